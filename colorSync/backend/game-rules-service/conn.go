@@ -0,0 +1,116 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// WebSocket connection tuning
+const (
+	writeWait     = 5 * time.Second   // Longest a single write may take
+	pongWait      = 30 * time.Second  // Drop the player if nothing arrives for this long
+	pingPeriod    = pongWait * 9 / 10 // Ping often enough for the pong to beat pongWait
+	sendQueueSize = 32                // Messages a player may fall behind before being dropped
+)
+
+// PlayerConn is a player's WebSocket connection
+// gorilla/websocket allows one writer at a time, so every message goes through
+// a queue drained by a single writer goroutine. A player who lets the queue fill
+// up is too slow to play and is disconnected, like any other dropped player.
+type PlayerConn struct {
+	userID string
+	ws     *websocket.Conn
+	send   chan WSMessage
+
+	done      chan struct{} // Closed once the connection is closed
+	closeOnce sync.Once
+}
+
+// newPlayerConn wraps ws and starts its writer and keepalive pings
+func newPlayerConn(userID string, ws *websocket.Conn) *PlayerConn {
+	pc := &PlayerConn{
+		userID: userID,
+		ws:     ws,
+		send:   make(chan WSMessage, sendQueueSize),
+		done:   make(chan struct{}),
+	}
+
+	// Every pong (or message, see ReadJSON) proves the player is still there
+	ws.SetReadDeadline(time.Now().Add(pongWait))
+	ws.SetPongHandler(func(string) error {
+		return ws.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	go pc.writeLoop()
+	return pc
+}
+
+// Send queues msg for the player without blocking
+// Returns false if the connection is closed or was just dropped for falling behind
+func (pc *PlayerConn) Send(msg WSMessage) bool {
+	select {
+	case <-pc.done:
+		return false
+	default:
+	}
+
+	select {
+	case pc.send <- msg:
+		return true
+	default:
+		log.Printf("Player %s is not keeping up (%d messages queued) - disconnecting", pc.userID, sendQueueSize)
+		pc.Close()
+		return false
+	}
+}
+
+// ReadJSON reads the next message from the player
+// Only one goroutine may read, see handlePlayerMessages
+func (pc *PlayerConn) ReadJSON(msg *WSMessage) error {
+	if err := pc.ws.ReadJSON(msg); err != nil {
+		return err
+	}
+	return pc.ws.SetReadDeadline(time.Now().Add(pongWait))
+}
+
+// Close closes the connection, which also ends the player's read loop
+// Safe to call more than once and from any goroutine
+func (pc *PlayerConn) Close() {
+	pc.closeOnce.Do(func() {
+		close(pc.done)
+		pc.ws.Close()
+	})
+}
+
+// writeLoop is the connection's only writer: queued messages and keepalive pings
+func (pc *PlayerConn) writeLoop() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		pc.Close()
+	}()
+
+	for {
+		select {
+		case msg := <-pc.send:
+			pc.ws.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := pc.ws.WriteJSON(msg); err != nil {
+				log.Printf("Write to player %s failed: %v", pc.userID, err)
+				return
+			}
+
+		case <-ticker.C:
+			pc.ws.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := pc.ws.WriteMessage(websocket.PingMessage, nil); err != nil {
+				log.Printf("Ping to player %s failed: %v", pc.userID, err)
+				return
+			}
+
+		case <-pc.done:
+			return
+		}
+	}
+}
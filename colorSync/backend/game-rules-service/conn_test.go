@@ -0,0 +1,122 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// dialPlayer connects a client to a PlayerConn served by a test server
+func dialPlayer(t *testing.T) (*PlayerConn, *websocket.Conn) {
+	t.Helper()
+	conns := make(chan *PlayerConn, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		conns <- newPlayerConn("alice", ws)
+	}))
+	t.Cleanup(server.Close)
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	pc := <-conns
+	t.Cleanup(pc.Close)
+	return pc, client
+}
+
+func TestSlowPlayerIsDisconnected(t *testing.T) {
+	pc, client := dialPlayer(t)
+
+	// The client never reads: once the socket and the queue are full, Send gives up
+	big := WSMessage{Type: "FILLER", Payload: map[string]interface{}{"data": strings.Repeat("x", 64*1024)}}
+	sent := 0
+	for ; sent < 10000 && pc.Send(big); sent++ {
+	}
+	if sent == 10000 {
+		t.Fatal("Send never reported the player as too slow")
+	}
+
+	select {
+	case <-pc.done:
+	case <-time.After(time.Second):
+		t.Fatal("connection not closed after the queue filled")
+	}
+	if pc.Send(WSMessage{Type: "LATE"}) {
+		t.Fatal("Send after the disconnect = true, want false")
+	}
+
+	// The client sees the connection end once it reads what did get through
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		_, _, err := client.ReadMessage()
+		if err == nil {
+			continue
+		}
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			t.Fatal("client still connected")
+		}
+		return
+	}
+}
+
+func TestSendAfterClose(t *testing.T) {
+	pc, _ := dialPlayer(t)
+	pc.Close()
+	pc.Close() // Twice is fine too
+
+	done := make(chan bool)
+	go func() { done <- pc.Send(WSMessage{Type: "ROUND_START"}) }()
+	select {
+	case ok := <-done:
+		if ok {
+			t.Fatal("Send after Close = true, want false")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Send after Close blocked")
+	}
+}
+
+func TestConcurrentSends(t *testing.T) {
+	pc, client := dialPlayer(t)
+
+	// Several goroutines at once, no more than the queue holds between them
+	const senders, each = 4, sendQueueSize / 4
+	var wg sync.WaitGroup
+	for range senders {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range each {
+				if !pc.Send(WSMessage{Type: "ROUND_FEEDBACK"}) {
+					t.Error("Send = false on a healthy connection")
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Every message arrives whole, as it would not with interleaved writes
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for i := range senders * each {
+		var msg WSMessage
+		if err := client.ReadJSON(&msg); err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+		if msg.Type != "ROUND_FEEDBACK" {
+			t.Fatalf("message %d is %q, want ROUND_FEEDBACK", i, msg.Type)
+		}
+	}
+}
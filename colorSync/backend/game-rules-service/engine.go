@@ -0,0 +1,156 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// Each running game is driven by one goroutine, runGame. Clicks and players
+// dropping or rejoining reach it on channels, and its timers come from a
+// Clock, so a round ends the moment it is decided and tests can play a whole
+// game on a fake clock without sleeping.
+
+// gameStartDelay gives players time to read the rules before round 1
+const gameStartDelay = 2 * time.Second
+
+// Clock is where the game engine gets the time from
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is the wall clock
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// playerClick is an answer from a player, stamped with when it arrived
+type playerClick struct {
+	playerID string
+	answer   string
+	at       time.Time
+}
+
+// presenceChange is a player dropping out of or rejoining a running game
+type presenceChange struct {
+	playerID  string
+	connected bool
+}
+
+// submitClick hands a player's answer to the game engine
+// Answers are ignored unless the game is running
+func submitClick(game *Game, playerID, answer string) {
+	game.mu.Lock()
+	running := game.Status == StatusInProgress
+	click := playerClick{playerID: playerID, answer: answer, at: game.clock.Now()}
+	game.mu.Unlock()
+
+	if !running {
+		log.Printf("Player %s clicked but game %s is not running", playerID, game.RoomID)
+		return
+	}
+
+	select {
+	case game.clicks <- click:
+	case <-game.done: // Engine stopped, the game is over
+	}
+}
+
+// notifyPresence tells the game engine a player dropped out or came back
+func notifyPresence(game *Game, playerID string, connected bool) {
+	select {
+	case game.presence <- presenceChange{playerID: playerID, connected: connected}:
+	case <-game.done: // Engine stopped, the game is over
+	}
+}
+
+// waitFor lets d of game time pass, handling clicks and players coming and
+// going meanwhile. The time stands still while the game is paused.
+// With a round open it returns as soon as the round is over.
+// Returns false if the game ended while waiting
+func waitFor(game *Game, d time.Duration, roundOpen bool) bool {
+	game.mu.Lock()
+	game.deadline = game.clock.Now().Add(d)
+	game.mu.Unlock()
+
+	for {
+		game.mu.Lock()
+		if game.Status != StatusInProgress {
+			game.mu.Unlock()
+			return false
+		}
+		if roundOpen && roundOverLocked(game) {
+			game.mu.Unlock()
+			return true
+		}
+		// A nil timer never fires: paused with nobody's grace period running, only a player can wake the engine
+		var timer <-chan time.Time
+		if wake, ok := nextWakeLocked(game); ok {
+			timer = game.clock.After(wake.Sub(game.clock.Now()))
+		}
+		game.mu.Unlock()
+
+		select {
+		case click := <-game.clicks:
+			// Between rounds no round is open, so the click is only recorded as turned away
+			handleClick(game, click)
+
+		case change := <-game.presence:
+			handlePresence(game, change)
+
+		case <-timer:
+			game.mu.Lock()
+			expireGraceLocked(game)
+			timedOut := game.Status == StatusInProgress && !game.paused && !game.clock.Now().Before(game.deadline)
+			game.mu.Unlock()
+
+			if timedOut {
+				return true
+			}
+		}
+	}
+}
+
+// nextWakeLocked is when the engine has to act on its own next: the end of
+// the current wait, or a dropped player's grace period running out
+// Returns false if there is nothing to wait for, which happens while paused
+// after a player rejoined but before the engine has been told
+// Caller must hold game.mu
+func nextWakeLocked(game *Game) (time.Time, bool) {
+	var wake time.Time
+	if !game.paused {
+		wake = game.deadline
+	}
+	for _, droppedAt := range game.droppedAt {
+		expiry := droppedAt.Add(game.Config.ReconnectGrace())
+		if wake.IsZero() || expiry.Before(wake) {
+			wake = expiry
+		}
+	}
+	return wake, !wake.IsZero()
+}
+
+// handlePresence pauses the game for a dropped player, or resumes it once everyone is back
+func handlePresence(game *Game, change presenceChange) {
+	game.mu.Lock()
+	defer game.mu.Unlock()
+
+	if change.connected {
+		resumeIfCompleteLocked(game)
+		return
+	}
+
+	// Back already, before we got to it
+	if !game.disconnected[change.playerID] {
+		return
+	}
+
+	// A dropped player gets the grace period to come back, or forfeits right away without one
+	if game.Config.ReconnectGrace() > 0 {
+		pauseForReconnectLocked(game, change.playerID)
+		return
+	}
+	log.Printf("Player %s disconnected during game - ending game", change.playerID)
+	forfeitLocked(game, change.playerID)
+}
@@ -0,0 +1,565 @@
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Flokots/programming-5/colorSync/shared/auth"
+	"github.com/Flokots/programming-5/colorSync/shared/gameconfig"
+)
+
+// fakeClock only moves when a test advances it
+// Every After call is reported on waits, which is how tests know the engine
+// has handled everything sent to it and is waiting for something new
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []fakeTimer
+	waits  chan time.Duration
+}
+
+type fakeTimer struct {
+	at time.Time
+	c  chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{
+		now:   time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC),
+		waits: make(chan time.Duration, 100),
+	}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	timer := fakeTimer{at: c.now.Add(d), c: make(chan time.Time, 1)}
+	c.timers = append(c.timers, timer)
+	c.mu.Unlock()
+
+	c.waits <- d
+	return timer.c
+}
+
+// advance moves the clock on by d, firing every timer that comes due
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	pending := c.timers[:0]
+	for _, timer := range c.timers {
+		if timer.at.After(c.now) {
+			pending = append(pending, timer)
+			continue
+		}
+		timer.c <- c.now
+	}
+	c.timers = pending
+}
+
+// idle waits for the engine to settle and returns how long until it wakes up on its own
+func (c *fakeClock) idle(t *testing.T) time.Duration {
+	t.Helper()
+	select {
+	case d := <-c.waits:
+		return d
+	case <-time.After(2 * time.Second):
+		t.Fatal("game engine never went idle")
+		return 0
+	}
+}
+
+// callbacks records the lifecycle events Room Service is sent
+// User Service calls are answered and ignored
+type callbacks struct {
+	mu     sync.Mutex
+	events map[string]lifecycleEvent // Room ID to the last event about it
+}
+
+func (cb *callbacks) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	roomID, isLifecycle := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/internal/rooms/"), "/lifecycle")
+	var event lifecycleEvent
+	if isLifecycle && json.NewDecoder(r.Body).Decode(&event) == nil {
+		cb.mu.Lock()
+		cb.events[roomID] = event
+		cb.mu.Unlock()
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+var reported = &callbacks{events: make(map[string]lifecycleEvent)}
+
+func TestMain(m *testing.M) {
+	os.Setenv("AUTH_DEV_KEYS", "1")
+	if err := auth.LoadServiceKeys(auth.GameRulesService); err != nil {
+		panic(err)
+	}
+
+	server := httptest.NewServer(reported)
+	userServiceURL, roomServiceURL = server.URL, server.URL
+
+	code := m.Run()
+	server.Close()
+	os.Exit(code)
+}
+
+// reportedEvent waits for Room Service to be sent the named event about roomID
+// The callback is sent in the background, so this waits in real time
+func reportedEvent(t *testing.T, roomID, name string) lifecycleEvent {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		reported.mu.Lock()
+		event, ok := reported.events[roomID]
+		reported.mu.Unlock()
+		if ok && event.Event == name {
+			return event
+		}
+	}
+	t.Fatalf("room %s never reported %s", roomID, name)
+	return lifecycleEvent{}
+}
+
+// finishedEvent waits for Room Service to be told the game in roomID finished
+func finishedEvent(t *testing.T, roomID string) lifecycleEvent {
+	t.Helper()
+	return reportedEvent(t, roomID, LifecycleGameFinished)
+}
+
+// startTestGame starts a game between alice and bob on a fake clock, as if both had connected
+func startTestGame(t *testing.T, config gameconfig.Config) (*Game, *fakeClock) {
+	t.Helper()
+	if err := config.Validate(); err != nil {
+		t.Fatalf("bad test config: %v", err)
+	}
+
+	clock := newFakeClock()
+	game, _ := games.getOrCreate("room-"+t.Name(), func() *Game {
+		return newGame("room-"+t.Name(), []string{"alice", "bob"}, config, clock)
+	})
+	t.Cleanup(func() { games.remove(game) })
+	game.Status = StatusInProgress
+	go runGame(game)
+
+	if d := clock.idle(t); d != gameStartDelay {
+		t.Fatalf("game start delay = %s, want %s", d, gameStartDelay)
+	}
+	clock.advance(gameStartDelay)
+	return game, clock
+}
+
+// answer returns the current round's correct answer and a wrong one
+func answer(game *Game) (right, wrong string) {
+	game.mu.Lock()
+	defer game.mu.Unlock()
+
+	for _, option := range game.trial.Options {
+		if option != game.trial.Answer {
+			return game.trial.Answer, option
+		}
+	}
+	return game.trial.Answer, ""
+}
+
+func results(game *Game) []RoundResult {
+	game.mu.Lock()
+	defer game.mu.Unlock()
+	return append([]RoundResult(nil), game.Results...)
+}
+
+func testConfig() gameconfig.Config {
+	config := gameconfig.Default()
+	config.Rounds = 2
+	config.PauseMs = 1000
+	return config
+}
+
+func TestRoundEndsOnFirstCorrectAnswer(t *testing.T) {
+	game, clock := startTestGame(t, testConfig())
+
+	// Round 1: bob answers after 300ms
+	if d := clock.idle(t); d != 5*time.Second {
+		t.Fatalf("round 1 waits %s, want the 5s timeout", d)
+	}
+	right, _ := answer(game)
+	clock.advance(300 * time.Millisecond)
+	submitClick(game, "bob", right)
+
+	// The round is over at once: next up is the pause between rounds
+	if d := clock.idle(t); d != time.Second {
+		t.Fatalf("after the correct answer the engine waits %s, want the 1s pause", d)
+	}
+	if got := results(game); len(got) != 1 || got[0].Winner != "bob" || got[0].Latency != 300 {
+		t.Fatalf("round 1 result = %+v, want bob in 300ms", got)
+	}
+
+	// Round 2: nobody answers
+	clock.advance(time.Second)
+	clock.idle(t)
+	clock.advance(5 * time.Second)
+	clock.idle(t)
+	clock.advance(time.Second)
+
+	event := finishedEvent(t, game.RoomID)
+	if event.Winner != "bob" || event.Reason != "game_completed" {
+		t.Fatalf("game finished with %+v, want bob to win", event)
+	}
+	if got := results(game); len(got) != 2 || got[1].Winner != "timeout" {
+		t.Fatalf("round 2 result = %+v, want a timeout", got)
+	}
+}
+
+func TestWrongAnswerLocksOut(t *testing.T) {
+	game, clock := startTestGame(t, testConfig())
+
+	clock.idle(t)
+	right, wrong := answer(game)
+	clock.advance(100 * time.Millisecond)
+	submitClick(game, "alice", wrong)
+	clock.idle(t)
+	clock.advance(100 * time.Millisecond)
+	submitClick(game, "alice", right) // Locked out, ignored
+	clock.idle(t)
+	clock.advance(400 * time.Millisecond)
+	submitClick(game, "bob", right)
+	clock.idle(t)
+
+	got := results(game)
+	if len(got) != 1 || got[0].Winner != "bob" || got[0].Latency != 600 || got[0].Wrong["alice"] != 1 {
+		t.Fatalf("round 1 result = %+v, want bob in 600ms and one wrong answer from alice", got)
+	}
+
+	// What User Service is told for the players' statistics
+	game.mu.Lock()
+	stats := matchStatsLocked(game)
+	game.mu.Unlock()
+	want := map[string]playerMatchStats{
+		"alice": {RoundsPlayed: 1, WrongAnswers: 1},
+		"bob":   {RoundsPlayed: 1, RoundsWon: 1, CorrectAnswers: 1, ReactionMs: 600, BestReactionMs: 600},
+	}
+	if !maps.Equal(stats, want) {
+		t.Fatalf("match stats = %+v, want %+v", stats, want)
+	}
+}
+
+func TestReplayChecksTheRecordedWinner(t *testing.T) {
+	game, clock := startTestGame(t, testConfig())
+
+	// Round 1: alice is wrong, bob right after 400ms
+	clock.idle(t)
+	right, wrong := answer(game)
+	clock.advance(100 * time.Millisecond)
+	submitClick(game, "alice", wrong)
+	clock.idle(t)
+	clock.advance(300 * time.Millisecond)
+	submitClick(game, "bob", right)
+	clock.idle(t)
+	clock.advance(time.Second)
+
+	// Round 2: alice right after 250ms, which wins her the tiebreak
+	clock.idle(t)
+	right, _ = answer(game)
+	clock.advance(250 * time.Millisecond)
+	submitClick(game, "alice", right)
+	clock.idle(t)
+	clock.advance(time.Second)
+
+	if event := finishedEvent(t, game.RoomID); event.Winner != "alice" {
+		t.Fatalf("game won by %s, want alice", event.Winner)
+	}
+	<-game.done
+	record, err := history.Get(game.RoomID)
+	if err != nil {
+		t.Fatalf("game not in history: %v", err)
+	}
+	if record.Seed != game.Seed || len(record.Clicks) != 3 || !record.Clicks[1].At.Equal(record.StartedAt.Add(2*time.Second+400*time.Millisecond)) {
+		t.Fatalf("history has seed %d and clicks %+v, want seed %d and bob's click stamped 2.4s in", record.Seed, record.Clicks, game.Seed)
+	}
+
+	// The rounds are dealt again from the seed and the clicks bear out the winner
+	report, err := replayGame(*record)
+	if err != nil || !report.Verified || report.Winner != "alice" || report.Rounds != 2 {
+		t.Fatalf("replay = %+v, %v; want alice verified as the winner over 2 rounds", report, err)
+	}
+
+	// Had bob been quicker, the record would not stand
+	tampered := *record
+	tampered.Clicks = slices.Clone(record.Clicks)
+	tampered.Clicks[1].Latency = 200
+	report, err = replayGame(tampered)
+	if err != nil || report.Verified || report.Winner != "bob" {
+		t.Fatalf("replay of tampered record = %+v, %v; want bob winning and a mismatch", report, err)
+	}
+}
+
+func TestReplayCoversRejectedClicks(t *testing.T) {
+	game, clock := startTestGame(t, testConfig())
+
+	// Round 1: alice is wrong and then locked out, bob right, alice too late
+	clock.idle(t)
+	right, wrong := answer(game)
+	clock.advance(100 * time.Millisecond)
+	submitClick(game, "alice", wrong)
+	clock.idle(t)
+	submitClick(game, "alice", right)
+	clock.idle(t)
+	clock.advance(100 * time.Millisecond)
+	submitClick(game, "bob", right)
+	clock.idle(t)
+	submitClick(game, "alice", right)
+	clock.idle(t)
+	clock.advance(time.Second)
+
+	// Round 2: alice clicks while bob is away, then wins once he is back
+	clock.idle(t)
+	right, _ = answer(game)
+	dropPlayer(game, "bob")
+	clock.idle(t)
+	submitClick(game, "alice", right)
+	clock.idle(t)
+	rejoinPlayer(game, "bob")
+	clock.idle(t)
+	clock.advance(300 * time.Millisecond)
+	submitClick(game, "alice", right)
+	clock.idle(t)
+	clock.advance(time.Second)
+
+	finishedEvent(t, game.RoomID)
+	<-game.done
+	record, err := history.Get(game.RoomID)
+	if err != nil {
+		t.Fatalf("game not in history: %v", err)
+	}
+	var rejected []string
+	for _, click := range record.Clicks {
+		rejected = append(rejected, click.Rejected)
+	}
+	want := []string{"", ClickLockedOut, "", ClickRoundClosed, ClickPaused, ""}
+	if !slices.Equal(rejected, want) {
+		t.Fatalf("clicks rejected as %q, want %q", rejected, want)
+	}
+
+	report, err := replayGame(*record)
+	if err != nil || !report.Verified {
+		t.Fatalf("replay = %+v, %v; want it verified", report, err)
+	}
+
+	// So is a click before the first round, which is turned away too
+	early := *record
+	early.Clicks = append([]ClickRecord{{PlayerID: "bob", Answer: right, Rejected: ClickRoundClosed, At: record.StartedAt}}, record.Clicks...)
+	if report, err = replayGame(early); err != nil || !report.Verified {
+		t.Fatalf("replay with a click before round 1 = %+v, %v; want it verified", report, err)
+	}
+
+	// Had alice's second click been judged, the record would not stand
+	tampered := *record
+	tampered.Clicks = slices.Clone(record.Clicks)
+	tampered.Clicks[1].Rejected = ""
+	if report, err = replayGame(tampered); err != nil || report.Verified {
+		t.Fatalf("replay of tampered record = %+v, %v; want a mismatch", report, err)
+	}
+}
+
+func TestSeedCommitment(t *testing.T) {
+	if seedCommitment(42) != seedCommitment(42) {
+		t.Fatal("the same seed gave two commitments")
+	}
+	if seedCommitment(42) == seedCommitment(43) {
+		t.Fatal("two seeds gave the same commitment")
+	}
+	// sha256 of "42", which a client can check the revealed seed against
+	if got := seedCommitment(42); got != "73475cb40a568e8da8a045ced110137e159f890ac4da883b6b17dc651b3a8049" {
+		t.Fatalf("seedCommitment(42) = %s", got)
+	}
+}
+
+func TestPointsRoundWaitsForBothPlayers(t *testing.T) {
+	config := testConfig()
+	config.Scoring = gameconfig.ScoringPoints
+	game, clock := startTestGame(t, config)
+
+	clock.idle(t)
+	right, _ := answer(game)
+	submitClick(game, "alice", right) // Instant: full points
+	if d := clock.idle(t); d != 5*time.Second {
+		t.Fatalf("after one answer the engine waits %s, want the rest of the round", d)
+	}
+
+	clock.advance(2500 * time.Millisecond)
+	submitClick(game, "bob", right)
+	clock.idle(t)
+
+	got := results(game)
+	if len(got) != 1 || got[0].Winner != "alice" {
+		t.Fatalf("round 1 result = %+v, want alice first", got)
+	}
+	if got[0].Points["alice"] != maxCorrectPoints || got[0].Points["bob"] != pointsFor(2500*time.Millisecond, 5*time.Second) {
+		t.Fatalf("round 1 points = %v", got[0].Points)
+	}
+}
+
+func TestPauseStopsTheRoundClock(t *testing.T) {
+	game, clock := startTestGame(t, testConfig())
+
+	clock.idle(t)
+	right, _ := answer(game)
+
+	// Bob drops 1s into the round
+	clock.advance(time.Second)
+	dropPlayer(game, "bob")
+	if d := clock.idle(t); d != 15*time.Second {
+		t.Fatalf("paused game waits %s, want the 15s grace period", d)
+	}
+
+	// Clicks don't count while paused
+	submitClick(game, "alice", right)
+	clock.idle(t)
+
+	// Bob is back 10s later: the round goes on with the 4s it had left
+	clock.advance(10 * time.Second)
+	rejoinPlayer(game, "bob")
+	if d := clock.idle(t); d != 4*time.Second {
+		t.Fatalf("resumed round waits %s, want the 4s that were left", d)
+	}
+
+	clock.advance(time.Second)
+	submitClick(game, "alice", right)
+	clock.idle(t)
+
+	if got := results(game); len(got) != 1 || got[0].Winner != "alice" || got[0].Latency != 2000 {
+		t.Fatalf("round 1 result = %+v, want alice in 2000ms of playing time", got)
+	}
+}
+
+func TestRejoinBeforeTheEngineHearsOfIt(t *testing.T) {
+	game, clock := startTestGame(t, testConfig())
+
+	clock.idle(t)
+	right, _ := answer(game)
+	clock.advance(time.Second)
+	dropPlayer(game, "bob")
+	clock.idle(t)
+
+	// Bob is back, but the engine hasn't had the news yet: paused with no grace period running
+	game.mu.Lock()
+	game.disconnected["bob"] = false
+	delete(game.droppedAt, "bob")
+	if wake, ok := nextWakeLocked(game); ok {
+		t.Errorf("nextWakeLocked = %s, want nothing to wait for", wake)
+	}
+	game.mu.Unlock()
+
+	// Anything that wakes the engine meanwhile must not leave it spinning on a timer
+	submitClick(game, "alice", right)
+	select {
+	case d := <-clock.waits:
+		t.Fatalf("engine set a %s timer while waiting for bob's return", d)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Once it hears bob is back, the round goes on with the 4s it had left
+	notifyPresence(game, "bob", true)
+	if d := clock.idle(t); d != 4*time.Second {
+		t.Fatalf("resumed round waits %s, want the 4s that were left", d)
+	}
+}
+
+func TestPlayerWhoDoesNotComeBackForfeits(t *testing.T) {
+	game, clock := startTestGame(t, testConfig())
+
+	clock.idle(t)
+	dropPlayer(game, "alice")
+	clock.idle(t)
+	clock.advance(15 * time.Second)
+
+	event := finishedEvent(t, game.RoomID)
+	if event.Winner != "bob" || event.Reason != "opponent_disconnected" {
+		t.Fatalf("game finished with %+v, want bob to win by forfeit", event)
+	}
+	select {
+	case <-game.done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("game engine still running after the forfeit")
+	}
+	// The game is in the history, as far as it got
+	record, err := history.Get(game.RoomID)
+	if err != nil {
+		t.Fatalf("forfeited game not in history: %v", err)
+	}
+	if record.Winner != "bob" || record.Reason != "opponent_disconnected" || len(record.Results) != 0 {
+		t.Fatalf("history has %+v, want bob winning by forfeit before any round finished", record)
+	}
+	// ...and nowhere else
+	if _, exists := games.get(game.RoomID); exists {
+		t.Fatal("finished game still in the registry")
+	}
+}
+
+func TestNoGracePeriodForfeitsAtOnce(t *testing.T) {
+	config := testConfig()
+	config.ReconnectGraceMs = 0
+	game, clock := startTestGame(t, config)
+
+	clock.idle(t)
+	dropPlayer(game, "bob")
+
+	event := finishedEvent(t, game.RoomID)
+	if event.Winner != "alice" || event.Reason != "opponent_disconnected" {
+		t.Fatalf("game finished with %+v, want alice to win by forfeit", event)
+	}
+}
+
+func TestGameNobodyJoinsExpires(t *testing.T) {
+	clock := newFakeClock()
+	game, _ := games.getOrCreate("room-"+t.Name(), func() *Game {
+		return newGame("room-"+t.Name(), []string{"alice", "bob"}, testConfig(), clock)
+	})
+	t.Cleanup(func() { games.remove(game) })
+
+	// Still within the deadline: nothing happens
+	expireGames(clock.Now().Add(connectTimeout - time.Second))
+	if _, exists := games.get(game.RoomID); !exists {
+		t.Fatal("game expired before its deadline")
+	}
+
+	expireGames(clock.Now().Add(connectTimeout))
+	if _, exists := games.get(game.RoomID); exists {
+		t.Fatal("expired game still in the registry")
+	}
+	if game.Status != StatusExpired {
+		t.Fatalf("game status = %s, want %s", game.Status, StatusExpired)
+	}
+	if event := reportedEvent(t, game.RoomID, LifecycleGameAborted); event.Reason != "connect_timeout" {
+		t.Fatalf("room told the game was aborted with %+v, want connect_timeout", event)
+	}
+}
+
+// dropPlayer does what losing a player's connection does
+func dropPlayer(game *Game, playerID string) {
+	game.mu.Lock()
+	game.disconnected[playerID] = true
+	game.mu.Unlock()
+	checkDisconnection(game, playerID)
+}
+
+// rejoinPlayer does what a player reconnecting does
+func rejoinPlayer(game *Game, playerID string) {
+	game.mu.Lock()
+	game.disconnected[playerID] = false
+	delete(game.droppedAt, playerID)
+	game.mu.Unlock()
+	notifyPresence(game, playerID, true)
+}
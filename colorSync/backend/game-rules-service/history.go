@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Flokots/programming-5/colorSync/shared/gameconfig"
+)
+
+// Finished games, selected at startup (see newGameHistory)
+var history GameHistory = newMemoryGameHistory()
+
+// Page sizes for GET /users/{id}/games
+const (
+	defaultHistoryLimit = 20
+	maxHistoryLimit     = 100
+)
+
+// GameRecord is a finished game as kept in the history
+type GameRecord struct {
+	RoomID     string            `json:"room_id"`
+	Players    []string          `json:"players"`
+	Config     gameconfig.Config `json:"config"`
+	Seed       int64             `json:"seed"`   // The rounds can be dealt again from it (see replay.go)
+	Winner     string            `json:"winner"` // Player ID or "draw"
+	Reason     string            `json:"reason"` // game_completed or opponent_disconnected
+	Scores     map[string]int    `json:"scores"` // What the winner was decided on, see Config.Scoring
+	Results    []RoundResult     `json:"results"`
+	Clicks     []ClickRecord     `json:"clicks"` // Every answer that was judged, in order
+	StartedAt  time.Time         `json:"started_at"`
+	FinishedAt time.Time         `json:"finished_at"`
+
+	Stats map[string]map[string]interface{} `json:"stats"` // As sent in GAME_OVER
+}
+
+// Why a click was turned away without being judged
+const (
+	ClickRoundClosed     = "round_closed"     // No round open: before the first, between rounds or after the round ended
+	ClickPaused          = "paused"           // The game was waiting for a player to reconnect
+	ClickAlreadyAnswered = "already_answered" // The round was won, or with points the player had answered correctly
+	ClickLockedOut       = "locked_out"       // The player's wrong answer locked them out of the round
+)
+
+// ClickRecord is one click in a game's timeline, judged or turned away
+type ClickRecord struct {
+	Round    int       `json:"round"`
+	PlayerID string    `json:"player_id"`
+	Answer   string    `json:"answer"`
+	Correct  bool      `json:"correct"`
+	Rejected string    `json:"rejected,omitempty"` // Why the click wasn't judged (Click* above), empty if it was
+	Latency  int64     `json:"latency_ms"`         // Since the round started, pauses excluded
+	At       time.Time `json:"at"`                 // When the server received it
+}
+
+// GameSummary is a game in a player's match history
+type GameSummary struct {
+	RoomID       string         `json:"room_id"`
+	Players      []string       `json:"players"`
+	Mode         string         `json:"mode"`
+	Scoring      string         `json:"scoring"`
+	RoundsPlayed int            `json:"rounds_played"`
+	Winner       string         `json:"winner"`
+	Reason       string         `json:"reason"`
+	Scores       map[string]int `json:"scores"`
+	StartedAt    time.Time      `json:"started_at"`
+	FinishedAt   time.Time      `json:"finished_at"`
+}
+
+func (record GameRecord) summary() GameSummary {
+	return GameSummary{
+		RoomID:       record.RoomID,
+		Players:      record.Players,
+		Mode:         record.Config.Mode,
+		Scoring:      record.Config.Scoring,
+		RoundsPlayed: len(record.Results),
+		Winner:       record.Winner,
+		Reason:       record.Reason,
+		Scores:       record.Scores,
+		StartedAt:    record.StartedAt,
+		FinishedAt:   record.FinishedAt,
+	}
+}
+
+// archiveGame stores a finished game in the history
+// Games that never finished are not kept
+func archiveGame(game *Game) error {
+	game.mu.Lock()
+	if game.Status != StatusFinished {
+		game.mu.Unlock()
+		return nil
+	}
+
+	scores := make(map[string]int)
+	for playerID, score := range scoreGame(game) {
+		scores[playerID] = score.Score
+	}
+	record := GameRecord{
+		RoomID:     game.RoomID,
+		Players:    game.Players,
+		Config:     game.Config,
+		Seed:       game.Seed,
+		Winner:     game.winner,
+		Reason:     game.endReason,
+		Scores:     scores,
+		Results:    game.Results,
+		Clicks:     game.timeline,
+		StartedAt:  game.startedAt,
+		FinishedAt: game.clock.Now(),
+		Stats:      gameStats(game),
+	}
+	game.mu.Unlock()
+
+	if err := history.Save(record); err != nil {
+		return err
+	}
+	log.Printf("Game %s saved to history", game.RoomID)
+	return nil
+}
+
+// gamesHandler routes /games/{roomID} and /games/{roomID}/replay
+func gamesHandler(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(r.URL.Path, "/replay") {
+		replayHandler(w, r)
+		return
+	}
+	gameHistoryHandler(w, r)
+}
+
+// GET /games/{roomID} - one finished game with every round and click
+func gameHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	roomID := strings.TrimPrefix(r.URL.Path, "/games/")
+	if roomID == "" || strings.Contains(roomID, "/") {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	record, err := history.Get(roomID)
+	if errors.Is(err, ErrGameNotFound) {
+		http.Error(w, "Game not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to look up game %s: %v", roomID, err)
+		http.Error(w, "Failed to look up game", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(record)
+}
+
+// PlayerGamesResponse is a page of a player's match history
+type PlayerGamesResponse struct {
+	UserID string        `json:"user_id"`
+	Games  []GameSummary `json:"games"`
+	Total  int           `json:"total"`
+	Limit  int           `json:"limit"`
+	Offset int           `json:"offset"`
+}
+
+// GET /users/{id}/games?limit=&offset= - a player's finished games, newest first
+func playerGamesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// URL format: /users/{id}/games
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/users/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] != "games" {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	userID := parts[0]
+
+	limit, err := queryInt(r, "limit", defaultHistoryLimit)
+	if err != nil || limit < 1 || limit > maxHistoryLimit {
+		http.Error(w, "limit must be between 1 and "+strconv.Itoa(maxHistoryLimit), http.StatusBadRequest)
+		return
+	}
+	offset, err := queryInt(r, "offset", 0)
+	if err != nil || offset < 0 {
+		http.Error(w, "offset must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	records, total, err := history.ListByPlayer(userID, limit, offset)
+	if err != nil {
+		log.Printf("Failed to list games of %s: %v", userID, err)
+		http.Error(w, "Failed to list games", http.StatusInternalServerError)
+		return
+	}
+
+	games := make([]GameSummary, len(records))
+	for i, record := range records {
+		games[i] = record.summary()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(PlayerGamesResponse{
+		UserID: userID,
+		Games:  games,
+		Total:  total,
+		Limit:  limit,
+		Offset: offset,
+	})
+}
+
+// queryInt reads an integer query parameter, or fallback when it is absent
+func queryInt(r *http.Request, name string, fallback int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+	return strconv.Atoi(value)
+}
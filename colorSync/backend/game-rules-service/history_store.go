@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+)
+
+// ErrGameNotFound is returned when no finished game is stored for a room
+var ErrGameNotFound = errors.New("game not found")
+
+// GameHistory abstracts where finished games are kept
+// Handlers only talk to this interface, so the backend can be swapped at startup
+type GameHistory interface {
+	// Save stores a finished game, replacing any earlier record for the same room
+	Save(record GameRecord) error
+
+	// Get looks up the game played in a room, returns ErrGameNotFound if missing
+	Get(roomID string) (*GameRecord, error)
+
+	// ListByPlayer returns a page of the player's games, newest first, and how many they played
+	ListByPlayer(playerID string, limit, offset int) ([]GameRecord, int, error)
+
+	// Close releases any resources held by the store
+	Close() error
+}
+
+// newGameHistory picks a history implementation based on the environment
+// GAME_HISTORY=memory (default) keeps finished games in memory only
+// GAME_HISTORY=file appends them to GAME_HISTORY_PATH (default games.jsonl)
+func newGameHistory() (GameHistory, error) {
+	switch kind := getEnv("GAME_HISTORY", "memory"); kind {
+	case "memory":
+		return newMemoryGameHistory(), nil
+	case "file":
+		return openFileGameHistory(getEnv("GAME_HISTORY_PATH", "games.jsonl"))
+	default:
+		return nil, fmt.Errorf("unknown GAME_HISTORY %q (use memory or file)", kind)
+	}
+}
+
+// getEnv returns the environment variable value or a fallback
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// memoryGameHistory keeps games in maps guarded by a mutex
+// Everything is lost on restart
+type memoryGameHistory struct {
+	games    map[string]*GameRecord // roomID -> GameRecord
+	byPlayer map[string][]string    // playerID -> roomIDs, oldest first
+	mu       sync.RWMutex
+}
+
+func newMemoryGameHistory() *memoryGameHistory {
+	return &memoryGameHistory{
+		games:    make(map[string]*GameRecord),
+		byPlayer: make(map[string][]string),
+	}
+}
+
+func (h *memoryGameHistory) Save(record GameRecord) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, exists := h.games[record.RoomID]; !exists {
+		for _, playerID := range record.Players {
+			h.byPlayer[playerID] = append(h.byPlayer[playerID], record.RoomID)
+		}
+	}
+	h.games[record.RoomID] = &record
+	return nil
+}
+
+func (h *memoryGameHistory) Get(roomID string) (*GameRecord, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	record, exists := h.games[roomID]
+	if !exists {
+		return nil, ErrGameNotFound
+	}
+	copied := *record
+	return &copied, nil
+}
+
+func (h *memoryGameHistory) ListByPlayer(playerID string, limit, offset int) ([]GameRecord, int, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	roomIDs := h.byPlayer[playerID]
+	page := []GameRecord{}
+	for i := len(roomIDs) - 1 - offset; i >= 0 && len(page) < limit; i-- {
+		page = append(page, *h.games[roomIDs[i]])
+	}
+	return page, len(roomIDs), nil
+}
+
+func (h *memoryGameHistory) Close() error {
+	return nil
+}
+
+// fileGameHistory keeps games in memory and appends every finished game to a
+// JSON Lines file, which is replayed on startup. Games are never changed once
+// finished, so appending is all the file ever needs.
+type fileGameHistory struct {
+	file  *os.File
+	size  int64 // Up to the end of the last complete record
+	cache *memoryGameHistory
+	mu    sync.Mutex // Serializes writes to the file
+}
+
+// openFileGameHistory loads the history at path, creating it if missing
+func openFileGameHistory(path string) (*fileGameHistory, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open game history: %w", err)
+	}
+
+	h := &fileGameHistory{file: file, cache: newMemoryGameHistory()}
+
+	reader := bufio.NewReader(file)
+	loaded := 0
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// A write cut short by a crash leaves a last line without its newline.
+			// The next record would be appended onto it and lost with it, so drop it now
+			if len(data) > 0 {
+				log.Printf("Dropping incomplete game history line %d (%d bytes)", line, len(data))
+				if err := file.Truncate(h.size); err != nil {
+					file.Close()
+					return nil, fmt.Errorf("failed to drop incomplete game history line: %w", err)
+				}
+			}
+			break
+		}
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to read game history: %w", err)
+		}
+		h.size += int64(len(data))
+
+		var record GameRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			log.Printf("Skipping unreadable game history line %d: %v", line, err)
+			continue
+		}
+		h.cache.Save(record)
+		loaded++
+	}
+
+	log.Printf("Game history loaded from %s (%d games)", path, loaded)
+	return h, nil
+}
+
+func (h *fileGameHistory) Save(record GameRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode game %s: %w", record.RoomID, err)
+	}
+	data = append(data, '\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	_, err = h.file.Write(data)
+	if err == nil {
+		err = h.file.Sync()
+	}
+	if err != nil {
+		// Cut off whatever part of the record made it, so the next one starts on a line of its own
+		if truncErr := h.file.Truncate(h.size); truncErr != nil {
+			log.Printf("Failed to drop partly written game %s: %v", record.RoomID, truncErr)
+		}
+		return fmt.Errorf("failed to write game %s: %w", record.RoomID, err)
+	}
+	h.size += int64(len(data))
+	return h.cache.Save(record)
+}
+
+func (h *fileGameHistory) Get(roomID string) (*GameRecord, error) {
+	return h.cache.Get(roomID)
+}
+
+func (h *fileGameHistory) ListByPlayer(playerID string, limit, offset int) ([]GameRecord, int, error) {
+	return h.cache.ListByPlayer(playerID, limit, offset)
+}
+
+func (h *fileGameHistory) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.file.Close()
+}
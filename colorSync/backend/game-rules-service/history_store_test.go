@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testRecord(roomID string, players ...string) GameRecord {
+	return GameRecord{
+		RoomID:     roomID,
+		Players:    players,
+		Winner:     players[0],
+		Reason:     "game_completed",
+		StartedAt:  time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC),
+		FinishedAt: time.Date(2026, 10, 16, 12, 1, 0, 0, time.UTC),
+	}
+}
+
+func openTestHistory(t *testing.T, path string) *fileGameHistory {
+	t.Helper()
+	h, err := openFileGameHistory(path)
+	if err != nil {
+		t.Fatalf("openFileGameHistory: %v", err)
+	}
+	t.Cleanup(func() { h.Close() })
+	return h
+}
+
+func TestFileGameHistoryReopens(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "games.jsonl")
+	h := openTestHistory(t, path)
+	for _, record := range []GameRecord{testRecord("room-1", "alice", "bob"), testRecord("room-2", "bob", "carol")} {
+		if err := h.Save(record); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+	h.Close()
+
+	reopened := openTestHistory(t, path)
+	if record, err := reopened.Get("room-1"); err != nil || record.Winner != "alice" {
+		t.Fatalf("Get(room-1) = %+v, %v; want alice's win", record, err)
+	}
+	games, total, err := reopened.ListByPlayer("bob", 10, 0)
+	if err != nil || total != 2 || len(games) != 2 || games[0].RoomID != "room-2" {
+		t.Fatalf("ListByPlayer(bob) = %v of %d, %v; want room-2 then room-1", games, total, err)
+	}
+}
+
+func TestFileGameHistoryDropsIncompleteLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "games.jsonl")
+	h := openTestHistory(t, path)
+	if err := h.Save(testRecord("room-1", "alice", "bob")); err != nil {
+		t.Fatal(err)
+	}
+	h.Close()
+
+	// A crash cut the next write short
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"room_id":"room-2","players":["al`)
+	file.Close()
+
+	// The game saved after the restart must not be glued onto the torn line
+	h = openTestHistory(t, path)
+	if err := h.Save(testRecord("room-3", "alice", "carol")); err != nil {
+		t.Fatal(err)
+	}
+	h.Close()
+
+	reopened := openTestHistory(t, path)
+	for _, roomID := range []string{"room-1", "room-3"} {
+		if _, err := reopened.Get(roomID); err != nil {
+			t.Fatalf("Get(%s) after reopening: %v", roomID, err)
+		}
+	}
+	if _, err := reopened.Get("room-2"); err != ErrGameNotFound {
+		t.Fatalf("Get(room-2) = %v, want the torn game gone", err)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// useTestHistory swaps in an empty in-memory history for the test
+func useTestHistory(t *testing.T) {
+	t.Helper()
+	saved := history
+	history = newMemoryGameHistory()
+	t.Cleanup(func() { history = saved })
+}
+
+func TestGameHistoryHandler(t *testing.T) {
+	useTestHistory(t)
+	history.Save(testRecord("room-1", "alice", "bob"))
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		wantCode int
+	}{
+		{"finished game", http.MethodGet, "/games/room-1", http.StatusOK},
+		{"unknown game", http.MethodGet, "/games/room-2", http.StatusNotFound},
+		{"no room", http.MethodGet, "/games/", http.StatusNotFound},
+		{"nested path", http.MethodGet, "/games/room-1/clicks", http.StatusNotFound},
+		{"wrong method", http.MethodPost, "/games/room-1", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			gamesHandler(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var record GameRecord
+			if err := json.NewDecoder(w.Body).Decode(&record); err != nil {
+				t.Fatal(err)
+			}
+			if record.RoomID != "room-1" || record.Winner != "alice" || !slices.Equal(record.Players, []string{"alice", "bob"}) {
+				t.Fatalf("record = %+v, want alice beating bob in room-1", record)
+			}
+		})
+	}
+}
+
+func TestPlayerGamesHandler(t *testing.T) {
+	useTestHistory(t)
+	for i := 1; i <= 3; i++ {
+		history.Save(testRecord("room-"+strconv.Itoa(i), "alice", "bob"))
+	}
+	history.Save(testRecord("room-other", "carol", "dave"))
+
+	tests := []struct {
+		name     string
+		path     string
+		wantCode int
+		want     []string // Room IDs, newest first
+	}{
+		{"newest first", "/users/alice/games", http.StatusOK, []string{"room-3", "room-2", "room-1"}},
+		{"page", "/users/alice/games?limit=1&offset=1", http.StatusOK, []string{"room-2"}},
+		{"past the end", "/users/alice/games?offset=10", http.StatusOK, []string{}},
+		{"no games", "/users/erin/games", http.StatusOK, []string{}},
+		{"zero limit", "/users/alice/games?limit=0", http.StatusBadRequest, nil},
+		{"negative offset", "/users/alice/games?offset=-1", http.StatusBadRequest, nil},
+		{"not a games path", "/users/alice", http.StatusNotFound, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			playerGamesHandler(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var response PlayerGamesResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, game := range response.Games {
+				got = append(got, game.RoomID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("games = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,47 +4,80 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"math/rand"
+	"maps"
 	"net/http"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 
+	"github.com/Flokots/programming-5/colorSync/shared/auth"
+	"github.com/Flokots/programming-5/colorSync/shared/gameconfig"
 	"github.com/Flokots/programming-5/colorSync/shared/middleware"
 )
 
 // Game represents an active game session
 type Game struct {
-	RoomID       string                     `json:"room_id"`
-	Players      []string                   `json:"players"`
-	Connections  map[string]*websocket.Conn `json:"-"` // Don't serialize connections
-	Status       string                     `json:"status"`
-	CurrentRound int                        `json:"current_round"`
-	MaxRounds    int                        `json:"max_rounds"`
-	Results      []RoundResult              `json:"results"`
+	RoomID       string                 `json:"room_id"`
+	Players      []string               `json:"players"`
+	Connections  map[string]*PlayerConn `json:"-"` // Don't serialize connections
+	Status       string                 `json:"status"`
+	CurrentRound int                    `json:"current_round"`
+	MaxRounds    int                    `json:"max_rounds"`
+	Config       gameconfig.Config      `json:"config"`
+	Results      []RoundResult          `json:"results"`
+	Seed         int64                  `json:"seed"` // Every round's trial is derived from it (see replay.go)
 
 	disconnected map[string]bool `json:"-"` // Track disconnected players playerID -> disconnected
 
+	// Reconnection: play stops while a player is gone, for up to Config.ReconnectGrace
+	paused    bool
+	pausedAt  time.Time
+	droppedAt map[string]time.Time // When each player still being waited for dropped
+
+	mode GameMode // Picks each round's trial, from Config.Mode
+
+	// Game engine (see engine.go)
+	clock    Clock
+	clicks   chan playerClick
+	presence chan presenceChange
+	done     chan struct{} // Closed when the engine stops
+	deadline time.Time     // When the engine's current wait ends, moved on by pauses
+
 	// Round state (for click handling)
-	currentWord    string
-	currentColor   string
+	trial          Trial
 	roundStartTime time.Time
 	roundAnswered  bool
 	roundFinished  bool
 	roundWinner    string
 	roundLatency   int64
-	wrongAnswers   map[string]bool // Track who got it wrong
+	wrongAnswers   map[string]int  // Wrong clicks per player this round
+	roundCorrect   map[string]bool // Who answered correctly this round (points scoring)
+	roundPoints    map[string]int  // Points per player this round (points scoring)
+
+	createdAt time.Time // Players must connect within connectTimeout of this (see registry.go)
+
+	// For the game history (see history.go)
+	startedAt time.Time
+	timeline  []ClickRecord
+	winner    string
+	endReason string
 
 	mu sync.Mutex
 }
 
 type RoundResult struct {
-	Round   int    `json:"round"`
-	Word    string `json:"word"`
-	Color   string `json:"color"`
-	Winner  string `json:"winner"`
-	Latency int64  `json:"latency_ms"`
+	Round     int            `json:"round"`
+	Word      string         `json:"word"`
+	Color     string         `json:"color"`
+	Answer    string         `json:"answer"`    // Correct answer under the game mode
+	Congruent bool           `json:"congruent"` // Word and ink agreed
+	Winner    string         `json:"winner"`
+	Latency   int64          `json:"latency_ms"`
+	Wrong     map[string]int `json:"wrong,omitempty"`  // Wrong clicks per player
+	Points    map[string]int `json:"points,omitempty"` // Points per player (points scoring)
 }
 
 // WebSocket message types
@@ -54,8 +87,9 @@ type WSMessage struct {
 }
 
 var (
-	games    = make(map[string]*Game) // roomID to Game
-	gamesMu  sync.RWMutex
+	userServiceURL = "http://localhost:8001" // User service endpoint (JWKS)
+	roomServiceURL = "http://localhost:8002" // Room service endpoint (lifecycle callbacks)
+
 	upgrader = websocket.Upgrader{
 		CheckOrigin: func(r *http.Request) bool {
 			return true // Allow websocket from any origin
@@ -79,11 +113,43 @@ func corsMiddleware(next http.Handler) http.Handler {
 }
 
 func main() {
+	// Keys for service-to-service tokens
+	if err := auth.LoadServiceKeys(auth.GameRulesService); err != nil {
+		log.Fatalf("Failed to load service keys: %v", err)
+	}
+
+	// Verify user tokens with the public keys published by User Service
+	auth.UseRemoteJWKS(userServiceURL + "/.well-known/jwks.json")
+
+	// Mirror revoked user tokens from User Service
+	auth.SyncRevocations(userServiceURL+"/internal/revocations", auth.GameRulesService, 5*time.Second)
+
+	// Pick up rotated signing keys without a restart
+	auth.WatchKeyFiles(30 * time.Second)
+
+	// Finished games are kept for match history
+	var err error
+	history, err = newGameHistory()
+	if err != nil {
+		log.Fatalf("Failed to open game history: %v", err)
+	}
+	defer history.Close()
+
+	// Call off games nobody connects to
+	go runReaper()
+
 	mux := http.NewServeMux()
 
-	mux.HandleFunc("/game/start", middleware.RequireServiceAuth(startGameHandler))
+	// Only Room Service may start games
+	mux.HandleFunc("/game/start", middleware.RequireServicePolicy(middleware.ServicePolicy{
+		Audience: auth.GameRulesService,
+		Callers:  []string{auth.RoomService},
+		Scopes:   []string{auth.ScopeGameStart},
+	}, startGameHandler))
 	mux.HandleFunc("/game/ws", wsHandler)
 	mux.HandleFunc("/game/status", gameStatusHandler)
+	mux.HandleFunc("/games/", middleware.RequireAuth(gamesHandler))       // /games/{id}, /games/{id}/replay
+	mux.HandleFunc("/users/", middleware.RequireAuth(playerGamesHandler)) // /users/{id}/games
 	mux.HandleFunc("/health", healthHandler)
 
 	handler := corsMiddleware(mux)
@@ -101,30 +167,55 @@ func gameStatusHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	gamesMu.RLock()
-	game, exists := games[roomID]
-	gamesMu.RUnlock()
-
-	if !exists {
+	status := StatusFinished
+	if game, exists := games.get(roomID); exists {
+		game.mu.Lock()
+		status = game.Status
+		game.mu.Unlock()
+	} else if _, err := history.Get(roomID); err != nil {
+		// Finished games are only in the history
 		http.Error(w, "Game not found", http.StatusNotFound)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"room_id": game.RoomID,
-		"status":  game.Status,
+		"room_id": roomID,
+		"status":  status,
 	})
 }
 
+// newGame creates a game waiting for its players to connect
+func newGame(roomID string, players []string, config gameconfig.Config, clock Clock) *Game {
+	return &Game{
+		RoomID:       roomID,
+		Players:      players,
+		Connections:  make(map[string]*PlayerConn),
+		disconnected: make(map[string]bool),
+		droppedAt:    make(map[string]time.Time),
+		Status:       StatusWaiting,
+		MaxRounds:    config.Rounds,
+		Config:       config,
+		Seed:         newSeed(),
+		mode:         modeFor(config),
+		Results:      []RoundResult{},
+		clock:        clock,
+		clicks:       make(chan playerClick),
+		presence:     make(chan presenceChange),
+		done:         make(chan struct{}),
+		createdAt:    clock.Now(),
+	}
+}
+
 func healthHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"status": "healthy"})
 }
 
 type StartGameRequest struct {
-	RoomID  string   `json:"room_id"`
-	Players []string `json:"players"`
+	RoomID  string             `json:"room_id"`
+	Players []string           `json:"players"`
+	Config  *gameconfig.Config `json:"config,omitempty"` // Defaults when omitted
 }
 
 type StartGameResponse struct {
@@ -155,23 +246,38 @@ func startGameHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Create game session
-	game := &Game{
-		RoomID:       req.RoomID,
-		Players:      req.Players,
-		Connections:  make(map[string]*websocket.Conn),
-		disconnected: make(map[string]bool),
-		Status:       "waiting_for_players",
-		MaxRounds:    5,
-		Results:      []RoundResult{},
+	config := gameconfig.Default()
+	if req.Config != nil {
+		config = *req.Config
+	}
+	if err := config.Validate(); err != nil {
+		http.Error(w, "Invalid game config: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Starting the same room again is a no-op so Room Service can retry safely,
+	// even once the game is over and only in the history
+	if record, err := history.Get(req.RoomID); err == nil {
+		respondExistingGame(w, req, record.Players, StatusFinished)
+		return
 	}
 
-	gamesMu.Lock()
-	games[req.RoomID] = game
-	gamesMu.Unlock()
+	// Create game session
+	game, created := games.getOrCreate(req.RoomID, func() *Game {
+		return newGame(req.RoomID, req.Players, config, realClock{})
+	})
+	if !created {
+		game.mu.Lock()
+		players, status := game.Players, game.Status
+		game.mu.Unlock()
+		respondExistingGame(w, req, players, status)
+		return
+	}
 
 	log.Printf("Game created for room %s (waiting for WebSocket connections)", req.RoomID)
 	log.Printf("Players: %s vs %s", req.Players[0], req.Players[1])
+	log.Printf("Rules: %s mode, %d rounds, %dms per round, %s on wrong answers, %s scoring",
+		config.Mode, config.Rounds, config.RoundTimeoutMs, config.WrongAnswer, config.Scoring)
 
 	// Send response
 	w.Header().Set("Content-Type", "application/json")
@@ -179,32 +285,110 @@ func startGameHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(StartGameResponse{
 		RoomID:  req.RoomID,
 		Message: "Game created",
-		Status:  "waiting_for_players",
+		Status:  StatusWaiting,
 	})
 }
 
+// respondExistingGame answers a repeated start request for a room that already has a game
+func respondExistingGame(w http.ResponseWriter, req StartGameRequest, players []string, status string) {
+	if !slices.Equal(players, req.Players) {
+		log.Printf("Rejected start for room %s: a game with other players exists", req.RoomID)
+		http.Error(w, "A game with different players already exists for this room", http.StatusConflict)
+		return
+	}
+
+	log.Printf("Game for room %s already exists (%s), not recreating", req.RoomID, status)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(StartGameResponse{
+		RoomID:  req.RoomID,
+		Message: "Game already exists",
+		Status:  status,
+	})
+}
+
+// wsBearerProtocol is the subprotocol browsers use to send their JWT,
+// since the WebSocket API can't set an Authorization header:
+//
+//	new WebSocket(url, ["colorsync.bearer", token])
+const wsBearerProtocol = "colorsync.bearer"
+
+// authenticateWebSocket extracts and verifies the user JWT from a WebSocket upgrade request
+// Accepts "Authorization: Bearer <token>" or the colorsync.bearer subprotocol
+// Returns the response header to pass to Upgrade (echoes the subprotocol when used)
+func authenticateWebSocket(r *http.Request) (*auth.UserClaims, http.Header, error) {
+	var tokenString string
+	var responseHeader http.Header
+
+	if authHeader := r.Header.Get("Authorization"); authHeader != "" {
+		tokenString = strings.TrimPrefix(authHeader, "Bearer ")
+		if tokenString == authHeader {
+			return nil, nil, fmt.Errorf("invalid authorization format")
+		}
+	} else {
+		protocols := websocket.Subprotocols(r)
+		if len(protocols) == 2 && protocols[0] == wsBearerProtocol {
+			tokenString = protocols[1]
+			responseHeader = http.Header{}
+			responseHeader.Set("Sec-WebSocket-Protocol", wsBearerProtocol) // Canonical key, Upgrade looks it up with Get
+		}
+	}
+
+	if tokenString == "" {
+		return nil, nil, fmt.Errorf("missing authorization token")
+	}
+
+	claims, err := auth.VerifyUserToken(tokenString)
+	if err != nil {
+		return nil, nil, err
+	}
+	if auth.IsTokenRevoked(claims.ID) {
+		return nil, nil, fmt.Errorf("token has been revoked")
+	}
+	return claims, responseHeader, nil
+}
+
 func wsHandler(w http.ResponseWriter, r *http.Request) {
-	// Get room_id and user_id from query params
+	// Get room_id from query params
 	roomID := r.URL.Query().Get("room_id")
-	userID := r.URL.Query().Get("user_id")
+	if roomID == "" {
+		http.Error(w, "room_id required", http.StatusBadRequest)
+		return
+	}
+
+	// Authenticate before upgrading - the player is whoever the token says
+	claims, responseHeader, err := authenticateWebSocket(r)
+	if err != nil {
+		log.Printf("WebSocket auth failed for room %s: %v", roomID, err)
+		http.Error(w, "Invalid or missing token", http.StatusUnauthorized)
+		return
+	}
+	userID := claims.UserID
 
-	if roomID == "" || userID == "" {
-		http.Error(w, "room_id and user_id required", http.StatusBadRequest)
+	// user_id is optional now, but must not disagree with the token
+	if queryUserID := r.URL.Query().Get("user_id"); queryUserID != "" && queryUserID != userID {
+		log.Printf("User %s attempted to connect as %s", userID, queryUserID)
+		http.Error(w, "User ID mismatch", http.StatusForbidden)
 		return
 	}
 
 	// Find game
-	gamesMu.RLock()
-	game, exists := games[roomID]
-	gamesMu.RUnlock()
+	game, exists := games.get(roomID)
 
 	if !exists {
 		http.Error(w, "Game not found", http.StatusNotFound)
 		return
 	}
 
+	// Only the two matched players may join
+	if !slices.Contains(game.Players, userID) {
+		log.Printf("User %s is not a player in room %s", userID, roomID)
+		http.Error(w, "Not a player in this game", http.StatusForbidden)
+		return
+	}
+
 	// Upgrade HTTP connection to WebSocket
-	conn, err := upgrader.Upgrade(w, r, nil)
+	ws, err := upgrader.Upgrade(w, r, responseHeader)
 	if err != nil {
 		log.Printf("WebSocket upgrade failed: %v", err)
 		return
@@ -213,11 +397,11 @@ func wsHandler(w http.ResponseWriter, r *http.Request) {
 	// Register player connection
 	game.mu.Lock()
 
-	// Check if game already started or finished
-	if game.Status == "in_progress" || game.Status == "finished" {
+	// Check if game is already over (a running game can be rejoined)
+	if game.Status != StatusWaiting && game.Status != StatusInProgress {
 		log.Printf("Player %s tried to connect but game is %s", userID, game.Status)
 		game.mu.Unlock()
-		conn.Close()
+		ws.Close()
 		return
 	}
 
@@ -226,19 +410,31 @@ func wsHandler(w http.ResponseWriter, r *http.Request) {
 		oldConn.Close()
 	}
 
+	conn := newPlayerConn(userID, ws)
 	game.Connections[userID] = conn
 	game.disconnected[userID] = false // Mark as connected
+	delete(game.droppedAt, userID)
 	connCount := len(game.Connections)
 
+	// Rejoining a running game - catch the player up, then carry on
+	if game.Status == StatusInProgress {
+		log.Printf("Player %s reconnected to game %s", userID, game.RoomID)
+		conn.Send(WSMessage{Type: "STATE_SYNC", Payload: stateSnapshotLocked(game, userID)})
+		game.mu.Unlock()
+		notifyPresence(game, userID, true)
+		go handlePlayerMessages(game, userID, conn)
+		return
+	}
+
 	log.Printf("Player %s connected via WebSocket (%d/2)", userID, connCount)
 
 	// Start game only if BOTH players connected and game not started yet
-	shouldStart := connCount == 2 && game.Status == "waiting_for_players"
+	shouldStart := connCount == 2 && setStatusLocked(game, StatusInProgress)
 
 	if shouldStart {
-		game.Status = "in_progress"
 		game.mu.Unlock()
 		log.Printf("Both players ready! Starting game...")
+		notifyRoomService(game.RoomID, lifecycleEvent{Event: LifecycleGameStarted})
 		go runGame(game)
 	} else {
 		game.mu.Unlock()
@@ -248,18 +444,24 @@ func wsHandler(w http.ResponseWriter, r *http.Request) {
 	go handlePlayerMessages(game, userID, conn)
 }
 
-func handlePlayerMessages(game *Game, userID string, conn *websocket.Conn) {
+func handlePlayerMessages(game *Game, userID string, conn *PlayerConn) {
 	defer func() {
-		// Mark player as disconnected
+		conn.Close()
+
+		// Mark player as disconnected, unless they already reconnected on a new connection
 		game.mu.Lock()
+		if game.Connections[userID] != conn {
+			game.mu.Unlock()
+			log.Printf("Player %s replaced their connection", userID)
+			return
+		}
 		game.disconnected[userID] = true
 		game.mu.Unlock()
 
-		conn.Close()
 		log.Printf("Player %s disconnected", userID)
 
 		// Check if game should end due to disconnection
-		checkDisconnection(game)
+		checkDisconnection(game, userID)
 	}()
 
 	for {
@@ -275,10 +477,15 @@ func handlePlayerMessages(game *Game, userID string, conn *websocket.Conn) {
 		// Handle different message types
 		switch msg.Type {
 		case "CLICK":
-			handleClick(game, userID, msg.Payload)
+			answer, ok := msg.Payload["answer"].(string)
+			if !ok {
+				log.Printf("Invalid answer from player %s", userID)
+				continue
+			}
+			submitClick(game, userID, answer)
 		case "PING":
 			// Heartbeat message
-			conn.WriteJSON(WSMessage{Type: "PONG", Payload: map[string]interface{}{}})
+			conn.Send(WSMessage{Type: "PONG", Payload: map[string]interface{}{}})
 		default:
 			log.Printf("Unknown message type from player %s: %s", userID, msg.Type)
 		}
@@ -286,130 +493,142 @@ func handlePlayerMessages(game *Game, userID string, conn *websocket.Conn) {
 }
 
 // Check if game should end due to player disconnection
-func checkDisconnection(game *Game) {
+func checkDisconnection(game *Game, playerID string) {
 	game.mu.Lock()
-	defer game.mu.Unlock()
 
-	// Only handle if game is in progress
-	if game.Status != "in_progress" {
+	// A running game is up to its engine: wait for the player to come back, or forfeit
+	if game.Status == StatusInProgress {
+		game.mu.Unlock()
+		notifyPresence(game, playerID, false)
 		return
 	}
 
-	// Check if any player is disconnected
-	for playerID, disconnected := range game.disconnected {
-		if disconnected {
-			log.Printf("Player %s disconnected during game - ending game", playerID)
-
-			// Find the other player (winner by default)
-			var winner string
-			for _, pid := range game.Players {
-				if pid != playerID {
-					winner = pid
-					break
-				}
-			}
-
-			// Mark game as finished
-			game.Status = "finished"
-
-			// Notify remaining player
-			if conn, exists := game.Connections[winner]; exists {
-				conn.WriteJSON(WSMessage{
-					Type: "GAME_OVER",
-					Payload: map[string]interface{}{
-						"reason":  "opponent_disconnected",
-						"winner":  winner,
-						"results": game.Results,
-					},
-				})
-
-				// Close after delay
-				time.AfterFunc(3*time.Second, func() {
-					conn.Close()
-				})
-			}
+	// Nobody left waiting for a game that never started - give the room back
+	if game.Status != StatusWaiting {
+		game.mu.Unlock()
+		return
+	}
+	for _, disconnected := range game.disconnected {
+		if !disconnected {
+			game.mu.Unlock()
 			return
 		}
 	}
+	setStatusLocked(game, StatusAborted)
+	game.mu.Unlock()
+
+	games.remove(game)
+	log.Printf("Game %s aborted - players left before it started", game.RoomID)
+	notifyRoomService(game.RoomID, lifecycleEvent{Event: LifecycleGameAborted, Reason: "players_left"})
+}
+
+// forfeitLocked ends the game in favour of the opponent of the player who left
+// Caller must hold game.mu
+func forfeitLocked(game *Game, loser string) {
+	winner := opponentOf(game, loser)
+
+	// Mark game as finished, leaving counts as a loss
+	if !setStatusLocked(game, StatusFinished) {
+		return
+	}
+	game.winner = winner
+	game.endReason = "opponent_disconnected"
+	game.paused = false
+	go reportMatchResult(game.RoomID, game.Players, winner, matchStatsLocked(game))
+	notifyRoomService(game.RoomID, lifecycleEvent{
+		Event:   LifecycleGameFinished,
+		Winner:  winner,
+		Reason:  "opponent_disconnected",
+		Results: slices.Clone(game.Results),
+	})
+
+	// Notify remaining player
+	if conn, exists := game.Connections[winner]; exists && !game.disconnected[winner] {
+		conn.Send(WSMessage{
+			Type: "GAME_OVER",
+			Payload: map[string]interface{}{
+				"reason":  "opponent_disconnected",
+				"winner":  winner,
+				"results": game.Results,
+				"seed":    game.Seed,
+			},
+		})
+
+		// Close after delay
+		time.AfterFunc(3*time.Second, func() {
+			conn.Close()
+		})
+	}
 }
 
+// runGame is the game engine: it plays every round, reacting to clicks and
+// players coming and going in between, until the game is over
 func runGame(game *Game) {
+	defer close(game.done) // Nothing handles game events any more
+	defer retireGame(game)
+
 	// Reset game state for new game
 	game.mu.Lock()
 	game.Results = []RoundResult{} // Clear previous results
 	game.CurrentRound = 0
+	game.roundFinished = true // No round is open until the first one starts
+	game.startedAt = game.clock.Now()
 	game.mu.Unlock()
 
-	// Send game start message
+	// Send game start message; the seed itself is only revealed in GAME_OVER
 	broadcast(game, WSMessage{
 		Type: "GAME_START",
 		Payload: map[string]interface{}{
-			"room_id":    game.RoomID,
-			"max_rounds": game.MaxRounds,
-			"players":    game.Players,
+			"room_id":         game.RoomID,
+			"max_rounds":      game.MaxRounds,
+			"players":         game.Players,
+			"config":          game.Config,
+			"instructions":    game.mode.Instructions(),
+			"seed_commitment": seedCommitment(game.Seed),
 		},
 	})
 
-	time.Sleep(2 * time.Second) // Give players time to get ready
+	// Give players time to get ready
+	if !waitFor(game, gameStartDelay, false) {
+		log.Printf("Game ended early due to disconnection")
+		return
+	}
 
 	// Run rounds
 	for round := 1; round <= game.MaxRounds; round++ {
-		// Check if anyone disconnected
 		game.mu.Lock()
-		anyDisconnected := false
-		for _, disconnected := range game.disconnected {
-			if disconnected {
-				anyDisconnected = true
-				break
-			}
-		}
+		game.CurrentRound = round
 		game.mu.Unlock()
 
-		if anyDisconnected {
+		// Pause between rounds (held while someone is reconnecting)
+		if !playRound(game, round) || !waitFor(game, game.Config.Pause(), false) {
 			log.Printf("Game ended early due to disconnection")
 			return
 		}
-
-		game.mu.Lock()
-		game.CurrentRound = round
-		game.mu.Unlock()
-
-		playRound(game, round)
-		time.Sleep(3 * time.Second) // Pause between rounds
 	}
 
 	// Calculate final stats
-	stats := make(map[string]map[string]interface{})
-	for _, playerID := range game.Players {
-		wins := 0
-		totalLatency := int64(0)
-
-		for _, result := range game.Results {
-			if result.Winner == playerID {
-				wins++
-				totalLatency += result.Latency
-			}
-		}
-
-		avgLatency := int64(0)
-		if wins > 0 {
-			avgLatency = totalLatency / int64(wins)
-		}
-
-		stats[playerID] = map[string]interface{}{
-			"wins":          wins,
-			"total_latency": totalLatency,
-			"avg_latency":   avgLatency,
-		}
-	}
+	stats := gameStats(game)
 
 	winner := determineWinner(game)
 
 	// Mark game as finished
 	game.mu.Lock()
-	game.Status = "finished"
+	setStatusLocked(game, StatusFinished)
+	game.winner = winner
+	game.endReason = "game_completed"
+	playerStats := matchStatsLocked(game)
 	game.mu.Unlock()
 
+	go reportMatchResult(game.RoomID, game.Players, winner, playerStats)
+	notifyRoomService(game.RoomID, lifecycleEvent{
+		Event:   LifecycleGameFinished,
+		Winner:  winner,
+		Reason:  "game_completed",
+		Results: game.Results,
+		Stats:   stats,
+	})
+
 	// Game over
 	broadcast(game, WSMessage{
 		Type: "GAME_OVER",
@@ -418,240 +637,396 @@ func runGame(game *Game) {
 			"results": game.Results,
 			"winner":  winner,
 			"stats":   stats,
+			"seed":    game.Seed,
 		},
 	})
 
 	log.Printf("Game finished")
 
-	// Cleanup after delay
-	time.Sleep(5 * time.Second)
+	// Hang up after a delay, so GAME_OVER gets through
+	time.AfterFunc(5*time.Second, func() {
+		game.mu.Lock()
+		defer game.mu.Unlock()
 
-	game.mu.Lock()
-	for _, conn := range game.Connections {
-		conn.Close()
+		for _, conn := range game.Connections {
+			conn.Close()
+		}
+		log.Printf("Room %s closed", game.RoomID)
+	})
+}
+
+// retireGame moves a game that is over from the registry to the history
+// A game that could not be saved stays in the registry, so its result isn't lost
+func retireGame(game *Game) {
+	if err := archiveGame(game); err != nil {
+		log.Printf("Failed to save game %s to history, keeping it in memory: %v", game.RoomID, err)
+		return
 	}
-	game.Status = "completed"
-	log.Printf("Room %s completed and closed", game.RoomID)
-	game.mu.Unlock()
+	games.remove(game)
 }
 
-func playRound(game *Game, roundNum int) {
+// playRound plays one round to the end
+// Returns false if the game ended during the round
+func playRound(game *Game, roundNum int) bool {
 	game.mu.Lock()
-
-	words := []string{"RED", "BLUE", "GREEN", "YELLOW"}
-	colors := []string{"red", "blue", "green", "yellow"}
-
-	// Create a new rand source with current time
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-
-	word := words[r.Intn(len(words))]
-	color := colors[r.Intn(len(colors))]
-
-	log.Printf("🎨 Round %d: Word='%s' Color='%s'", roundNum, word, color) // ← DEBUG
-
-	game.currentWord = word
-	game.currentColor = color
-	game.roundStartTime = time.Now()
-	game.roundAnswered = false
-	game.roundFinished = false
-	game.roundWinner = ""
-	game.wrongAnswers = make(map[string]bool)
-
+	trial := startRoundLocked(game, roundNum)
 	game.mu.Unlock()
 
-	log.Printf("Round %d: Word='%s', Color='%s'", roundNum, word, color)
+	log.Printf("Round %d: Word='%s', Color='%s', Answer='%s'", roundNum, trial.Word, trial.Color, trial.Answer)
 
 	// Broadcast round start
 	broadcast(game, WSMessage{
 		Type: "ROUND_START",
 		Payload: map[string]interface{}{
-			"round": roundNum,
-			"word":  word,
-			"color": color,
+			"round":      roundNum,
+			"word":       trial.Word,
+			"color":      trial.Color,
+			"options":    trial.Options,
+			"timeout_ms": trial.Timeout.Milliseconds(),
 		},
 	})
 
-	// Wait for first correct answer (max 5 seconds)
-	timeout := time.After(5 * time.Second)
-	ticker := time.NewTicker(100 * time.Millisecond)
-	defer ticker.Stop()
+	// Wait for first correct answer, or for everyone with points scoring (max one round timeout)
+	if !waitFor(game, trial.Timeout, true) {
+		game.mu.Lock()
+		game.roundFinished = true // Ended while paused - nobody came back in time
+		game.mu.Unlock()
+		return false
+	}
 
-	for {
-		select {
-		case <-timeout:
-			// Time's up, no one answered correctly
-			game.mu.Lock()
-			if !game.roundAnswered {
-				log.Printf("Round %d timed out - no correct answer", roundNum)
-				game.roundWinner = "timeout"
-			}
-			game.roundFinished = true // LOCK round - no more clicks!
-			game.mu.Unlock()
-			goto RoundEnd
+	game.mu.Lock()
+	result := finishRoundLocked(game, roundNum)
+	game.mu.Unlock()
 
-		case <-ticker.C:
-			// Check if round has been answered
-			game.mu.Lock()
-			answered := game.roundAnswered
-			game.mu.Unlock()
+	// Broadcast round result
+	payload := map[string]interface{}{
+		"round":      roundNum,
+		"winner":     result.Winner,
+		"latency_ms": result.Latency,
+	}
+	if result.Points != nil {
+		payload["points"] = result.Points
+	}
+	broadcast(game, WSMessage{Type: "ROUND_RESULT", Payload: payload})
+	return true
+}
 
-			if answered {
-				game.mu.Lock()
-				game.roundFinished = true // LOCK round - no more clicks!
-				game.mu.Unlock()
-				goto RoundEnd
-			}
-		}
+// startRoundLocked deals the round's trial from the game's seed and opens it for answers
+// Caller must hold game.mu
+func startRoundLocked(game *Game, roundNum int) Trial {
+	trial := game.mode.NextTrial(game.Config, roundNum, roundRand(game.Seed, roundNum))
+
+	game.trial = trial
+	game.roundStartTime = game.clock.Now()
+	game.roundAnswered = false
+	game.roundFinished = false
+	game.roundWinner = ""
+	game.roundLatency = 0
+	game.wrongAnswers = make(map[string]int)
+	game.roundCorrect = make(map[string]bool)
+	game.roundPoints = make(map[string]int)
+	return trial
+}
+
+// finishRoundLocked closes the round to answers and stores its result
+// Caller must hold game.mu
+func finishRoundLocked(game *Game, roundNum int) RoundResult {
+	game.roundFinished = true // LOCK round - no more clicks!
+	if !game.roundAnswered && !roundOverLocked(game) {
+		// Time's up, no one answered correctly
+		log.Printf("Round %d timed out - no correct answer", roundNum)
+		game.roundWinner = "timeout"
 	}
 
-RoundEnd:
-	// Store result
-	game.mu.Lock()
 	result := RoundResult{
-		Round:   roundNum,
-		Word:    game.currentWord,
-		Color:   game.currentColor,
-		Winner:  game.roundWinner,
-		Latency: game.roundLatency,
+		Round:     roundNum,
+		Word:      game.trial.Word,
+		Color:     game.trial.Color,
+		Answer:    game.trial.Answer,
+		Congruent: game.trial.Congruent,
+		Winner:    game.roundWinner,
+		Latency:   game.roundLatency,
+	}
+	if len(game.wrongAnswers) > 0 {
+		result.Wrong = game.wrongAnswers
+	}
+	if usesPoints(game) {
+		result.Points = make(map[string]int)
+		for _, playerID := range game.Players {
+			result.Points[playerID] = game.roundPoints[playerID]
+		}
 	}
 	game.Results = append(game.Results, result)
-	game.mu.Unlock()
-
-	// Broadcast round result
-	broadcast(game, WSMessage{
-		Type: "ROUND_RESULT",
-		Payload: map[string]interface{}{
-			"round":      roundNum,
-			"winner":     result.Winner,
-			"latency_ms": result.Latency,
-		},
-	})
+	return result
 }
 
-func handleClick(game *Game, userID string, payload map[string]interface{}) {
+func handleClick(game *Game, click playerClick) {
 	game.mu.Lock()
 	defer game.mu.Unlock()
 
+	userID, answer := click.playerID, click.answer
+
+	// Calculate latency, from when the click arrived (0 before the first round)
+	var latency int64
+	if !game.roundStartTime.IsZero() {
+		latency = max(click.at.Sub(game.roundStartTime), 0).Milliseconds()
+	}
+
+	// Clicks that are turned away are recorded too, so the timeline has every click
+	reject := func(reason string) {
+		game.timeline = append(game.timeline, ClickRecord{
+			Round:    game.CurrentRound,
+			PlayerID: userID,
+			Answer:   answer,
+			Rejected: reason,
+			Latency:  latency,
+			At:       click.at,
+		})
+	}
+
 	// Check if round is over
 	if game.roundFinished {
 		log.Printf("Player %s clicked but round already finished", userID)
+		reject(ClickRoundClosed)
 		return
 	}
 
-	// Check if round already answered correctly
-	if game.roundAnswered {
-		log.Printf("Player %s clicked but round already won by someone else", userID)
+	// No answers while the game waits for a player to reconnect
+	if game.paused {
+		log.Printf("Player %s clicked while game is paused", userID)
+		reject(ClickPaused)
+		if conn, exists := game.Connections[userID]; exists {
+			conn.Send(WSMessage{
+				Type: "ROUND_FEEDBACK",
+				Payload: map[string]interface{}{
+					"message": "Game paused - answer again once your opponent is back.",
+					"retry":   true,
+				},
+			})
+		}
 		return
 	}
 
-	// Check if this player already got it wrong this round
-	if game.wrongAnswers[userID] {
-		log.Printf("Player %s BLOCKED. Already answered wrong this round", userID)
+	// Check if round already answered correctly (with points, only this player's answer counts)
+	if usesPoints(game) && game.roundCorrect[userID] {
+		log.Printf("Player %s clicked but already answered correctly", userID)
+		reject(ClickAlreadyAnswered)
 		return
 	}
-
-	// Get player's answer
-	answer, ok := payload["answer"].(string)
-	if !ok {
-		log.Printf("Invalid answer from player %s", userID)
+	if !usesPoints(game) && game.roundAnswered {
+		log.Printf("Player %s clicked but round already won by someone else", userID)
+		reject(ClickAlreadyAnswered)
 		return
 	}
 
-	// Calculate latency
-	latency := time.Since(game.roundStartTime).Milliseconds()
+	// Check if this player already got it wrong this round
+	if lockedOutLocked(game, userID) {
+		log.Printf("Player %s BLOCKED. Already answered wrong this round", userID)
+		reject(ClickLockedOut)
+		return
+	}
 
-	// Check if answer is correct (must match the COLOR, not the word!)
-	correctAnswer := game.currentColor
+	// Check if answer is correct (what counts depends on the game mode)
+	correctAnswer := game.trial.Answer
 
 	log.Printf("Player %s clicked '%s' (correct: '%s') - %dms",
 		userID, answer, correctAnswer, latency)
+	game.timeline = append(game.timeline, ClickRecord{
+		Round:    game.CurrentRound,
+		PlayerID: userID,
+		Answer:   answer,
+		Correct:  answer == correctAnswer,
+		Latency:  latency,
+		At:       click.at,
+	})
 
 	if answer == correctAnswer {
-		// Correct answer!
-		game.roundAnswered = true
-		game.roundWinner = userID
-		game.roundLatency = latency
+		// Correct answer! The first one still wins the round
+		if !game.roundAnswered {
+			game.roundAnswered = true
+			game.roundWinner = userID
+			game.roundLatency = latency
+		}
+		if usesPoints(game) {
+			game.roundCorrect[userID] = true
+			game.roundPoints[userID] += pointsFor(time.Duration(latency)*time.Millisecond, game.trial.Timeout)
+		}
 		log.Printf("Player %s correct in %dms", userID, latency)
 	} else {
-		// WRONG - block this player from trying again
-		game.wrongAnswers[userID] = true
-		log.Printf("Player %s WRONG (blocked for this round)", userID)
+		// WRONG - what happens next depends on the wrong-answer policy
+		game.wrongAnswers[userID]++
+		message := "Wrong answer! Blocked for this round."
+		retry := false
+
+		if usesPoints(game) {
+			game.roundPoints[userID] -= wrongAnswerPenalty
+		}
+
+		switch {
+		case game.Config.WrongAnswer == gameconfig.WrongAnswerRetry:
+			message = "Wrong answer! Try again."
+			retry = true
+			log.Printf("Player %s WRONG (may retry)", userID)
+		case game.Config.WrongAnswer == gameconfig.WrongAnswerForfeit && !usesPoints(game):
+			message = "Wrong answer! The round goes to your opponent."
+			game.roundAnswered = true
+			game.roundWinner = opponentOf(game, userID)
+			game.roundLatency = latency
+			log.Printf("Player %s WRONG (round forfeited)", userID)
+		default:
+			log.Printf("Player %s WRONG (blocked for this round)", userID)
+		}
 
 		// Send feedback to client
 		if conn, exists := game.Connections[userID]; exists {
-			conn.WriteJSON(WSMessage{
+			conn.Send(WSMessage{
 				Type: "ROUND_FEEDBACK",
 				Payload: map[string]interface{}{
-					"message": "Wrong answer! Blocked for this round.",
+					"message": message,
+					"retry":   retry,
 				},
 			})
 		}
 	}
 }
 
+// opponentOf returns the other player of the game
+func opponentOf(game *Game, userID string) string {
+	for _, playerID := range game.Players {
+		if playerID != userID {
+			return playerID
+		}
+	}
+	return ""
+}
+
 func broadcast(game *Game, msg WSMessage) {
 	game.mu.Lock()
 	defer game.mu.Unlock()
 
-	for _, conn := range game.Connections {
-		conn.WriteJSON(msg)
-	}
+	broadcastLocked(game, msg)
 }
 
-func determineWinner(game *Game) string {
-	// Count wins and total latency per player
-	type PlayerStats struct {
-		Wins         int
-		TotalLatency int64
+// broadcastLocked sends msg to every connected player
+// Caller must hold game.mu
+func broadcastLocked(game *Game, msg WSMessage) {
+	for playerID, conn := range game.Connections {
+		if !game.disconnected[playerID] {
+			conn.Send(msg)
+		}
 	}
+}
+
+// PlayerScore is one player's tally at the end of a game
+type PlayerScore struct {
+	Wins         int
+	WrongAnswers int
+	Score        int // What the winner is decided on, depends on the scoring mode
+	TotalLatency int64
+	RoundPoints  []int // Points earned each round (points scoring)
+}
 
-	stats := make(map[string]*PlayerStats)
+// scoreGame tallies the round results under the game's scoring mode
+func scoreGame(game *Game) map[string]*PlayerScore {
+	scores := make(map[string]*PlayerScore)
 
-	// Initialize stats for both players
+	// Initialize scores for both players
 	for _, playerID := range game.Players {
-		stats[playerID] = &PlayerStats{
-			Wins:         0,
-			TotalLatency: 0,
-		}
+		scores[playerID] = &PlayerScore{}
 	}
 
-	// Calculate stats from results
+	// Calculate scores from results
 	for _, result := range game.Results {
-		if result.Winner != "" && result.Winner != "timeout" {
-			stats[result.Winner].Wins++
-			stats[result.Winner].TotalLatency += result.Latency
+		if score, exists := scores[result.Winner]; exists {
+			score.Wins++
+			score.TotalLatency += result.Latency
+		}
+		for playerID, wrong := range result.Wrong {
+			if score, exists := scores[playerID]; exists {
+				score.WrongAnswers += wrong
+			}
+		}
+		if usesPoints(game) {
+			for playerID, score := range scores {
+				score.RoundPoints = append(score.RoundPoints, result.Points[playerID])
+			}
 		}
 	}
 
-	// Find winner by wins first, then by latency
-	var winner string
-	maxWins := 0
-	lowestLatency := int64(9999999999)
-
-	for playerID, playerStats := range stats {
-		// Primary: Most wins
-		if playerStats.Wins > maxWins {
-			maxWins = playerStats.Wins
-			lowestLatency = playerStats.TotalLatency
-			winner = playerID
-		} else if playerStats.Wins == maxWins && playerStats.Wins > 0 {
-			// Tiebreaker: lowest latency
-			if playerStats.TotalLatency < lowestLatency {
-				lowestLatency = playerStats.TotalLatency
-				winner = playerID
+	for _, score := range scores {
+		switch game.Config.Scoring {
+		case gameconfig.ScoringNet:
+			score.Score = score.Wins - score.WrongAnswers
+		case gameconfig.ScoringPoints:
+			for _, points := range score.RoundPoints {
+				score.Score += points
 			}
+		default:
+			score.Score = score.Wins
 		}
 	}
+	return scores
+}
+
+// gameStats is the per-player breakdown sent with GAME_OVER
+func gameStats(game *Game) map[string]map[string]interface{} {
+	stats := make(map[string]map[string]interface{})
+	for playerID, score := range scoreGame(game) {
+		avgLatency := int64(0)
+		if score.Wins > 0 {
+			avgLatency = score.TotalLatency / int64(score.Wins)
+		}
+
+		stats[playerID] = map[string]interface{}{
+			"wins":          score.Wins,
+			"wrong_answers": score.WrongAnswers,
+			"score":         score.Score,
+			"total_latency": score.TotalLatency,
+			"avg_latency":   avgLatency,
+		}
+		if usesPoints(game) {
+			stats[playerID]["round_points"] = score.RoundPoints
+		}
+
+		// Mode-specific breakdown, e.g. congruent vs incongruent trials
+		if extra, ok := game.mode.(modeStats); ok {
+			maps.Copy(stats[playerID], extra.PlayerStats(game.Results, playerID))
+		}
+	}
+	return stats
+}
+
+func determineWinner(game *Game) string {
+	scores := scoreGame(game)
 
 	// Log the decision
-	log.Printf("Final Scores:")
-	for playerID, playerStats := range stats {
-		log.Printf("- Player %s: %d wins, %dms total latency", playerID, playerStats.Wins, playerStats.TotalLatency)
+	log.Printf("Final Scores (%s scoring):", game.Config.Scoring)
+	for playerID, score := range scores {
+		log.Printf("- Player %s: score %d (%d wins, %d wrong), %dms total latency",
+			playerID, score.Score, score.Wins, score.WrongAnswers, score.TotalLatency)
+	}
+
+	// Find winner by score first, then by latency
+	a, b := game.Players[0], game.Players[1]
+	var winner string
+	switch {
+	case scores[a].Score != scores[b].Score:
+		winner = a
+		if scores[b].Score > scores[a].Score {
+			winner = b
+		}
+	case scores[a].Wins == 0 && scores[b].Wins == 0:
+		// No one won any rounds - nothing to break the tie on
+	case scores[a].TotalLatency != scores[b].TotalLatency:
+		// Tiebreaker: lowest latency
+		winner = a
+		if scores[b].TotalLatency < scores[a].TotalLatency {
+			winner = b
+		}
 	}
 
-	// If no one won any rounds, it's a draw
 	if winner == "" {
-		log.Printf("Result: DRAW (0-0)")
+		log.Printf("Result: DRAW")
 		return "draw"
 	}
 
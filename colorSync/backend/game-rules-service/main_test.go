@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/Flokots/programming-5/colorSync/shared/auth"
+)
+
+func TestAuthenticateWebSocket(t *testing.T) {
+	t.Setenv("USER_JWT_PRIVATE_KEY_FILE", filepath.Join(t.TempDir(), "user.pem"))
+	if err := auth.UseUserSigningKeys(); err != nil {
+		t.Fatalf("UseUserSigningKeys: %v", err)
+	}
+	valid, err := auth.IssueUserToken("user-1", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked, err := auth.IssueUserToken("user-1", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth.RevokeToken(revoked.ID, revoked.ExpiresAt)
+
+	tests := []struct {
+		name          string
+		authorization string
+		protocols     string // Sec-WebSocket-Protocol
+		wantOK        bool
+		wantEcho      bool // Response names the bearer subprotocol
+	}{
+		{"bearer header", "Bearer " + valid.Token, "", true, false},
+		{"bearer subprotocol", "", wsBearerProtocol + ", " + valid.Token, true, true},
+		{"header without Bearer", valid.Token, "", false, false},
+		{"header wins over subprotocol", "Bearer not-a-jwt", wsBearerProtocol + ", " + valid.Token, false, false},
+		{"other subprotocol", "", "chat, " + valid.Token, false, false},
+		{"subprotocol without token", "", wsBearerProtocol, false, false},
+		{"nothing", "", "", false, false},
+		{"garbage token", "Bearer not-a-jwt", "", false, false},
+		{"revoked token", "", wsBearerProtocol + ", " + revoked.Token, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/ws?room_id=room-1", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			if tt.protocols != "" {
+				r.Header.Set("Sec-WebSocket-Protocol", tt.protocols)
+			}
+
+			claims, header, err := authenticateWebSocket(r)
+			if !tt.wantOK {
+				if err == nil {
+					t.Fatalf("accepted as %+v, want an error", claims)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("authenticateWebSocket: %v", err)
+			}
+			if claims.UserID != "user-1" || claims.ID != valid.ID {
+				t.Fatalf("claims = %+v, want user-1 from token %s", claims, valid.ID)
+			}
+			if echoed := header.Get("Sec-WebSocket-Protocol") == wsBearerProtocol; echoed != tt.wantEcho {
+				t.Fatalf("response header %v, want subprotocol echoed=%t", header, tt.wantEcho)
+			}
+		})
+	}
+}
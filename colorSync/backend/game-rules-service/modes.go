@@ -0,0 +1,224 @@
+package main
+
+import (
+	"math/rand"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Flokots/programming-5/colorSync/shared/gameconfig"
+)
+
+// Answers in match mode
+const (
+	AnswerMatch   = "match"
+	AnswerNoMatch = "no_match"
+)
+
+// Trial is what players see in one round and the answer that wins it
+type Trial struct {
+	Word      string
+	Color     string // Ink the word is shown in
+	Answer    string
+	Options   []string // Answers players can pick from
+	Congruent bool     // Word and ink agree
+	Timeout   time.Duration
+}
+
+// GameMode decides what players are asked each round
+// Modes must be safe for concurrent use; per-game state lives in Game
+type GameMode interface {
+	// Instructions shown to players before the first round
+	Instructions() string
+
+	// NextTrial builds the trial for round (1-based)
+	NextTrial(config gameconfig.Config, round int, r *rand.Rand) Trial
+}
+
+// modeStats is implemented by modes that report more than the common stats
+type modeStats interface {
+	PlayerStats(results []RoundResult, playerID string) map[string]interface{}
+}
+
+// gameModes maps gameconfig mode names to their implementation
+var gameModes = map[string]GameMode{
+	gameconfig.ModeClassic:    classicMode{},
+	gameconfig.ModeReverse:    reverseMode{},
+	gameconfig.ModeMixed:      mixedMode{},
+	gameconfig.ModeMatch:      matchMode{},
+	gameconfig.ModeEscalating: escalatingMode{},
+}
+
+// modeFor returns the implementation of the configured mode
+func modeFor(config gameconfig.Config) GameMode {
+	if mode, exists := gameModes[config.Mode]; exists {
+		return mode
+	}
+	return classicMode{}
+}
+
+// stroopTrial picks a word and an ink from palette independently
+func stroopTrial(palette []string, r *rand.Rand) (word, color string) {
+	return gameconfig.Word(palette[r.Intn(len(palette))]), palette[r.Intn(len(palette))]
+}
+
+// congruenceTrial picks a word and an ink that agree or differ with equal odds
+func congruenceTrial(palette []string, r *rand.Rand) (word, color string, congruent bool) {
+	color = palette[r.Intn(len(palette))]
+	if r.Intn(2) == 0 {
+		return gameconfig.Word(color), color, true
+	}
+
+	// Any other color for the word
+	other := palette[r.Intn(len(palette)-1)]
+	if other == color {
+		other = palette[len(palette)-1]
+	}
+	return gameconfig.Word(other), color, false
+}
+
+// classicMode is the original game: name the ink, ignore the word
+type classicMode struct{}
+
+func (classicMode) Instructions() string {
+	return "Name the COLOR of the text (not the word!)"
+}
+
+func (classicMode) NextTrial(config gameconfig.Config, round int, r *rand.Rand) Trial {
+	word, color := stroopTrial(config.Palette, r)
+	return Trial{
+		Word:      word,
+		Color:     color,
+		Answer:    color,
+		Options:   config.Palette,
+		Congruent: word == gameconfig.Word(color),
+		Timeout:   config.RoundTimeout(),
+	}
+}
+
+// reverseMode asks for the word and ignores the ink
+type reverseMode struct{}
+
+func (reverseMode) Instructions() string {
+	return "Name the WORD (ignore its color!)"
+}
+
+func (reverseMode) NextTrial(config gameconfig.Config, round int, r *rand.Rand) Trial {
+	word, color := stroopTrial(config.Palette, r)
+	return Trial{
+		Word:      word,
+		Color:     color,
+		Answer:    strings.ToLower(word),
+		Options:   config.Palette,
+		Congruent: word == gameconfig.Word(color),
+		Timeout:   config.RoundTimeout(),
+	}
+}
+
+// mixedMode balances congruent and incongruent trials and reports them apart,
+// showing how much the conflicting word slows each player down
+// The split is stats only: the winner is decided by the configured scoring, as in classic
+type mixedMode struct{}
+
+func (mixedMode) Instructions() string {
+	return "Name the COLOR of the text - sometimes the word agrees, sometimes it doesn't"
+}
+
+func (mixedMode) NextTrial(config gameconfig.Config, round int, r *rand.Rand) Trial {
+	word, color, congruent := congruenceTrial(config.Palette, r)
+	return Trial{
+		Word:      word,
+		Color:     color,
+		Answer:    color,
+		Options:   config.Palette,
+		Congruent: congruent,
+		Timeout:   config.RoundTimeout(),
+	}
+}
+
+// PlayerStats splits round wins and latency by trial type, without changing the score
+// interference_ms is how much slower the player won incongruent rounds
+func (mixedMode) PlayerStats(results []RoundResult, playerID string) map[string]interface{} {
+	var congruentWins, incongruentWins int
+	var congruentLatency, incongruentLatency int64
+	for _, result := range results {
+		if result.Winner != playerID {
+			continue
+		}
+		if result.Congruent {
+			congruentWins++
+			congruentLatency += result.Latency
+		} else {
+			incongruentWins++
+			incongruentLatency += result.Latency
+		}
+	}
+
+	stats := map[string]interface{}{
+		"congruent_wins":   congruentWins,
+		"incongruent_wins": incongruentWins,
+	}
+	if congruentWins > 0 && incongruentWins > 0 {
+		stats["interference_ms"] = incongruentLatency/int64(incongruentWins) - congruentLatency/int64(congruentWins)
+	}
+	return stats
+}
+
+// matchMode asks whether word and ink agree
+type matchMode struct{}
+
+func (matchMode) Instructions() string {
+	return "Does the WORD match its COLOR? Answer match or no_match"
+}
+
+func (matchMode) NextTrial(config gameconfig.Config, round int, r *rand.Rand) Trial {
+	word, color, congruent := congruenceTrial(config.Palette, r)
+	answer := AnswerNoMatch
+	if congruent {
+		answer = AnswerMatch
+	}
+	return Trial{
+		Word:      word,
+		Color:     color,
+		Answer:    answer,
+		Options:   []string{AnswerMatch, AnswerNoMatch},
+		Congruent: congruent,
+		Timeout:   config.RoundTimeout(),
+	}
+}
+
+// Escalating mode shortens the timeout by this fraction every round, down to the minimum
+const escalatingTimeoutStep = 0.15
+
+// escalatingMode is classic with one more color and less time every round
+type escalatingMode struct{}
+
+func (escalatingMode) Instructions() string {
+	return "Name the COLOR of the text - every round adds a color and takes away time"
+}
+
+func (escalatingMode) NextTrial(config gameconfig.Config, round int, r *rand.Rand) Trial {
+	// Grow the palette with colors it doesn't have yet
+	palette := slices.Clone(config.Palette)
+	for _, color := range gameconfig.Colors {
+		if len(palette) >= len(config.Palette)+round-1 {
+			break
+		}
+		if !slices.Contains(palette, color) {
+			palette = append(palette, color)
+		}
+	}
+
+	timeout := time.Duration(float64(config.RoundTimeout()) * (1 - escalatingTimeoutStep*float64(round-1)))
+	timeout = max(timeout, gameconfig.MinRoundTimeout*time.Millisecond)
+
+	word, color := stroopTrial(palette, r)
+	return Trial{
+		Word:      word,
+		Color:     color,
+		Answer:    color,
+		Options:   palette,
+		Congruent: word == gameconfig.Word(color),
+		Timeout:   timeout,
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Flokots/programming-5/colorSync/shared/gameconfig"
+)
+
+func TestModeTrials(t *testing.T) {
+	tests := []struct {
+		mode string
+		// check reports what is wrong with a trial of round, or ""
+		check func(trial Trial, config gameconfig.Config, round int) string
+	}{
+		{gameconfig.ModeClassic, func(trial Trial, config gameconfig.Config, round int) string {
+			if trial.Answer != trial.Color {
+				return "answer is not the ink"
+			}
+			return ""
+		}},
+		{gameconfig.ModeReverse, func(trial Trial, config gameconfig.Config, round int) string {
+			if trial.Answer != strings.ToLower(trial.Word) {
+				return "answer is not the word"
+			}
+			return ""
+		}},
+		{gameconfig.ModeMixed, func(trial Trial, config gameconfig.Config, round int) string {
+			if trial.Answer != trial.Color {
+				return "answer is not the ink"
+			}
+			return ""
+		}},
+		{gameconfig.ModeMatch, func(trial Trial, config gameconfig.Config, round int) string {
+			if want := map[bool]string{true: AnswerMatch, false: AnswerNoMatch}[trial.Congruent]; trial.Answer != want {
+				return "answer doesn't say whether word and ink agree"
+			}
+			return ""
+		}},
+		{gameconfig.ModeEscalating, func(trial Trial, config gameconfig.Config, round int) string {
+			if len(trial.Options) != min(len(config.Palette)+round-1, len(gameconfig.Colors)) {
+				return "palette didn't grow by one color per round"
+			}
+			want := max(time.Duration(float64(config.RoundTimeout())*(1-escalatingTimeoutStep*float64(round-1))),
+				gameconfig.MinRoundTimeout*time.Millisecond)
+			if trial.Timeout != want {
+				return "timeout didn't shrink as expected"
+			}
+			return ""
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			config := gameconfig.Default()
+			config.Mode = tt.mode
+			mode := modeFor(config)
+			r := rand.New(rand.NewSource(1))
+
+			for round := 1; round <= gameconfig.MaxRounds; round++ {
+				trial := mode.NextTrial(config, round, r)
+
+				if trial.Congruent != (trial.Word == gameconfig.Word(trial.Color)) {
+					t.Fatalf("round %d: %s in %s marked congruent=%t", round, trial.Word, trial.Color, trial.Congruent)
+				}
+				if !slices.Contains(trial.Options, trial.Answer) {
+					t.Fatalf("round %d: answer %q is not one of %v", round, trial.Answer, trial.Options)
+				}
+				if problem := tt.check(trial, config, round); problem != "" {
+					t.Fatalf("round %d: %s: %+v", round, problem, trial)
+				}
+			}
+		})
+	}
+}
+
+func TestCongruenceTrialsAreBalanced(t *testing.T) {
+	config := gameconfig.Default()
+	r := rand.New(rand.NewSource(1))
+
+	const trials = 2000
+	congruent := 0
+	for range trials {
+		if _, _, c := congruenceTrial(config.Palette, r); c {
+			congruent++
+		}
+	}
+	if congruent < trials*45/100 || congruent > trials*55/100 {
+		t.Fatalf("%d of %d trials congruent, want about half", congruent, trials)
+	}
+}
+
+func TestModeForUnknownFallsBackToClassic(t *testing.T) {
+	config := gameconfig.Default()
+	config.Mode = "unknown"
+	if _, ok := modeFor(config).(classicMode); !ok {
+		t.Fatalf("modeFor(unknown) = %T, want classicMode", modeFor(config))
+	}
+}
+
+func TestMixedModeStats(t *testing.T) {
+	results := []RoundResult{
+		{Winner: "a", Congruent: true, Latency: 400},
+		{Winner: "a", Congruent: true, Latency: 600},
+		{Winner: "a", Congruent: false, Latency: 900},
+		{Winner: "b", Congruent: false, Latency: 700},
+		{Winner: "", Congruent: false},
+	}
+
+	tests := []struct {
+		playerID string
+		want     map[string]interface{}
+	}{
+		{"a", map[string]interface{}{"congruent_wins": 2, "incongruent_wins": 1, "interference_ms": int64(400)}},
+		{"b", map[string]interface{}{"congruent_wins": 0, "incongruent_wins": 1}}, // No congruent wins to compare with
+	}
+	for _, tt := range tests {
+		got := mixedMode{}.PlayerStats(results, tt.playerID)
+		if len(got) != len(tt.want) {
+			t.Fatalf("stats of %s = %v, want %v", tt.playerID, got, tt.want)
+		}
+		for key, want := range tt.want {
+			if got[key] != want {
+				t.Fatalf("stats of %s = %v, want %v", tt.playerID, got, tt.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"log"
+	"slices"
+	"time"
+)
+
+// A player who drops out of a running game pauses it for Config.ReconnectGrace.
+// Rejoining in time resumes play where it stopped, with a STATE_SYNC snapshot
+// for the returning player; otherwise they forfeit.
+
+// pauseForReconnectLocked starts the grace window for a player who dropped
+// Caller must hold game.mu
+func pauseForReconnectLocked(game *Game, playerID string) {
+	if _, waiting := game.droppedAt[playerID]; waiting {
+		return // Already counting down for this drop
+	}
+
+	now := game.clock.Now()
+	grace := game.Config.ReconnectGrace()
+	game.droppedAt[playerID] = now
+	if !game.paused {
+		game.paused = true
+		game.pausedAt = now
+	}
+
+	log.Printf("Player %s dropped from game %s - pausing for up to %s", playerID, game.RoomID, grace)
+	broadcastLocked(game, WSMessage{
+		Type: "GAME_PAUSED",
+		Payload: map[string]interface{}{
+			"player_id": playerID,
+			"grace_ms":  grace.Milliseconds(),
+			"resume_by": now.Add(grace),
+		},
+	})
+}
+
+// expireGraceLocked forfeits the game for a player whose grace period ran out
+// Caller must hold game.mu
+func expireGraceLocked(game *Game) {
+	now := game.clock.Now()
+	for playerID, droppedAt := range game.droppedAt {
+		if game.Status != StatusInProgress || now.Before(droppedAt.Add(game.Config.ReconnectGrace())) {
+			continue
+		}
+		log.Printf("Player %s did not reconnect to game %s in time", playerID, game.RoomID)
+		forfeitLocked(game, playerID)
+	}
+}
+
+// resumeIfCompleteLocked resumes a paused game once every player is back
+// The round clock is moved forward by the pause, so nobody loses time to it
+// Caller must hold game.mu
+func resumeIfCompleteLocked(game *Game) {
+	if !game.paused || slices.ContainsFunc(game.Players, func(playerID string) bool {
+		return game.disconnected[playerID]
+	}) {
+		return
+	}
+
+	paused := game.clock.Now().Sub(game.pausedAt)
+	game.paused = false
+	game.roundStartTime = game.roundStartTime.Add(paused)
+	game.deadline = game.deadline.Add(paused)
+
+	log.Printf("Game %s resumed after %s", game.RoomID, paused.Round(time.Millisecond))
+	broadcastLocked(game, WSMessage{
+		Type:    "GAME_RESUMED",
+		Payload: map[string]interface{}{"round": game.CurrentRound},
+	})
+}
+
+// stateSnapshotLocked is the STATE_SYNC payload for a player rejoining the game
+// Caller must hold game.mu
+func stateSnapshotLocked(game *Game, playerID string) map[string]interface{} {
+	scores := make(map[string]map[string]interface{})
+	for id, score := range scoreGame(game) {
+		scores[id] = map[string]interface{}{
+			"wins":          score.Wins,
+			"wrong_answers": score.WrongAnswers,
+			"score":         score.Score,
+		}
+	}
+
+	snapshot := map[string]interface{}{
+		"room_id":      game.RoomID,
+		"players":      game.Players,
+		"max_rounds":   game.MaxRounds,
+		"config":       game.Config,
+		"instructions": game.mode.Instructions(),
+		"round":        game.CurrentRound,
+		"results":      game.Results,
+		"scores":       scores,
+		"paused":       game.paused,
+	}
+
+	// The round still running, if any, so the player can answer it
+	if game.CurrentRound > 0 && !game.roundFinished && game.trial.Word != "" {
+		remaining := game.deadline.Sub(game.clock.Now())
+		if game.paused {
+			remaining = game.deadline.Sub(game.pausedAt)
+		}
+
+		answered := game.roundCorrect[playerID] || (!usesPoints(game) && game.roundAnswered)
+		snapshot["current"] = map[string]interface{}{
+			"round":        game.CurrentRound,
+			"word":         game.trial.Word,
+			"color":        game.trial.Color,
+			"options":      game.trial.Options,
+			"remaining_ms": max(remaining.Milliseconds(), 0),
+			"can_answer":   !answered && !lockedOutLocked(game, playerID),
+		}
+	}
+	return snapshot
+}
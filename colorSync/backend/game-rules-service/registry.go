@@ -0,0 +1,145 @@
+package main
+
+import (
+	"log"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Game lifecycle: a game is created waiting for its players and starts once
+// both are connected. It ends finished, or aborted/expired if it never started.
+// Only games that are not over yet live in the registry; finished games are
+// dropped once they are in the history (see history.go).
+const (
+	StatusWaiting    = "waiting_for_players"
+	StatusInProgress = "in_progress"
+	StatusFinished   = "finished"
+	StatusAborted    = "aborted" // Both players left before it started
+	StatusExpired    = "expired" // Not both players connected in time
+)
+
+// gameTransitions lists the states each state may move to
+var gameTransitions = map[string][]string{
+	StatusWaiting:    {StatusInProgress, StatusAborted, StatusExpired},
+	StatusInProgress: {StatusFinished},
+}
+
+// setStatusLocked moves the game to the next state of its lifecycle
+// Returns false, changing nothing, if the game can't get there from where it is
+// Caller must hold game.mu
+func setStatusLocked(game *Game, status string) bool {
+	if !slices.Contains(gameTransitions[game.Status], status) {
+		log.Printf("Game %s can't go from %s to %s", game.RoomID, game.Status, status)
+		return false
+	}
+	game.Status = status
+	return true
+}
+
+// The reaper expires games that were created but never started
+const (
+	reapInterval = 5 * time.Second
+
+	// Both players must connect within this long of the game being created
+	connectTimeout = 60 * time.Second
+)
+
+// gameRegistry holds the games that are waiting or running, by room ID
+// Never hold its lock while taking a game's lock
+type gameRegistry struct {
+	games map[string]*Game
+	mu    sync.RWMutex
+}
+
+var games = newGameRegistry()
+
+func newGameRegistry() *gameRegistry {
+	return &gameRegistry{games: make(map[string]*Game)}
+}
+
+// get looks up the game of a room
+func (r *gameRegistry) get(roomID string) (*Game, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	game, exists := r.games[roomID]
+	return game, exists
+}
+
+// getOrCreate returns the room's game, adding the one create makes if there is none
+// created reports which of the two happened
+func (r *gameRegistry) getOrCreate(roomID string, create func() *Game) (game *Game, created bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, exists := r.games[roomID]; exists {
+		return existing, false
+	}
+	game = create()
+	r.games[roomID] = game
+	return game, true
+}
+
+// remove forgets a game, unless its room has moved on to another one
+func (r *gameRegistry) remove(game *Game) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.games[game.RoomID] == game {
+		delete(r.games, game.RoomID)
+	}
+}
+
+// list returns every game in the registry
+func (r *gameRegistry) list() []*Game {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list := make([]*Game, 0, len(r.games))
+	for _, game := range r.games {
+		list = append(list, game)
+	}
+	return list
+}
+
+// runReaper periodically expires games whose players never showed up
+func runReaper() {
+	ticker := time.NewTicker(reapInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		expireGames(now)
+	}
+}
+
+// expireGames does one reaper pass: every game still waiting for its players
+// connectTimeout after it was created is called off and its room closed
+func expireGames(now time.Time) {
+	for _, game := range games.list() {
+		game.mu.Lock()
+		if game.Status != StatusWaiting || now.Sub(game.createdAt) < connectTimeout {
+			game.mu.Unlock()
+			continue
+		}
+		setStatusLocked(game, StatusExpired)
+
+		// Tell whoever did turn up, then hang up on them after a delay
+		for playerID, conn := range game.Connections {
+			if game.disconnected[playerID] {
+				continue
+			}
+			conn.Send(WSMessage{
+				Type:    "ERROR",
+				Payload: map[string]interface{}{"message": "Your opponent never connected - the game was cancelled."},
+			})
+			time.AfterFunc(3*time.Second, func() {
+				conn.Close()
+			})
+		}
+		game.mu.Unlock()
+
+		games.remove(game)
+		log.Printf("Game %s expired - players did not connect within %s", game.RoomID, connectTimeout)
+		notifyRoomService(game.RoomID, lifecycleEvent{Event: LifecycleGameAborted, Reason: "connect_timeout"})
+	}
+}
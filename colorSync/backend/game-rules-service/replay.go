@@ -0,0 +1,269 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Every game has a seed, and each round's word and colour are dealt from a
+// generator derived from it. Together with the clicks in the history that is
+// enough to play a finished game again through the same rules and check the
+// result stands, which is what a dispute comes down to.
+
+// seedCommitment is what GAME_START sends in place of the seed, which is only
+// revealed in GAME_OVER: players can check the two match, but not predict trials
+func seedCommitment(seed int64) string {
+	sum := sha256.Sum256([]byte(strconv.FormatInt(seed, 10)))
+	return hex.EncodeToString(sum[:])
+}
+
+// newSeed picks the seed of a new game
+// Never 0, which is what games recorded before seeds were kept have
+func newSeed() int64 {
+	for {
+		if seed := rand.Int63(); seed != 0 {
+			return seed
+		}
+	}
+}
+
+// roundRand is the generator a round's trial is dealt from
+// Each round gets its own, so a round can be dealt again without the ones before it
+func roundRand(seed int64, round int) *rand.Rand {
+	return rand.New(rand.NewSource(seed + int64(round)))
+}
+
+// replayClock stands still at the start of the game
+// A replay never waits, clicks are placed by their recorded latency instead
+type replayClock struct {
+	at time.Time
+}
+
+func (c replayClock) Now() time.Time                       { return c.at }
+func (replayClock) After(d time.Duration) <-chan time.Time { return nil }
+
+var ErrNoSeed = errors.New("game was recorded without a seed")
+
+// ReplayReport is what replaying a finished game found
+type ReplayReport struct {
+	RoomID         string   `json:"room_id"`
+	Seed           int64    `json:"seed"`
+	Rounds         int      `json:"rounds"`          // Rounds replayed
+	Winner         string   `json:"winner"`          // Who won the replay, "draw" if nobody
+	RecordedWinner string   `json:"recorded_winner"` // Who the game was given to
+	Verified       bool     `json:"verified"`        // The replay matched the record throughout
+	Mismatches     []string `json:"mismatches"`      // Where it didn't, empty if verified
+}
+
+// replayGame plays a finished game again from its seed and recorded clicks
+// Every round is dealt afresh and every click judged again; the rounds and the
+// winner that come out are compared with the record. A forfeit is decided by a
+// player leaving, which the clicks don't show, so there only the rounds played
+// are checked and the recorded winner stands.
+func replayGame(record GameRecord) (ReplayReport, error) {
+	if record.Seed == 0 {
+		return ReplayReport{}, ErrNoSeed
+	}
+	if len(record.Players) != 2 {
+		return ReplayReport{}, fmt.Errorf("game has %d players", len(record.Players))
+	}
+	if err := record.Config.Validate(); err != nil {
+		return ReplayReport{}, fmt.Errorf("game has a bad config: %w", err)
+	}
+
+	report := ReplayReport{
+		RoomID:         record.RoomID,
+		Seed:           record.Seed,
+		RecordedWinner: record.Winner,
+		Mismatches:     []string{},
+	}
+	mismatch := func(format string, args ...interface{}) {
+		report.Mismatches = append(report.Mismatches, fmt.Sprintf(format, args...))
+	}
+
+	clock := replayClock{at: record.StartedAt}
+	game := newGame(record.RoomID, record.Players, record.Config, clock)
+	game.Seed = record.Seed
+	game.Status = StatusInProgress
+
+	// A forfeit can come in the middle of a round, whose clicks have no result
+	clicks := record.Clicks
+	if record.Reason != "game_completed" {
+		for len(clicks) > 0 && clicks[len(clicks)-1].Round == len(record.Results)+1 {
+			clicks = clicks[:len(clicks)-1]
+		}
+	}
+
+	// Each click arrives as long after the round started as it did in the game,
+	// and must be accepted, or turned away, and judged as it was then
+	replayClick := func(click ClickRecord, trial Trial) {
+		latency := time.Duration(click.Latency) * time.Millisecond
+		if click.Rejected == "" && latency > trial.Timeout {
+			mismatch("round %d: %s answered after %dms, the round times out at %dms",
+				click.Round, click.PlayerID, click.Latency, trial.Timeout.Milliseconds())
+		}
+
+		// Pauses aren't recorded, only the clicks they turned away
+		game.mu.Lock()
+		game.paused = click.Rejected == ClickPaused
+		game.mu.Unlock()
+
+		judged := len(game.timeline)
+		handleClick(game, playerClick{playerID: click.PlayerID, answer: click.Answer, at: clock.Now().Add(latency)})
+
+		game.mu.Lock()
+		game.paused = false
+		game.mu.Unlock()
+
+		switch {
+		case len(game.timeline) == judged:
+			mismatch("round %d: %s answering %q would not have been recorded", click.Round, click.PlayerID, click.Answer)
+		case game.timeline[judged].Rejected != click.Rejected:
+			mismatch("round %d: %s answering %q turned away as %q, recorded %q",
+				click.Round, click.PlayerID, click.Answer, game.timeline[judged].Rejected, click.Rejected)
+		case game.timeline[judged].Correct != click.Correct:
+			mismatch("round %d: %s answering %q judged correct=%t, recorded correct=%t",
+				click.Round, click.PlayerID, click.Answer, game.timeline[judged].Correct, click.Correct)
+		}
+	}
+
+	// Clicks before the first round are all turned away
+	game.roundFinished = true
+	for len(clicks) > 0 && clicks[0].Round == 0 {
+		replayClick(clicks[0], Trial{})
+		clicks = clicks[1:]
+	}
+
+	for round := 1; round <= len(record.Results); round++ {
+		game.mu.Lock()
+		game.CurrentRound = round
+		game.mu.Unlock()
+
+		// A round opens at its first click that wasn't turned away as round_closed,
+		// and is over by the first one after that which was
+		var trial Trial
+		var result RoundResult
+		started, finished := false, false
+		start := func() {
+			game.mu.Lock()
+			trial = startRoundLocked(game, round)
+			game.mu.Unlock()
+			started = true
+		}
+		finish := func() {
+			game.mu.Lock()
+			result = finishRoundLocked(game, round)
+			game.mu.Unlock()
+			finished = true
+		}
+
+		for len(clicks) > 0 && clicks[0].Round == round {
+			click := clicks[0]
+			clicks = clicks[1:]
+
+			switch {
+			case click.Rejected != ClickRoundClosed && !started:
+				start()
+			case click.Rejected == ClickRoundClosed && started && !finished:
+				finish()
+			}
+			replayClick(click, trial)
+		}
+
+		if !started {
+			start()
+		}
+		if !finished {
+			finish()
+		}
+
+		if recorded := record.Results[round-1]; !sameResult(result, recorded) {
+			mismatch("round %d: replayed %+v, recorded %+v", round, result, recorded)
+		}
+	}
+	report.Rounds = len(record.Results)
+
+	if len(clicks) > 0 {
+		mismatch("%d clicks recorded outside the rounds played, from round %d", len(clicks), clicks[0].Round)
+	}
+
+	if record.Reason == "game_completed" {
+		if len(record.Results) != record.Config.Rounds {
+			mismatch("game completed after %d of %d rounds", len(record.Results), record.Config.Rounds)
+		}
+		report.Winner = determineWinner(game)
+	} else {
+		report.Winner = record.Winner
+	}
+	if report.Winner != record.Winner {
+		mismatch("replay won by %s, recorded winner %s", report.Winner, record.Winner)
+	}
+
+	report.Verified = len(report.Mismatches) == 0
+	return report, nil
+}
+
+// sameResult compares a replayed round with the recorded one
+func sameResult(a, b RoundResult) bool {
+	return a.Round == b.Round &&
+		a.Word == b.Word &&
+		a.Color == b.Color &&
+		a.Answer == b.Answer &&
+		a.Congruent == b.Congruent &&
+		a.Winner == b.Winner &&
+		a.Latency == b.Latency &&
+		maps.Equal(a.Wrong, b.Wrong) &&
+		maps.Equal(a.Points, b.Points)
+}
+
+// GET /games/{roomID}/replay - play a finished game again and check its result
+func replayHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	roomID := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/games/"), "/replay")
+	if roomID == "" || strings.Contains(roomID, "/") {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	record, err := history.Get(roomID)
+	if errors.Is(err, ErrGameNotFound) {
+		http.Error(w, "Game not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to look up game %s: %v", roomID, err)
+		http.Error(w, "Failed to look up game", http.StatusInternalServerError)
+		return
+	}
+
+	report, err := replayGame(*record)
+	if errors.Is(err, ErrNoSeed) {
+		http.Error(w, "Game was recorded without a seed and can't be replayed", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to replay game %s: %v", roomID, err)
+		http.Error(w, "Failed to replay game", http.StatusUnprocessableEntity)
+		return
+	}
+	if !report.Verified {
+		log.Printf("Replay of game %s does not match its record: %v", roomID, report.Mismatches)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Flokots/programming-5/colorSync/shared/auth"
+)
+
+// Tokens for calling back into other services, each scoped to one internal route
+var (
+	// User Service only accepts match results on tokens scoped for rating updates
+	userServiceTokens = auth.NewServiceTokenSource(auth.GameRulesService, auth.UserService, auth.ScopeRatingsWrite)
+
+	// Room Service only accepts lifecycle callbacks on tokens scoped for them
+	roomServiceTokens = auth.NewServiceTokenSource(auth.GameRulesService, auth.RoomService, auth.ScopeRoomsLifecycle)
+)
+
+const (
+	callbackAttempts = 5
+	callbackBackoff  = 500 * time.Millisecond // Doubled after each failed attempt
+)
+
+type matchResult struct {
+	RoomID   string                      `json:"room_id"`
+	Players  []string                    `json:"players"`
+	WinnerID string                      `json:"winner_id"` // Empty for a draw
+	Stats    map[string]playerMatchStats `json:"stats"`     // Player ID to how they played
+}
+
+// playerMatchStats is how one player did in a game, for their lifetime statistics
+type playerMatchStats struct {
+	RoundsPlayed   int   `json:"rounds_played"`
+	RoundsWon      int   `json:"rounds_won"`
+	CorrectAnswers int   `json:"correct_answers"`
+	WrongAnswers   int   `json:"wrong_answers"`
+	ReactionMs     int64 `json:"reaction_ms"`      // Sum over correct answers
+	BestReactionMs int64 `json:"best_reaction_ms"` // Fastest correct answer, 0 if none
+}
+
+// matchStatsLocked tallies each player's rounds and answers, from the results and the click timeline
+// Caller must hold game.mu
+func matchStatsLocked(game *Game) map[string]playerMatchStats {
+	stats := make(map[string]playerMatchStats)
+	for _, playerID := range game.Players {
+		stats[playerID] = playerMatchStats{RoundsPlayed: len(game.Results)}
+	}
+	for _, result := range game.Results {
+		if player, exists := stats[result.Winner]; exists {
+			player.RoundsWon++
+			stats[result.Winner] = player
+		}
+	}
+	for _, click := range game.timeline {
+		player, exists := stats[click.PlayerID]
+		if !exists || click.Rejected != "" {
+			continue
+		}
+		if !click.Correct {
+			player.WrongAnswers++
+		} else {
+			player.CorrectAnswers++
+			player.ReactionMs += click.Latency
+			if player.BestReactionMs == 0 || click.Latency < player.BestReactionMs {
+				player.BestReactionMs = click.Latency
+			}
+		}
+		stats[click.PlayerID] = player
+	}
+	return stats
+}
+
+// reportMatchResult sends the outcome to User Service so ratings and statistics can be updated
+// winner is a player ID or "draw"; User Service ignores repeats for the same room,
+// so failed attempts are simply retried
+func reportMatchResult(roomID string, players []string, winner string, stats map[string]playerMatchStats) {
+	if len(players) != 2 {
+		return
+	}
+
+	result := matchResult{RoomID: roomID, Players: players, Stats: stats}
+	if winner != "draw" {
+		result.WinnerID = winner
+	}
+
+	postWithRetry(userServiceTokens, userServiceURL+"/internal/matches", result,
+		fmt.Sprintf("result for room %s", roomID))
+}
+
+// Room lifecycle events reported to Room Service
+const (
+	LifecycleGameStarted  = "game_started"
+	LifecycleGameFinished = "game_finished"
+	LifecycleGameAborted  = "game_aborted"
+)
+
+// lifecycleEvent is the body of POST /internal/rooms/{id}/lifecycle
+type lifecycleEvent struct {
+	Event   string                            `json:"event"`
+	Winner  string                            `json:"winner,omitempty"` // Player ID or "draw"
+	Reason  string                            `json:"reason,omitempty"`
+	Results []RoundResult                     `json:"results,omitempty"`
+	Stats   map[string]map[string]interface{} `json:"stats,omitempty"`
+}
+
+// notifyRoomService tells Room Service what happened to the game in roomID
+// Runs in the background; the room state is only advisory for the game itself
+func notifyRoomService(roomID string, event lifecycleEvent) {
+	url := fmt.Sprintf("%s/internal/rooms/%s/lifecycle", roomServiceURL, roomID)
+	go postWithRetry(roomServiceTokens, url, event,
+		fmt.Sprintf("%s for room %s", event.Event, roomID))
+}
+
+// postWithRetry POSTs payload with a service token, backing off between failures
+// Receivers must treat repeats as no-ops
+func postWithRetry(tokens *auth.ServiceTokenSource, url string, payload interface{}, what string) {
+	backoff := callbackBackoff
+	for attempt := 1; attempt <= callbackAttempts; attempt++ {
+		err := postJSON(tokens, url, payload)
+		if err == nil {
+			log.Printf("Delivered %s", what)
+			return
+		}
+
+		log.Printf("Failed to deliver %s (attempt %d/%d): %v", what, attempt, callbackAttempts, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+	log.Printf("Giving up on delivering %s", what)
+}
+
+func postJSON(tokens *auth.ServiceTokenSource, url string, payload interface{}) error {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := tokens.Do(client, req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	"github.com/Flokots/programming-5/colorSync/shared/gameconfig"
+)
+
+// Points scoring: a correct answer is worth between minCorrectPoints and
+// maxCorrectPoints, falling off quadratically with reaction time so fast
+// answers are rewarded much more than answers just before the timeout
+const (
+	maxCorrectPoints   = 100
+	minCorrectPoints   = 10
+	wrongAnswerPenalty = 25
+)
+
+// pointsFor scores a correct answer given after latency in a round lasting timeout
+func pointsFor(latency, timeout time.Duration) int {
+	t := min(max(float64(latency)/float64(timeout), 0), 1)
+	return minCorrectPoints + int(math.Round((maxCorrectPoints-minCorrectPoints)*(1-t)*(1-t)))
+}
+
+// usesPoints reports whether rounds are scored with points, letting both players score
+func usesPoints(game *Game) bool {
+	return game.Config.Scoring == gameconfig.ScoringPoints
+}
+
+// lockedOutLocked reports whether the player may not answer again this round
+// Caller must hold game.mu
+func lockedOutLocked(game *Game, playerID string) bool {
+	if game.wrongAnswers[playerID] == 0 {
+		return false
+	}
+	switch game.Config.WrongAnswer {
+	case gameconfig.WrongAnswerLockout:
+		return true
+	case gameconfig.WrongAnswerForfeit:
+		return usesPoints(game) // With points there is no round to give away, so it locks out
+	default:
+		return false
+	}
+}
+
+// roundOverLocked reports whether the round can end before its timeout
+// With points every player gets to answer; otherwise the first correct answer ends it
+// Caller must hold game.mu
+func roundOverLocked(game *Game) bool {
+	if !usesPoints(game) {
+		return game.roundAnswered
+	}
+	for _, playerID := range game.Players {
+		if !game.roundCorrect[playerID] && !lockedOutLocked(game, playerID) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Flokots/programming-5/colorSync/shared/middleware"
+)
+
+// Room events pushed to players over Server-Sent Events
+const (
+	EventOpponentJoined = "opponent_joined" // Room is full
+	EventGameCreated    = "game_created"    // Game Service accepted the game, connect the WebSocket
+	EventGameStarted    = "game_started"    // Both players connected, rounds are running
+	EventGameFinished   = "game_finished"   // Result is available from GET /rooms/{id}
+	EventRoomError      = "room_error"      // Game could not be started, leave and join again
+	EventRoomClosed     = "room_closed"     // Room is gone, the stream ends after this
+)
+
+const (
+	eventBufferSize   = 8
+	eventKeepAlive    = 15 * time.Second // Comment line so proxies don't drop idle streams
+	eventWriteTimeout = 5 * time.Second
+)
+
+// RoomEvent is the data of one SSE message
+// RoomID can change after opponent_joined when matchmaking moved the player into another room
+type RoomEvent struct {
+	Type    string   `json:"type"`
+	RoomID  string   `json:"room_id"`
+	Players []string `json:"players,omitempty"`
+	Winner  string   `json:"winner,omitempty"` // game_finished only
+	Reason  string   `json:"reason,omitempty"` // game_finished, room_error and room_closed
+}
+
+// subscription is one open event stream
+type subscription struct {
+	roomID string // Follows the player when their waiting room is merged
+	events chan RoomEvent
+}
+
+var subscribers = make(map[string][]*subscription) // roomID -> open streams, guarded by mu
+
+// subscribeLocked opens a stream for roomID and replays the room's current state,
+// so a client that subscribes late doesn't miss events that already happened
+// Caller must hold mu
+func subscribeLocked(room *Room) *subscription {
+	sub := &subscription{roomID: room.ID, events: make(chan RoomEvent, eventBufferSize)}
+	subscribers[room.ID] = append(subscribers[room.ID], sub)
+
+	if len(room.Players) == 2 {
+		sub.events <- RoomEvent{Type: EventOpponentJoined, RoomID: room.ID, Players: slices.Clone(room.Players)}
+	}
+	if room.GameCreated {
+		sub.events <- RoomEvent{Type: EventGameCreated, RoomID: room.ID, Players: slices.Clone(room.Players)}
+	}
+	switch room.Status {
+	case "in_progress":
+		sub.events <- RoomEvent{Type: EventGameStarted, RoomID: room.ID, Players: slices.Clone(room.Players)}
+	case "finished":
+		sub.events <- RoomEvent{Type: EventGameFinished, RoomID: room.ID, Players: slices.Clone(room.Players),
+			Winner: room.Result.Winner, Reason: room.Result.Reason}
+	case "error":
+		sub.events <- RoomEvent{Type: EventRoomError, RoomID: room.ID, Players: slices.Clone(room.Players), Reason: room.Error}
+	}
+	return sub
+}
+
+// unsubscribeLocked closes a stream
+// Caller must hold mu
+func unsubscribeLocked(sub *subscription) {
+	subs := subscribers[sub.roomID]
+	if i := slices.Index(subs, sub); i >= 0 {
+		subs = slices.Delete(subs, i, i+1)
+	}
+	if len(subs) == 0 {
+		delete(subscribers, sub.roomID)
+	} else {
+		subscribers[sub.roomID] = subs
+	}
+}
+
+// moveSubscribersLocked redirects streams of a merged waiting room to the room it merged into
+// Caller must hold mu
+func moveSubscribersLocked(from, to string) {
+	for _, sub := range subscribers[from] {
+		sub.roomID = to
+		subscribers[to] = append(subscribers[to], sub)
+	}
+	delete(subscribers, from)
+}
+
+// publishLocked sends an event to every stream of the room
+// Slow readers lose the event rather than block room-service
+// Caller must hold mu
+func publishLocked(roomID string, event RoomEvent) {
+	event.RoomID = roomID
+	event.Players = slices.Clone(event.Players) // Marshalled later without mu
+	for _, sub := range subscribers[roomID] {
+		select {
+		case sub.events <- event:
+		default:
+			log.Printf("Dropping %s event for a slow subscriber of room %s", event.Type, roomID)
+		}
+	}
+}
+
+// roomEventsHandler streams events for a room the caller is in
+// URL format: /rooms/{roomID}/events
+// Wrapped in middleware.RequireAuth
+func roomEventsHandler(w http.ResponseWriter, r *http.Request) {
+	// 1. Extract room ID from URL path
+	roomID := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/rooms/"), "/events")
+
+	// 2. Get user claims from JWT token (validated by middleware)
+	claims := middleware.GetUserClaims(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized - no user claims", http.StatusUnauthorized)
+		return
+	}
+
+	// 3. Only players of the room may listen
+	mu.Lock()
+	room, exists := resolveRoomLocked(roomID)
+	if !exists {
+		mu.Unlock()
+		http.Error(w, "Room not found", http.StatusNotFound)
+		return
+	}
+	if !slices.Contains(room.Players, claims.UserID) {
+		mu.Unlock()
+		http.Error(w, "Not a player in this room", http.StatusForbidden)
+		return
+	}
+	sub := subscribeLocked(room)
+	mu.Unlock()
+
+	defer func() {
+		mu.Lock()
+		unsubscribeLocked(sub)
+		mu.Unlock()
+	}()
+
+	// 4. Start the event stream
+	controller := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	if err := controller.Flush(); err != nil {
+		log.Printf("Streaming not supported: %v", err)
+		return
+	}
+
+	log.Printf("User %s listening for events in room %s", claims.UserID, room.ID)
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case <-keepAlive.C:
+			controller.SetWriteDeadline(time.Now().Add(eventWriteTimeout))
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			controller.Flush()
+
+		case event := <-sub.events:
+			data, _ := json.Marshal(event)
+			controller.SetWriteDeadline(time.Now().Add(eventWriteTimeout))
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+				return
+			}
+			controller.Flush()
+
+			if event.Type == EventRoomClosed {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// eventServer serves roomEventsHandler as the user named in the X-Test-User header
+func eventServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		roomEventsHandler(w, asUser(r, r.Header.Get("X-Test-User")))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// openEvents opens the event stream of a room as userID
+func openEvents(t *testing.T, ctx context.Context, server *httptest.Server, roomID, userID string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/rooms/"+roomID+"/events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Test-User", userID)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// readEvent reads the next SSE message, skipping keep-alive comments
+func readEvent(t *testing.T, stream *bufio.Reader) (string, RoomEvent) {
+	t.Helper()
+	var name string
+	var event RoomEvent
+	for {
+		line, err := stream.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading event stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+				t.Fatalf("event data %q: %v", line, err)
+			}
+		case line == "" && name != "":
+			return name, event
+		}
+	}
+}
+
+func TestRoomEventsAccess(t *testing.T) {
+	resetRooms(t)
+	room := addRoom("full", time.Now())
+
+	tests := []struct {
+		name     string
+		roomID   string
+		userID   string
+		wantCode int
+	}{
+		{"outsider", room.ID, "mallory", http.StatusForbidden},
+		{"unknown room", "no-such-room", "a", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			roomEventsHandler(w, asUser(httptest.NewRequest(http.MethodGet, "/rooms/"+tt.roomID+"/events", nil), tt.userID))
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if len(subscribers) != 0 {
+				t.Fatal("a rejected request was subscribed")
+			}
+		})
+	}
+}
+
+func TestRoomEventsStream(t *testing.T) {
+	resetRooms(t)
+	room := addRoom("full", time.Now())
+	server := eventServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	resp := openEvents(t, ctx, server, room.ID, "a")
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if ct, cc := resp.Header.Get("Content-Type"), resp.Header.Get("Cache-Control"); ct != "text/event-stream" || cc != "no-cache" {
+		t.Fatalf("Content-Type %q, Cache-Control %q, want an uncached event stream", ct, cc)
+	}
+	stream := bufio.NewReader(resp.Body)
+
+	// The room's state so far arrives straight away, flushed with the headers
+	if name, event := readEvent(t, stream); name != EventOpponentJoined || event.RoomID != room.ID || len(event.Players) != 2 {
+		t.Fatalf("first event = %s %+v, want opponent_joined with both players", name, event)
+	}
+
+	// Then whatever happens to the room
+	mu.Lock()
+	publishLocked(room.ID, RoomEvent{Type: EventGameCreated, Players: room.Players})
+	mu.Unlock()
+	if name, event := readEvent(t, stream); name != EventGameCreated || event.RoomID != room.ID {
+		t.Fatalf("next event = %s %+v, want game_created", name, event)
+	}
+
+	// The client going away ends the subscription
+	cancel()
+	waitFor(t, "the stream to unsubscribe", func() bool { return len(subscribers[room.ID]) == 0 })
+}
+
+func TestRoomEventsEndWhenRoomCloses(t *testing.T) {
+	resetRooms(t)
+	room := addRoom("full", time.Now())
+	server := eventServer(t)
+
+	resp := openEvents(t, context.Background(), server, room.ID, "b")
+	stream := bufio.NewReader(resp.Body)
+	readEvent(t, stream) // opponent_joined
+
+	mu.Lock()
+	publishLocked(room.ID, RoomEvent{Type: EventRoomClosed, Players: room.Players, Reason: "opponent_left"})
+	mu.Unlock()
+
+	if name, event := readEvent(t, stream); name != EventRoomClosed || event.Reason != "opponent_left" {
+		t.Fatalf("event = %s %+v, want room_closed because the opponent left", name, event)
+	}
+	if _, err := stream.ReadString('\n'); err == nil {
+		t.Fatal("stream still open after room_closed")
+	}
+	waitFor(t, "the stream to unsubscribe", func() bool { return len(subscribers[room.ID]) == 0 })
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Lifecycle events reported by Game Rules Service
+const (
+	LifecycleGameStarted  = "game_started"
+	LifecycleGameFinished = "game_finished"
+	LifecycleGameAborted  = "game_aborted"
+)
+
+// LifecycleRequest is the body of POST /internal/rooms/{id}/lifecycle
+type LifecycleRequest struct {
+	Event   string          `json:"event"`
+	Winner  string          `json:"winner,omitempty"` // Player ID or "draw"
+	Reason  string          `json:"reason,omitempty"`
+	Results json.RawMessage `json:"results,omitempty"` // Round results, passed through to clients
+	Stats   json.RawMessage `json:"stats,omitempty"`
+}
+
+// GameResult is the outcome kept on a finished room
+type GameResult struct {
+	Winner  string          `json:"winner"`
+	Reason  string          `json:"reason"`
+	Results json.RawMessage `json:"results,omitempty"`
+	Stats   json.RawMessage `json:"stats,omitempty"`
+}
+
+// lifecycleHandler moves a room through in_progress and finished as its game runs
+// URL format: /internal/rooms/{roomID}/lifecycle
+// Wrapped in middleware.RequireServicePolicy
+func lifecycleHandler(w http.ResponseWriter, r *http.Request) {
+	// 1. Only accept POST requests
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 2. Extract room ID from URL path
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/internal/rooms/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] != "lifecycle" {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	roomID := parts[0]
+
+	// 3. Parse event
+	var req LifecycleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	room, exists := rooms[roomID]
+	if !exists {
+		http.Error(w, "Room not found", http.StatusNotFound)
+		return
+	}
+
+	// 4. Apply the transition; repeats and late arrivals are no-ops so callers can retry
+	now := time.Now()
+	switch req.Event {
+	case LifecycleGameStarted:
+		if room.Status == "full" {
+			room.Status = "in_progress"
+			room.UpdatedAt = now
+			publishLocked(room.ID, RoomEvent{Type: EventGameStarted, Players: room.Players})
+		}
+
+	case LifecycleGameFinished:
+		if room.Status != "finished" {
+			room.Status = "finished"
+			room.UpdatedAt = now
+			room.Result = &GameResult{
+				Winner:  req.Winner,
+				Reason:  req.Reason,
+				Results: req.Results,
+				Stats:   req.Stats,
+			}
+			publishLocked(room.ID, RoomEvent{Type: EventGameFinished, Players: room.Players, Winner: req.Winner, Reason: req.Reason})
+		}
+
+	case LifecycleGameAborted:
+		// The game never happened, nothing worth keeping
+		deleteRoomLocked(room, "game_aborted")
+
+	default:
+		http.Error(w, "Unknown lifecycle event", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("Room %s: %s (status %s)", roomID, req.Event, room.Status)
+	respondJSON(w, http.StatusOK, map[string]string{
+		"room_id": roomID,
+		"status":  room.Status,
+	})
+}
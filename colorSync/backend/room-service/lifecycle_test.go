@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLifecycleTransitions(t *testing.T) {
+	tests := []struct {
+		name       string
+		from       string
+		body       string
+		wantCode   int
+		wantStatus string // Empty if the room must be gone
+		wantEvent  string // Published to the room's stream, empty for none
+	}{
+		{"full room starts", "full", `{"event":"game_started"}`, http.StatusOK, "in_progress", EventGameStarted},
+		{"start is repeated", "in_progress", `{"event":"game_started"}`, http.StatusOK, "in_progress", ""},
+		{"late start doesn't reopen a finished room", "finished", `{"event":"game_started"}`, http.StatusOK, "finished", ""},
+		{"game finishes", "in_progress", `{"event":"game_finished","winner":"a","reason":"game_completed"}`, http.StatusOK, "finished", EventGameFinished},
+		{"finish is repeated", "finished", `{"event":"game_finished","winner":"b"}`, http.StatusOK, "finished", ""},
+		{"abort closes the room", "full", `{"event":"game_aborted","reason":"player_left"}`, http.StatusOK, "", EventRoomClosed},
+		{"unknown event", "full", `{"event":"game_paused"}`, http.StatusBadRequest, "full", ""},
+		{"malformed body", "full", `{`, http.StatusBadRequest, "full", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetRooms(t)
+			room := addRoom(tt.from, time.Now())
+			sub := subscribeLocked(room)
+			drainEvents(sub)
+
+			w := httptest.NewRecorder()
+			lifecycleHandler(w, httptest.NewRequest(http.MethodPost, "/internal/rooms/"+room.ID+"/lifecycle", strings.NewReader(tt.body)))
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			stored, exists := rooms[room.ID]
+			switch {
+			case tt.wantStatus == "" && exists:
+				t.Fatalf("room still exists as %s, want it closed", stored.Status)
+			case tt.wantStatus != "" && !exists:
+				t.Fatalf("room was closed, want it %s", tt.wantStatus)
+			case exists && stored.Status != tt.wantStatus:
+				t.Fatalf("room is %s, want %s", stored.Status, tt.wantStatus)
+			}
+
+			select {
+			case event := <-sub.events:
+				if event.Type != tt.wantEvent {
+					t.Fatalf("event = %s, want %q", event.Type, tt.wantEvent)
+				}
+			default:
+				if tt.wantEvent != "" {
+					t.Fatalf("no event, want %s", tt.wantEvent)
+				}
+			}
+		})
+	}
+}
+
+func TestLifecycleKeepsResult(t *testing.T) {
+	resetRooms(t)
+	room := addRoom("in_progress", time.Now())
+	room.Result = nil
+
+	body := `{"event":"game_finished","winner":"b","reason":"game_completed","results":[{"round":1}]}`
+	w := httptest.NewRecorder()
+	lifecycleHandler(w, httptest.NewRequest(http.MethodPost, "/internal/rooms/"+room.ID+"/lifecycle", strings.NewReader(body)))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	if room.Result == nil || room.Result.Winner != "b" || string(room.Result.Results) != `[{"round":1}]` {
+		t.Fatalf("result = %+v, want b's win with the round results", room.Result)
+	}
+}
+
+func TestLifecycleUnknownRoom(t *testing.T) {
+	resetRooms(t)
+
+	w := httptest.NewRecorder()
+	lifecycleHandler(w, httptest.NewRequest(http.MethodPost, "/internal/rooms/missing/lifecycle", strings.NewReader(`{"event":"game_started"}`)))
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", w.Code)
+	}
+}
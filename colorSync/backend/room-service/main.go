@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -13,14 +14,39 @@ import (
 	"github.com/google/uuid"
 
 	"github.com/Flokots/programming-5/colorSync/shared/auth"
+	"github.com/Flokots/programming-5/colorSync/shared/gameconfig"
 	"github.com/Flokots/programming-5/colorSync/shared/middleware"
 )
 
 // Room represents a game room
 type Room struct {
-	ID      string   `json:"id"`
-	Players []string `json:"players"` // Array of user IDs
-	Status  string   `json:"status"`  // e.g., "waiting", or "full"
+	ID      string         `json:"id"`
+	Players []string       `json:"players"` // Array of user IDs
+	Ratings map[string]int `json:"ratings"` // userID -> rating when they joined
+	Status  string         `json:"status"`  // waiting, full, in_progress, finished or error
+	Private bool           `json:"private"` // Invite-only, never matched from the queue
+
+	Config *gameconfig.Config `json:"config,omitempty"` // Game rules, nil for the defaults
+
+	GameCreated bool        `json:"game_created"`     // Game Service accepted the game
+	Result      *GameResult `json:"result,omitempty"` // Set once finished
+	Error       string      `json:"error,omitempty"`  // Why the room is in the error state
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"` // Last status change, used by the reaper
+}
+
+// newRoom creates a waiting room for its first player
+func newRoom(userID string, rating int, private bool) *Room {
+	now := time.Now()
+	return &Room{
+		ID:        uuid.New().String(),
+		Players:   []string{userID},
+		Ratings:   map[string]int{userID: rating},
+		Status:    "waiting",
+		Private:   private,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
 }
 
 type ErrorResponse struct {
@@ -30,20 +56,20 @@ type ErrorResponse struct {
 // In-memory storage
 var (
 	rooms          = make(map[string]*Room)  //roomID -> Room
-	waitingRoomID  *string                   // ID of room waiting for players
 	mu             sync.RWMutex              //Mutex for thread-safe access
 	userServiceURL = "http://localhost:8001" // User service endpoint
 	gameServiceURL = "http://localhost:8003" // Game service endpoint
 )
 
-// Service token for Zero Trust communication
-var gameServiceToken string
+// Service tokens for Zero Trust communication, renewed automatically
+// Game Service only accepts tokens scoped to starting games
+var gameServiceTokens = auth.NewServiceTokenSource(auth.RoomService, auth.GameRulesService, auth.ScopeGameStart)
 
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Allow requests from React DEV server
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
 		if r.Method == "OPTIONS" {
@@ -55,32 +81,74 @@ func corsMiddleware(next http.Handler) http.Handler {
 }
 
 func main() {
-	// Generate service token for Game Service communication (Zero Trust)
-	var err error
-	gameServiceToken, err = auth.GenerateServiceToken("room-service")
-	if err != nil {
+	// Keys for service-to-service tokens
+	if err := auth.LoadServiceKeys(auth.RoomService); err != nil {
+		log.Fatalf("Failed to load service keys: %v", err)
+	}
+
+	// Verify user tokens with the public keys published by User Service
+	auth.UseRemoteJWKS(userServiceURL + "/.well-known/jwks.json")
+
+	// Mirror revoked user tokens from User Service
+	auth.SyncRevocations(userServiceURL+"/internal/revocations", auth.RoomService, 5*time.Second)
+
+	// Pick up rotated signing keys without a restart
+	auth.WatchKeyFiles(30 * time.Second)
+
+	// Mint the first Game Service token up front so misconfigured keys fail fast
+	if _, err := gameServiceTokens.Token(); err != nil {
 		log.Fatalf("Failed to generate service token: %v", err)
 	}
 	log.Printf("Service token generated for Game Service communication")
 
+	// Deliver game starts, including any left pending by the last run
+	pending, err := openOutbox(getEnv("ROOM_OUTBOX_PATH", "outbox.json"))
+	if err != nil {
+		log.Fatalf("Failed to open outbox: %v", err)
+	}
+	gameStarts = pending
+	gameStarts.restoreRooms()
+	go gameStarts.run()
+
+	// Pair queued players as their rating windows widen
+	go runMatchmaker()
+
+	// Expire abandoned and finished rooms
+	go runReaper()
+
 	mux := http.NewServeMux()
 
 	// Protect routes with JWT authentication
-	mux.HandleFunc("/join", middleware.RequireAuth(joinRoomHandler))
+	mux.HandleFunc("/join", middleware.RequireAuth(joinRouter))
+	mux.HandleFunc("/join/heartbeat", middleware.RequireAuth(queueHeartbeatHandler))
+	mux.HandleFunc("/rooms", middleware.RequireAuth(createPrivateRoomHandler))
 	mux.HandleFunc("/rooms/", middleware.RequireAuth(roomsRouter))
 
 	// Public routes
 	mux.HandleFunc("/health", healthHandler)
+
+	// Only Game Service may report how games went
+	mux.HandleFunc("/internal/rooms/", middleware.RequireServicePolicy(middleware.ServicePolicy{
+		Audience: auth.RoomService,
+		Callers:  []string{auth.GameRulesService},
+		Scopes:   []string{auth.ScopeRoomsLifecycle},
+	}, lifecycleHandler))
 	mux.HandleFunc("/room/", roomReadyHandler) // Note the trailing slash!
 
 	port := ":8002"
 	fmt.Printf("Room Service starting on port %s\n", port)
 	fmt.Printf("Endpoints:\n")
 	fmt.Printf("POST /join         - Join matchmaking (requires JWT)\n")
+	fmt.Printf("DELETE /join       - Leave matchmaking queue (requires JWT)\n")
+	fmt.Printf("POST /join/heartbeat - Keep queue entry alive (requires JWT)\n")
+	fmt.Printf("POST /rooms        - Create private room with invite code (requires JWT)\n")
+	fmt.Printf("POST /rooms/join/:code - Join private room by invite code (requires JWT)\n")
 	fmt.Printf("GET  /rooms/:id    - Get room info (requires JWT)\n")
+	fmt.Printf("GET  /rooms/:id/events - Room event stream, SSE (requires JWT)\n")
 	fmt.Printf("POST /rooms/:id/leave - Leave room (requires JWT)\n")
 	fmt.Printf("GET  /room/:id/ready - Check room status (public)\n")
 	fmt.Printf("GET  /health       - Health check (public)\n")
+	fmt.Printf("POST /internal/rooms/:id/lifecycle - Game started/finished/aborted (service token)\n")
 	fmt.Printf("\n")
 
 	handler := corsMiddleware(mux) // Wrap with CORS middleware
@@ -93,6 +161,18 @@ func roomsRouter(w http.ResponseWriter, r *http.Request) {
 	path := strings.TrimPrefix(r.URL.Path, "/rooms/")
 	parts := strings.Split(path, "/")
 
+	if len(parts) == 2 && parts[0] == "join" && r.Method == http.MethodPost {
+		// POST /rooms/join/:code
+		joinPrivateRoomHandler(w, r)
+		return
+	}
+
+	if len(parts) == 2 && parts[1] == "events" && r.Method == http.MethodGet {
+		// GET /rooms/:id/events
+		roomEventsHandler(w, r)
+		return
+	}
+
 	if len(parts) == 2 && parts[1] == "leave" && r.Method == http.MethodPost {
 		// POST /rooms/:id/leave
 		leaveRoomHandler(w, r)
@@ -164,9 +244,10 @@ func joinRoomHandler(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("User %s (%s) joining matchmaking", claims.Username, req.UserID)
 
-	// 6. Verify user exists by calling User Service
-	if !verifyUser(req.UserID) {
-		http.Error(w, "User not found", http.StatusNotFound)
+	// 6. Verify user exists and is allowed to play by calling User Service
+	user, err := verifyUser(req.UserID)
+	if err != nil {
+		respondVerifyError(w, err)
 		return
 	}
 
@@ -174,59 +255,28 @@ func joinRoomHandler(w http.ResponseWriter, r *http.Request) {
 	mu.Lock()
 	defer mu.Unlock() // Unlock when function exits
 
-	// Check if user is already in the waiting room
-	if waitingRoomID != nil {
-		waitingRoom := rooms[*waitingRoomID]
-		for _, playerID := range waitingRoom.Players {
-			if playerID == req.UserID {
-				log.Printf("User %s already in waiting room %s", req.UserID, *waitingRoomID)
-				http.Error(w, "You are already in matchmaking queue", http.StatusConflict)
-				return
-			}
-		}
-	}
-
-	// Check if user is already in any room
-	for _, room := range rooms {
-		for _, playerID := range room.Players {
-			if playerID == req.UserID {
-				log.Printf("⚠️ User %s already in room %s", req.UserID, room.ID)
-				http.Error(w, "You are already in an active room", http.StatusConflict)
-				return
-			}
+	// Check if user is already queued or in any room
+	if room := userInRoomLocked(req.UserID); room != nil {
+		log.Printf("⚠️ User %s already in room %s", req.UserID, room.ID)
+		if room.Status == "waiting" {
+			http.Error(w, "You are already in matchmaking queue", http.StatusConflict)
+		} else {
+			http.Error(w, "You are already in an active room", http.StatusConflict)
 		}
+		return
 	}
 
 	var room *Room
+	entry := &queueEntry{UserID: req.UserID, Rating: user.Rating, JoinedAt: time.Now()}
 
-	// Check if there's a waiting room
-	if waitingRoomID != nil {
-		// Join existing room
-		room = rooms[*waitingRoomID]
-
-		// Double-check players are different
-		if len(room.Players) > 0 && room.Players[0] == req.UserID {
-			log.Printf("ERROR: Same user attempting to join twice: %s", req.UserID)
-			http.Error(w, "Cannot match with yourself", http.StatusConflict)
-			return
-		}
-		room.Players = append(room.Players, req.UserID)
-		room.Status = "full"
-		waitingRoomID = nil // No longer waiting
-		log.Printf("User %s joined room %s (ROOM FULL - 2/2 players)", req.UserID, room.ID)
-
-		// Notify Game Service to start the game
-		go notifyGameService(room.ID, room.Players) // Run in background
-
+	// Join the closest-rated waiting player within range, otherwise wait in a new room
+	if opponent := findOpponentLocked(entry, entry.JoinedAt); opponent != nil {
+		room = pairLocked(opponent, entry)
 	} else {
-		// Create new room
-		room = &Room{
-			ID:      uuid.New().String(),
-			Players: []string{req.UserID},
-			Status:  "waiting",
-		}
+		room = newRoom(req.UserID, user.Rating, false)
 		rooms[room.ID] = room
-		waitingRoomID = &room.ID
+		entry.RoomID = room.ID
+		enqueueLocked(entry)
 
 		log.Printf("User %s created room %s and is waiting for opponent(1/2 players)", req.UserID, room.ID)
 	}
@@ -242,67 +292,57 @@ func joinRoomHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// verifyUser calls User Service to check if user exists
-func verifyUser(userID string) bool {
-	url := fmt.Sprintf("%s/users/%s", userServiceURL, userID)
-
-	resp, err := http.Get(url)
-	if err != nil {
-		log.Printf("Error calling User Service: %v", err)
-		return false
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("User %s not found in User Service", userID)
-		return false
-	}
-	log.Printf("Verified user %s exists", userID)
-	return true
-}
-
-// notifyGameService notifies Game Service to start the game,
+// notifyGameService asks Game Service to start the game, once
 // sends service token for zero trust auth
-func notifyGameService(roomID string, players []string) {
+// Called by the outbox, which retries; errGameRejected means retrying won't help
+func notifyGameService(roomID string, players []string, config *gameconfig.Config) error {
 	url := fmt.Sprintf("%s/game/start", gameServiceURL)
 
 	payload := map[string]interface{}{
 		"room_id": roomID,
 		"players": players,
 	}
+	if config != nil {
+		payload["config"] = config
+	}
 
 	jsonData, _ := json.Marshal(payload)
 
 	// Create request with service token
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		log.Printf("Error creating request to Game Service: %v", err)
-		return
+		return fmt.Errorf("error creating request to Game Service: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
-	//Add service token for Zero Trust
-	req.Header.Set("X-Service-Token", gameServiceToken)
 
-	// Send request
+	// Send request with service token for Zero Trust (renewed and retried on 401)
 	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := gameServiceTokens.Do(client, req)
 	if err != nil {
-		log.Printf("Error calling Game Service: %v", err)
-		return
+		return fmt.Errorf("error calling Game Service: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusOK {
+	switch {
+	case resp.StatusCode == http.StatusOK:
 		log.Printf("Game Service notified for room %s", roomID)
-	} else {
-		log.Printf("Game Service returned status %d", resp.StatusCode)
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("game service returned status %d", resp.StatusCode)
+	default:
+		return fmt.Errorf("%w: status %d", errGameRejected, resp.StatusCode)
 	}
 }
 
 type RoomResponse struct {
-	ID      string   `json:"id"`
-	Players []string `json:"players"`
-	Status  string   `json:"status"`
+	ID      string             `json:"id"`
+	Players []string           `json:"players"`
+	Ratings map[string]int     `json:"ratings"`
+	Status  string             `json:"status"`
+	Private bool               `json:"private"`
+	Config  *gameconfig.Config `json:"config,omitempty"`
+	Result  *GameResult        `json:"result,omitempty"`
+	Error   string             `json:"error,omitempty"`
 }
 
 func getRoomHandler(w http.ResponseWriter, r *http.Request) {
@@ -323,7 +363,7 @@ func getRoomHandler(w http.ResponseWriter, r *http.Request) {
 
 	// 3. Look up room (thread-safe read)
 	mu.RLock()
-	room, exists := rooms[roomID]
+	room, exists := resolveRoomLocked(roomID)
 	mu.RUnlock()
 
 	if !exists {
@@ -331,12 +371,17 @@ func getRoomHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 4. Return room info
+	// 4. Return room info (ID differs from the request if the player was moved)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(RoomResponse{
 		ID:      room.ID,
 		Players: room.Players,
+		Ratings: room.Ratings,
 		Status:  room.Status,
+		Private: room.Private,
+		Config:  room.Config,
+		Result:  room.Result,
+		Error:   room.Error,
 	})
 }
 
@@ -368,23 +413,29 @@ func roomReadyHandler(w http.ResponseWriter, r *http.Request) {
 
 	roomID := parts[0]
 
-	// Look up room
-	mu.RLock()
-	room, exists := rooms[roomID]
-	mu.RUnlock()
+	// Look up room (a merged waiting room resolves to the room its player was moved to)
+	mu.Lock()
+	room, exists := resolveRoomLocked(roomID)
+	if exists {
+		// Polling clients keep their queue entry alive this way
+		heartbeatRoomLocked(room.ID, time.Now())
+	}
+	mu.Unlock()
 
 	if !exists {
 		http.Error(w, "Room not found", http.StatusNotFound)
 		return
 	}
 
-	// Check if room is ready (has 2 players)
-	ready := len(room.Players) == 2
+	// Ready once both players are in and Game Service has created the game
+	ready := len(room.Players) == 2 && room.GameCreated
 
 	// Return response
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"ready":   ready,
+		"room_id": room.ID,
+		"status":  room.Status,
 		"players": room.Players,
 	})
 
@@ -413,7 +464,7 @@ func leaveRoomHandler(w http.ResponseWriter, r *http.Request) {
 	mu.Lock()
 	defer mu.Unlock()
 
-	room, exists := rooms[roomID]
+	room, exists := resolveRoomLocked(roomID)
 	if !exists {
 		respondJSON(w, http.StatusNotFound, ErrorResponse{
 			Error: "Room not found",
@@ -421,6 +472,15 @@ func leaveRoomHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Only players of the room may change it
+	if !slices.Contains(room.Players, userID) {
+		log.Printf("User %s attempted to leave room %s without being in it", userID, room.ID)
+		respondJSON(w, http.StatusForbidden, ErrorResponse{
+			Error: "You are not in this room",
+		})
+		return
+	}
+
 	// Remove user from room
 	newPlayers := []string{}
 	for _, playerID := range room.Players {
@@ -429,36 +489,54 @@ func leaveRoomHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	room.Players = newPlayers
+	room.UpdatedAt = time.Now()
+	delete(room.Ratings, userID)
+	dequeueLocked(userID)
 
 	// Update room status based on remaining players
 	switch len(room.Players) {
 	case 0:
 		// No players left - delete room
-		delete(rooms, roomID)
-		// Clear waitingRoomID if this was the waiting room
-		if waitingRoomID != nil && *waitingRoomID == roomID {
-			waitingRoomID = nil
-		}
-		log.Printf("Room %s deleted (no players remaining)", roomID)
+		deleteRoomLocked(room, "empty")
+		log.Printf("Room %s deleted (no players remaining)", room.ID)
 		respondJSON(w, http.StatusOK, map[string]string{
 			"message": "Left room; room deleted",
 		})
 		return
 	case 1:
-		// One player left - mark as waiting
-		room.Status = "waiting"
-		// Create pointer to room's ID (not local variable)
-		rid := room.ID
-		waitingRoomID = &rid
-		log.Printf("User %s left room %s (1 player remaining, marked as waiting)", userID, roomID)
+		switch {
+		case room.Status == "full" && !room.Private && gameStarts.Cancel(room.ID):
+			// Opponent backed out before the game was requested - queue the remaining player again
+			room.Status = "waiting"
+			remaining := room.Players[0]
+			enqueueLocked(&queueEntry{
+				UserID:   remaining,
+				Rating:   room.Ratings[remaining],
+				RoomID:   room.ID,
+				JoinedAt: time.Now(),
+			})
+			log.Printf("User %s left room %s (1 player remaining, marked as waiting)", userID, room.ID)
+		case room.Status == "full":
+			// Opponent backed out before the game started; the invite is spent, or
+			// Game Service holds (or is about to hold) a game for this room, so close it
+			deleteRoomLocked(room, "opponent_left")
+			log.Printf("User %s left room %s before the game started, room closed", userID, room.ID)
+			respondJSON(w, http.StatusOK, map[string]string{
+				"message": "Left room; room closed",
+			})
+			return
+		default:
+			// Finished and in-progress rooms are never recycled, the reaper closes them
+			log.Printf("User %s left room %s (%s, 1 player remaining)", userID, room.ID, room.Status)
+		}
 	default:
 		// Shouldn't happen after game ends, but keep room as-is
-		log.Printf("User %s left room %s (%d players remaining)", userID, roomID, len(room.Players))
+		log.Printf("User %s left room %s (%d players remaining)", userID, room.ID, len(room.Players))
 	}
 
 	respondJSON(w, http.StatusOK, map[string]interface{}{
 		"message": "Left room successfully",
-		"room_id": roomID,
+		"room_id": room.ID,
 		"players": room.Players,
 	})
 }
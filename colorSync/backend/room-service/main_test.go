@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Flokots/programming-5/colorSync/shared/auth"
+	"github.com/Flokots/programming-5/colorSync/shared/middleware"
+)
+
+// resetRooms gives the test empty room, queue and event state
+// and an outbox in a temp dir that is never delivered
+func resetRooms(t *testing.T) {
+	t.Helper()
+	pending, err := openOutbox(filepath.Join(t.TempDir(), "outbox.json"))
+	if err != nil {
+		t.Fatalf("openOutbox: %v", err)
+	}
+
+	// Under mu, since an earlier test's game start may still be reporting back
+	mu.Lock()
+	defer mu.Unlock()
+	rooms = make(map[string]*Room)
+	queue = nil
+	roomAliases = make(map[string]string)
+	subscribers = make(map[string][]*subscription)
+	invites = make(map[string]*invite)
+	gameStarts = pending
+}
+
+// queuePlayer puts a player with a waiting room in the queue, as joinRoomHandler does
+func queuePlayer(userID string, rating int, joinedAt time.Time) *Room {
+	room := newRoom(userID, rating, false)
+	rooms[room.ID] = room
+	enqueueLocked(&queueEntry{UserID: userID, Rating: rating, RoomID: room.ID, JoinedAt: joinedAt})
+	return room
+}
+
+// addRoom puts a room with players a and b in the given status
+func addRoom(status string, updatedAt time.Time) *Room {
+	room := newRoom("a", 1500, false)
+	room.Players = append(room.Players, "b")
+	room.Ratings["b"] = 1500
+	room.Status = status
+	room.UpdatedAt = updatedAt
+	if status == "finished" {
+		room.Result = &GameResult{Winner: "a", Reason: "game_completed"}
+	}
+	rooms[room.ID] = room
+	return room
+}
+
+// drainEvents discards the events already sent to sub
+func drainEvents(sub *subscription) {
+	for {
+		select {
+		case <-sub.events:
+		default:
+			return
+		}
+	}
+}
+
+// asUser returns r as it looks after middleware.RequireAuth accepted userID's token
+func asUser(r *http.Request, userID string) *http.Request {
+	claims := &auth.UserClaims{UserID: userID, Username: userID}
+	return r.WithContext(context.WithValue(r.Context(), middleware.UserClaimsKey, claims))
+}
+
+// waitFor polls done under the room lock until it holds, in real time
+func waitFor(t *testing.T, what string, done func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		mu.Lock()
+		ok := done()
+		mu.Unlock()
+		if ok {
+			return
+		}
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
+
+func TestLeaveRoom(t *testing.T) {
+	tests := []struct {
+		name string
+		// setup fills the rooms and returns the one to leave
+		setup      func() *Room
+		userID     string
+		wantCode   int
+		wantStatus string // Room status afterwards, empty if it must be gone
+		wantQueued string // Player expected in the queue afterwards
+	}{
+		{
+			name:       "non-member is refused",
+			setup:      func() *Room { return addRoom("full", time.Now()) },
+			userID:     "mallory",
+			wantCode:   http.StatusForbidden,
+			wantStatus: "full",
+		},
+		{
+			name:     "last player closes a waiting room",
+			setup:    func() *Room { return queuePlayer("a", 1500, time.Now()) },
+			userID:   "a",
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "opponent leaving before the game requeues the other player",
+			setup: func() *Room {
+				room := addRoom("full", time.Now())
+				gameStarts.Add(room)
+				return room
+			},
+			userID:     "b",
+			wantCode:   http.StatusOK,
+			wantStatus: "waiting",
+			wantQueued: "a",
+		},
+		{
+			name: "opponent leaving while the game is being started closes the room",
+			setup: func() *Room {
+				room := addRoom("full", time.Now())
+				gameStarts.Add(room)
+				gameStarts.due(time.Now()) // Delivery under way
+				return room
+			},
+			userID:   "b",
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "opponent leaving a created game closes the room",
+			setup: func() *Room {
+				room := addRoom("full", time.Now())
+				room.GameCreated = true
+				return room
+			},
+			userID:   "b",
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "opponent leaving a private room closes it",
+			setup: func() *Room {
+				room := addRoom("full", time.Now())
+				room.Private = true
+				return room
+			},
+			userID:   "b",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:       "finished room is left for the reaper",
+			setup:      func() *Room { return addRoom("finished", time.Now()) },
+			userID:     "b",
+			wantCode:   http.StatusOK,
+			wantStatus: "finished",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetRooms(t)
+			room := tt.setup()
+
+			w := httptest.NewRecorder()
+			leaveRoomHandler(w, asUser(httptest.NewRequest(http.MethodPost, "/rooms/"+room.ID+"/leave", nil), tt.userID))
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			stored, exists := rooms[room.ID]
+			switch {
+			case tt.wantStatus == "" && exists:
+				t.Fatalf("room still exists as %s, want it closed", stored.Status)
+			case tt.wantStatus != "" && !exists:
+				t.Fatalf("room was closed, want it %s", tt.wantStatus)
+			case exists && stored.Status != tt.wantStatus:
+				t.Fatalf("room is %s, want %s", stored.Status, tt.wantStatus)
+			}
+			if tt.wantQueued != "" && queueEntryLocked(tt.wantQueued) == nil {
+				t.Fatalf("%s is not queued", tt.wantQueued)
+			}
+			// A start that can still be cancelled must not outlive the leave
+			for _, entry := range gameStarts.entries {
+				if !entry.delivering {
+					t.Fatalf("game start for room %s still pending, want it cancelled", entry.RoomID)
+				}
+			}
+		})
+	}
+}
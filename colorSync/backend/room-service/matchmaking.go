@@ -0,0 +1,275 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Flokots/programming-5/colorSync/shared/middleware"
+)
+
+// Matchmaking pairs players whose ratings are within a window that
+// widens the longer they wait, so new players meet players of similar
+// skill first and nobody waits forever
+const (
+	matchWindowBase   = 100             // Rating difference accepted straight away
+	matchWindowGrowth = 50              // Added for every matchWindowStep waited
+	matchWindowStep   = 5 * time.Second // Wait time per widening step
+	matchWindowMax    = 1000            // Never wider than this
+	matchInterval     = 1 * time.Second // How often the matchmaker re-checks the queue
+)
+
+// queueEntry is a player waiting for an opponent in their own waiting room
+type queueEntry struct {
+	UserID        string
+	Rating        int
+	RoomID        string
+	JoinedAt      time.Time
+	LastHeartbeat time.Time // Refreshed by heartbeats, ready polls and open event streams
+}
+
+// Matchmaking state, guarded by mu like rooms
+var (
+	queue       []*queueEntry             // Oldest first
+	roomAliases = make(map[string]string) // Merged waiting room ID -> room its player was moved to
+)
+
+// matchWindow is the largest rating difference accepted after waiting this long
+func matchWindow(waited time.Duration) int {
+	window := matchWindowBase + matchWindowGrowth*int(waited/matchWindowStep)
+	return min(window, matchWindowMax)
+}
+
+// canMatch reports whether both players accept each other's rating
+func canMatch(a, b *queueEntry, now time.Time) bool {
+	diff := a.Rating - b.Rating
+	if diff < 0 {
+		diff = -diff
+	}
+	window := min(matchWindow(now.Sub(a.JoinedAt)), matchWindow(now.Sub(b.JoinedAt)))
+	return diff <= window
+}
+
+// findOpponentLocked returns the queued player closest in rating that entry can play, or nil
+// Caller must hold mu
+func findOpponentLocked(entry *queueEntry, now time.Time) *queueEntry {
+	var best *queueEntry
+	bestDiff := 0
+	for _, candidate := range queue {
+		if candidate.UserID == entry.UserID || !canMatch(entry, candidate, now) {
+			continue
+		}
+		diff := entry.Rating - candidate.Rating
+		if diff < 0 {
+			diff = -diff
+		}
+		if best == nil || diff < bestDiff {
+			best, bestDiff = candidate, diff
+		}
+	}
+	return best
+}
+
+// enqueueLocked adds a player with a waiting room to the queue
+// Caller must hold mu
+func enqueueLocked(entry *queueEntry) {
+	if entry.LastHeartbeat.IsZero() {
+		entry.LastHeartbeat = entry.JoinedAt
+	}
+	queue = append(queue, entry)
+	log.Printf("User %s queued (rating %d, room %s, %d waiting)", entry.UserID, entry.Rating, entry.RoomID, len(queue))
+}
+
+// dequeueLocked removes a player from the queue if present
+// Caller must hold mu
+func dequeueLocked(userID string) {
+	for i, entry := range queue {
+		if entry.UserID == userID {
+			queue = append(queue[:i], queue[i+1:]...)
+			return
+		}
+	}
+}
+
+// pairLocked moves joiner into waiting's room and starts the game
+// If joiner had a waiting room of its own, that room becomes an alias
+// Caller must hold mu
+func pairLocked(waiting, joiner *queueEntry) *Room {
+	room := rooms[waiting.RoomID]
+	room.Players = append(room.Players, joiner.UserID)
+	room.Ratings[joiner.UserID] = joiner.Rating
+	room.Status = "full"
+	room.UpdatedAt = time.Now()
+
+	dequeueLocked(waiting.UserID)
+	dequeueLocked(joiner.UserID)
+
+	if joiner.RoomID != "" && joiner.RoomID != room.ID {
+		delete(rooms, joiner.RoomID)
+		roomAliases[joiner.RoomID] = room.ID
+		moveSubscribersLocked(joiner.RoomID, room.ID)
+	}
+	publishLocked(room.ID, RoomEvent{Type: EventOpponentJoined, Players: room.Players})
+
+	log.Printf("Matched %s (%d) with %s (%d) in room %s (ROOM FULL - 2/2 players)",
+		waiting.UserID, waiting.Rating, joiner.UserID, joiner.Rating, room.ID)
+
+	// Ask Game Service to start the game (delivered in the background, with retries)
+	gameStarts.Add(room)
+	return room
+}
+
+// runMatchmaker periodically pairs queued players whose windows have grown to overlap
+func runMatchmaker() {
+	ticker := time.NewTicker(matchInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		mu.Lock()
+		matchQueueLocked(now)
+		mu.Unlock()
+	}
+}
+
+// matchQueueLocked pairs as many queued players as possible, longest waiting first
+// The longer waiting player keeps their room
+// Caller must hold mu
+func matchQueueLocked(now time.Time) {
+	for paired := true; paired; {
+		paired = false
+		for _, entry := range queue {
+			if opponent := findOpponentLocked(entry, now); opponent != nil {
+				pairLocked(entry, opponent)
+				paired = true
+				break // Both entries are gone from the queue, scan it again from the start
+			}
+		}
+	}
+}
+
+// resolveRoomLocked finds a room by ID, following the alias of a merged waiting room
+// Caller must hold mu (read or write)
+func resolveRoomLocked(roomID string) (*Room, bool) {
+	if target, aliased := roomAliases[roomID]; aliased {
+		roomID = target
+	}
+	room, exists := rooms[roomID]
+	return room, exists
+}
+
+// deleteRoomLocked removes a room, aliases pointing at it, its queue entries and its pending game start
+// Anyone still listening gets room_closed with the reason
+// Caller must hold mu
+func deleteRoomLocked(room *Room, reason string) {
+	publishLocked(room.ID, RoomEvent{Type: EventRoomClosed, Reason: reason})
+	gameStarts.Cancel(room.ID)
+	delete(rooms, room.ID)
+	for alias, target := range roomAliases {
+		if target == room.ID {
+			delete(roomAliases, alias)
+		}
+	}
+	for _, playerID := range room.Players {
+		dequeueLocked(playerID)
+	}
+}
+
+// queueEntryLocked returns the user's queue entry, or nil if they aren't queued
+// Caller must hold mu
+func queueEntryLocked(userID string) *queueEntry {
+	for _, entry := range queue {
+		if entry.UserID == userID {
+			return entry
+		}
+	}
+	return nil
+}
+
+// heartbeatRoomLocked marks the player waiting in roomID as still there
+// Caller must hold mu
+func heartbeatRoomLocked(roomID string, now time.Time) {
+	for _, entry := range queue {
+		if entry.RoomID == roomID {
+			entry.LastHeartbeat = now
+		}
+	}
+}
+
+// joinRouter handles /join: POST enters the queue, DELETE leaves it
+// Middleware has already validated JWT
+func joinRouter(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		joinRoomHandler(w, r)
+	case http.MethodDelete:
+		cancelJoinHandler(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// cancelJoinHandler takes the caller out of the queue and closes their waiting room
+func cancelJoinHandler(w http.ResponseWriter, r *http.Request) {
+	// 1. Get user claims from JWT token (validated by middleware)
+	claims := middleware.GetUserClaims(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized - no user claims", http.StatusUnauthorized)
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	// 2. Find the queue entry
+	entry := queueEntryLocked(claims.UserID)
+	if entry == nil {
+		http.Error(w, "Not in matchmaking queue", http.StatusNotFound)
+		return
+	}
+
+	// 3. Remove it together with the waiting room
+	dequeueLocked(entry.UserID)
+	if room, exists := rooms[entry.RoomID]; exists && room.Status == "waiting" {
+		deleteRoomLocked(room, "cancelled")
+	}
+
+	log.Printf("User %s left the matchmaking queue (room %s)", claims.UserID, entry.RoomID)
+
+	respondJSON(w, http.StatusOK, map[string]string{
+		"message": "Left matchmaking queue",
+		"room_id": entry.RoomID,
+	})
+}
+
+// queueHeartbeatHandler keeps the caller's queue entry from being reaped
+// Clients holding the room event stream open don't need to call it
+func queueHeartbeatHandler(w http.ResponseWriter, r *http.Request) {
+	// 1. Only accept POST requests
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 2. Get user claims from JWT token (validated by middleware)
+	claims := middleware.GetUserClaims(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized - no user claims", http.StatusUnauthorized)
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	// 3. Refresh the entry
+	entry := queueEntryLocked(claims.UserID)
+	if entry == nil {
+		http.Error(w, "Not in matchmaking queue", http.StatusNotFound)
+		return
+	}
+	entry.LastHeartbeat = time.Now()
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"room_id":    entry.RoomID,
+		"expires_in": int(queueHeartbeatTTL.Seconds()),
+	})
+}
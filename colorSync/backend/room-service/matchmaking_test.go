@@ -0,0 +1,151 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMatchWindow(t *testing.T) {
+	tests := []struct {
+		waited time.Duration
+		want   int
+	}{
+		{0, matchWindowBase},
+		{matchWindowStep - time.Millisecond, matchWindowBase},
+		{matchWindowStep, matchWindowBase + matchWindowGrowth},
+		{3 * matchWindowStep, matchWindowBase + 3*matchWindowGrowth},
+		{time.Hour, matchWindowMax},
+	}
+	for _, tt := range tests {
+		if got := matchWindow(tt.waited); got != tt.want {
+			t.Errorf("matchWindow(%s) = %d, want %d", tt.waited, got, tt.want)
+		}
+	}
+}
+
+func TestCanMatch(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		ratingA int
+		waitedA time.Duration
+		ratingB int
+		waitedB time.Duration
+		want    bool
+	}{
+		{"close ratings match straight away", 1500, 0, 1600, 0, true},
+		{"too far apart at first", 1500, 0, 1700, 0, false},
+		{"window widens with waiting", 1500, 2 * matchWindowStep, 1700, 2 * matchWindowStep, true},
+		{"newcomer's narrow window decides", 1500, time.Minute, 1700, 0, false},
+		{"never beyond the maximum", 1000, time.Hour, 2100, time.Hour, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &queueEntry{UserID: "a", Rating: tt.ratingA, JoinedAt: now.Add(-tt.waitedA)}
+			b := &queueEntry{UserID: "b", Rating: tt.ratingB, JoinedAt: now.Add(-tt.waitedB)}
+			if got := canMatch(a, b, now); got != tt.want {
+				t.Fatalf("canMatch = %t, want %t", got, tt.want)
+			}
+			if got := canMatch(b, a, now); got != tt.want {
+				t.Fatalf("canMatch reversed = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchQueuePairsEveryCompatiblePlayer(t *testing.T) {
+	resetRooms(t)
+	now := time.Now()
+
+	// Three pairs, interleaved so each pairing removes entries from the middle of the queue
+	for _, player := range []struct {
+		id     string
+		rating int
+	}{
+		{"a", 1000}, {"b", 1500}, {"c", 2000}, {"d", 1010}, {"e", 1510}, {"f", 2010},
+	} {
+		queuePlayer(player.id, player.rating, now)
+	}
+
+	matchQueueLocked(now)
+
+	if len(queue) != 0 {
+		t.Fatalf("%d players left in the queue, want 0", len(queue))
+	}
+	var pairs [][]string
+	for _, room := range rooms {
+		if room.Status != "full" {
+			t.Fatalf("room %s is %s with %v, want full", room.ID, room.Status, room.Players)
+		}
+		pairs = append(pairs, slices.Sorted(slices.Values(room.Players)))
+	}
+	slices.SortFunc(pairs, func(x, y []string) int { return slices.Compare(x, y) })
+	want := [][]string{{"a", "d"}, {"b", "e"}, {"c", "f"}}
+	if !slices.EqualFunc(pairs, want, slices.Equal) {
+		t.Fatalf("pairs = %v, want %v", pairs, want)
+	}
+}
+
+func TestMatchQueuePrefersClosestRating(t *testing.T) {
+	resetRooms(t)
+	now := time.Now()
+
+	waiting := queuePlayer("waiting", 1500, now)
+	queuePlayer("far", 1590, now)
+	queuePlayer("near", 1520, now)
+
+	matchQueueLocked(now)
+
+	if !slices.Equal(waiting.Players, []string{"waiting", "near"}) {
+		t.Fatalf("players = %v, want waiting paired with near", waiting.Players)
+	}
+	if len(queue) != 1 || queue[0].UserID != "far" {
+		t.Fatalf("queue = %v, want only far left", queue)
+	}
+}
+
+func TestCancelJoin(t *testing.T) {
+	t.Run("queued player leaves with their waiting room", func(t *testing.T) {
+		resetRooms(t)
+		room := queuePlayer("a", 1500, time.Now())
+		sub := subscribeLocked(room)
+
+		w := httptest.NewRecorder()
+		joinRouter(w, asUser(httptest.NewRequest(http.MethodDelete, "/join", nil), "a"))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+		}
+		if len(queue) != 0 {
+			t.Fatalf("queue = %v, want empty", queue)
+		}
+		if _, exists := rooms[room.ID]; exists {
+			t.Fatal("waiting room still exists")
+		}
+		if event := <-sub.events; event.Type != EventRoomClosed || event.Reason != "cancelled" {
+			t.Fatalf("event = %+v, want room_closed because it was cancelled", event)
+		}
+	})
+
+	t.Run("matched player has nothing to cancel", func(t *testing.T) {
+		resetRooms(t)
+		now := time.Now()
+		room := queuePlayer("a", 1500, now)
+		queuePlayer("b", 1500, now)
+		matchQueueLocked(now)
+
+		w := httptest.NewRecorder()
+		joinRouter(w, asUser(httptest.NewRequest(http.MethodDelete, "/join", nil), "b"))
+
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("status = %d, want 404: %s", w.Code, w.Body)
+		}
+		if rooms[room.ID].Status != "full" {
+			t.Fatalf("room is %s, want it still full", rooms[room.ID].Status)
+		}
+	})
+}
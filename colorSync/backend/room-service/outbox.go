@@ -0,0 +1,335 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Flokots/programming-5/colorSync/shared/fileutil"
+	"github.com/Flokots/programming-5/colorSync/shared/gameconfig"
+)
+
+// Game start requests go through a durable outbox: they are written to disk
+// before Game Service is called and retried with exponential backoff until it
+// accepts them, surviving Game Service outages and Room Service restarts
+const (
+	outboxMaxAttempts  = 6
+	outboxBaseBackoff  = 500 * time.Millisecond // Doubled after each failed attempt
+	outboxMaxBackoff   = 30 * time.Second
+	outboxIdleInterval = time.Minute // Wake up at least this often even with nothing due
+)
+
+// errGameRejected marks responses that won't get better by retrying
+var errGameRejected = errors.New("game service rejected the request")
+
+// outboxEntry is one pending game start
+// It keeps enough of the room to bring it back after a restart, since rooms live in memory only
+type outboxEntry struct {
+	RoomID      string             `json:"room_id"`
+	Players     []string           `json:"players"`
+	Ratings     map[string]int     `json:"ratings,omitempty"`
+	Private     bool               `json:"private,omitempty"`
+	Config      *gameconfig.Config `json:"config,omitempty"`
+	Attempts    int                `json:"attempts"`
+	NextAttempt time.Time          `json:"next_attempt"`
+	LastError   string             `json:"last_error,omitempty"`
+	CreatedAt   time.Time          `json:"created_at"`
+
+	delivering bool // An attempt is under way, so it can no longer be cancelled
+}
+
+// outbox keeps pending entries in memory and mirrors them to path
+// Add and Cancel are called with the room lock held, so they only mark the
+// entries dirty; the delivery loop writes the file, normally before the first attempt
+type outbox struct {
+	path    string
+	mu      sync.Mutex
+	entries map[string]*outboxEntry // roomID -> entry
+	dirty   bool                    // Entries changed since the last save
+	saveMu  sync.Mutex              // Serializes saves, so an older snapshot never overwrites a newer one
+	wake    chan struct{}
+}
+
+// gameStarts delivers POST /game/start, opened in main
+var gameStarts *outbox
+
+// openOutbox loads pending entries left over from a previous run
+func openOutbox(path string) (*outbox, error) {
+	o := &outbox{
+		path:    path,
+		entries: make(map[string]*outboxEntry),
+		wake:    make(chan struct{}, 1),
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return o, nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to read outbox: %w", err)
+	}
+
+	var pending []*outboxEntry
+	if err := json.Unmarshal(data, &pending); err != nil {
+		return nil, fmt.Errorf("failed to parse outbox: %w", err)
+	}
+	for _, entry := range pending {
+		o.entries[entry.RoomID] = entry
+	}
+
+	log.Printf("Outbox loaded from %s (%d pending game starts)", path, len(pending))
+	return o, nil
+}
+
+// restoreRooms puts the rooms of pending entries back after a restart,
+// so a delivered game still has a room to mark ready and report to
+// Rooms that already exist are left alone
+func (o *outbox) restoreRooms() {
+	mu.Lock()
+	defer mu.Unlock()
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, entry := range o.entries {
+		if _, exists := rooms[entry.RoomID]; exists {
+			continue
+		}
+		ratings := maps.Clone(entry.Ratings)
+		if ratings == nil {
+			ratings = make(map[string]int)
+		}
+		rooms[entry.RoomID] = &Room{
+			ID:        entry.RoomID,
+			Players:   slices.Clone(entry.Players),
+			Ratings:   ratings,
+			Status:    "full",
+			Private:   entry.Private,
+			Config:    entry.Config,
+			CreatedAt: entry.CreatedAt,
+			UpdatedAt: time.Now(),
+		}
+		log.Printf("Restored room %s from the outbox, its game is still being started", entry.RoomID)
+	}
+}
+
+// Add queues a game start for a full room and wakes the delivery loop
+// Adding a room that is already pending is a no-op
+func (o *outbox) Add(room *Room) {
+	o.mu.Lock()
+	if _, pending := o.entries[room.ID]; !pending {
+		now := time.Now()
+		o.entries[room.ID] = &outboxEntry{
+			RoomID:      room.ID,
+			Players:     slices.Clone(room.Players),
+			Ratings:     maps.Clone(room.Ratings),
+			Private:     room.Private,
+			Config:      room.Config,
+			NextAttempt: now,
+			CreatedAt:   now,
+		}
+		o.dirty = true
+	}
+	o.mu.Unlock()
+
+	o.wakeUp()
+}
+
+// Cancel drops the pending start of a room that no longer wants its game
+// Returns false if there was nothing to cancel or an attempt is already under way,
+// in which case Game Service may end up holding a game for the room
+func (o *outbox) Cancel(roomID string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	entry, pending := o.entries[roomID]
+	if !pending || entry.delivering {
+		return false
+	}
+	delete(o.entries, roomID)
+	o.dirty = true
+	log.Printf("Cancelled game start for room %s", roomID)
+	o.wakeUp()
+	return true
+}
+
+// wakeUp has the delivery loop look at the entries again
+func (o *outbox) wakeUp() {
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run saves and delivers due entries until the process exits
+// Each room's start is attempted in its own goroutine, so a slow Game Service
+// call only holds up its own room
+func (o *outbox) run() {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+		case <-o.wake:
+		}
+
+		o.save()
+		for _, entry := range o.due(time.Now()) {
+			go o.deliver(entry)
+		}
+
+		timer.Reset(o.untilNext(time.Now()))
+	}
+}
+
+// due returns copies of the entries whose next attempt has come and marks them as being delivered
+// Entries already being delivered are left to their attempt
+func (o *outbox) due(now time.Time) []outboxEntry {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var due []outboxEntry
+	for _, entry := range o.entries {
+		if !entry.delivering && !now.Before(entry.NextAttempt) {
+			entry.delivering = true
+			due = append(due, *entry)
+		}
+	}
+	return due
+}
+
+// untilNext is how long to sleep before the earliest pending attempt
+// An attempt that fails wakes the loop up with its next one
+func (o *outbox) untilNext(now time.Time) time.Duration {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	wait := outboxIdleInterval
+	if o.dirty {
+		wait = outboxBaseBackoff // The last save failed, try again soon
+	}
+	for _, entry := range o.entries {
+		if !entry.delivering {
+			wait = min(wait, max(entry.NextAttempt.Sub(now), 0))
+		}
+	}
+	return wait
+}
+
+// deliver makes one attempt and records the outcome
+func (o *outbox) deliver(entry outboxEntry) {
+	err := notifyGameService(entry.RoomID, entry.Players, entry.Config)
+	if err == nil {
+		o.remove(entry.RoomID)
+		gameCreated(entry.RoomID)
+		return
+	}
+
+	attempts := entry.Attempts + 1
+	if errors.Is(err, errGameRejected) || attempts >= outboxMaxAttempts {
+		log.Printf("Giving up on starting game for room %s after %d attempts: %v", entry.RoomID, attempts, err)
+		o.remove(entry.RoomID)
+		gameStartFailed(entry.RoomID)
+		return
+	}
+
+	backoff := min(outboxBaseBackoff<<(attempts-1), outboxMaxBackoff)
+	log.Printf("Starting game for room %s failed (attempt %d/%d), retrying in %s: %v",
+		entry.RoomID, attempts, outboxMaxAttempts, backoff, err)
+
+	o.mu.Lock()
+	if pending, exists := o.entries[entry.RoomID]; exists {
+		pending.delivering = false
+		pending.Attempts = attempts
+		pending.NextAttempt = time.Now().Add(backoff)
+		pending.LastError = err.Error()
+		o.dirty = true
+	}
+	o.mu.Unlock()
+
+	o.save()
+	o.wakeUp()
+}
+
+func (o *outbox) remove(roomID string) {
+	o.mu.Lock()
+	delete(o.entries, roomID)
+	o.dirty = true
+	o.mu.Unlock()
+
+	o.save()
+}
+
+// save writes all pending entries to disk atomically, if they changed
+// The entries are copied under o.mu and written without it, so Add and Cancel never wait on the disk
+// A failed save is tried again by the delivery loop; until then entries are only delivered from memory
+func (o *outbox) save() {
+	o.saveMu.Lock()
+	defer o.saveMu.Unlock()
+
+	o.mu.Lock()
+	if !o.dirty {
+		o.mu.Unlock()
+		return
+	}
+	pending := make([]outboxEntry, 0, len(o.entries))
+	for _, entry := range o.entries {
+		pending = append(pending, *entry)
+	}
+	o.dirty = false
+	o.mu.Unlock()
+
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].CreatedAt.Before(pending[j].CreatedAt)
+	})
+	data, err := json.MarshalIndent(pending, "", "  ")
+	if err == nil {
+		err = fileutil.WriteAtomic(o.path, data)
+	}
+	if err != nil {
+		log.Printf("Failed to persist outbox: %v", err)
+		o.mu.Lock()
+		o.dirty = true
+		o.mu.Unlock()
+	}
+}
+
+// gameCreated marks the room as ready to connect
+func gameCreated(roomID string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if room, exists := rooms[roomID]; exists {
+		room.GameCreated = true
+		room.UpdatedAt = time.Now()
+		publishLocked(roomID, RoomEvent{Type: EventGameCreated, Players: room.Players})
+	}
+}
+
+// gameStartFailed puts the room in the error state so clients stop waiting
+func gameStartFailed(roomID string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if room, exists := rooms[roomID]; exists && room.Status == "full" {
+		room.Status = "error"
+		room.Error = "Game could not be started, please join again"
+		room.UpdatedAt = time.Now()
+		publishLocked(roomID, RoomEvent{Type: EventRoomError, Players: room.Players, Reason: room.Error})
+	}
+}
+
+// getEnv returns the environment variable value or a fallback
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
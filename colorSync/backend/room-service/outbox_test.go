@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Flokots/programming-5/colorSync/shared/auth"
+)
+
+func TestOutboxRestoresRoomsAfterRestart(t *testing.T) {
+	resetRooms(t)
+	path := filepath.Join(t.TempDir(), "outbox.json")
+	pending, err := openOutbox(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	room := addRoom("full", time.Now())
+	room.Ratings["b"] = 1620
+	pending.Add(room)
+	pending.save()
+
+	// Restart: rooms are gone, the outbox is read back
+	rooms = make(map[string]*Room)
+	reopened, err := openOutbox(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reopened.restoreRooms()
+
+	restored, exists := rooms[room.ID]
+	if !exists {
+		t.Fatal("room was not restored")
+	}
+	if restored.Status != "full" || !slices.Equal(restored.Players, room.Players) || restored.Ratings["b"] != 1620 {
+		t.Fatalf("restored %+v, want the full room with its players and ratings", restored)
+	}
+
+	// The redelivered start now has a room to mark
+	reopened.remove(room.ID)
+	gameCreated(room.ID)
+	if !restored.GameCreated {
+		t.Fatal("restored room was not marked as created")
+	}
+}
+
+func TestOutboxCancel(t *testing.T) {
+	resetRooms(t)
+	path := filepath.Join(t.TempDir(), "outbox.json")
+	pending, err := openOutbox(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queued, delivering := addRoom("full", time.Now()), addRoom("full", time.Now())
+	pending.Add(delivering)
+	pending.due(time.Now())
+	pending.Add(queued)
+
+	if !pending.Cancel(queued.ID) {
+		t.Fatal("Cancel of a queued start = false, want true")
+	}
+	if pending.Cancel(queued.ID) {
+		t.Fatal("second Cancel = true, want false")
+	}
+	if pending.Cancel(delivering.ID) {
+		t.Fatal("Cancel during delivery = true, want false")
+	}
+	pending.save()
+
+	reopened, err := openOutbox(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := reopened.entries[queued.ID]; exists {
+		t.Fatal("cancelled start is back after a restart")
+	}
+	if _, exists := reopened.entries[delivering.ID]; !exists {
+		t.Fatal("start under delivery was lost")
+	}
+}
+
+// useGameService points game starts at handler, with service tokens signed by development keys
+func useGameService(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+	t.Setenv("AUTH_DEV_KEYS", "1")
+	if err := auth.LoadServiceKeys(auth.RoomService); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	saved := gameServiceURL
+	gameServiceURL = server.URL
+	t.Cleanup(func() { gameServiceURL = saved })
+}
+
+func TestOutboxSlowStartHoldsUpOnlyItsRoom(t *testing.T) {
+	resetRooms(t)
+	stalled, later, cancelled := addRoom("full", time.Now()), addRoom("full", time.Now()), addRoom("full", time.Now())
+
+	// Game Service hangs on the first room's start until the test ends
+	arrived, release := make(chan struct{}), make(chan struct{})
+	useGameService(t, func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			RoomID string `json:"room_id"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.RoomID == stalled.ID {
+			close(arrived)
+			<-release
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	pending := gameStarts
+	t.Cleanup(func() {
+		// Let the stalled start finish and wait for its last save, before the outbox's directory goes
+		close(release)
+		for {
+			pending.mu.Lock()
+			settled := len(pending.entries) == 0 && !pending.dirty
+			pending.mu.Unlock()
+			if settled {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		pending.saveMu.Lock()
+		pending.saveMu.Unlock()
+	})
+	go pending.run()
+	mu.Lock()
+	pending.Add(stalled)
+	mu.Unlock()
+	<-arrived
+
+	// A room queued after it can still be cancelled before its own attempt
+	mu.Lock()
+	pending.Add(cancelled)
+	ok := pending.Cancel(cancelled.ID)
+	mu.Unlock()
+	if !ok {
+		t.Fatal("Cancel of a start queued behind a stalled one = false, want true")
+	}
+
+	// and one that isn't cancelled gets its game without waiting for the first
+	mu.Lock()
+	pending.Add(later)
+	mu.Unlock()
+	waitFor(t, "the later room's game", func() bool { return later.GameCreated })
+
+	if pending.Cancel(stalled.ID) {
+		t.Fatal("Cancel of the start under way = true, want false")
+	}
+	if stalled.GameCreated || cancelled.GameCreated {
+		t.Fatal("a stalled or cancelled room got its game")
+	}
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Flokots/programming-5/colorSync/shared/gameconfig"
+	"github.com/Flokots/programming-5/colorSync/shared/middleware"
+)
+
+// Private rooms skip the queue; the creator shares an invite code with one opponent
+const (
+	inviteCodeLength = 6
+	inviteCodeTTL    = 10 * time.Minute
+
+	// No 0/O, 1/I/L so codes survive being read out loud
+	inviteCodeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+)
+
+// invite ties a code to the private room it opens, guarded by mu
+type invite struct {
+	RoomID    string
+	ExpiresAt time.Time
+}
+
+var invites = make(map[string]*invite) // code -> invite
+
+// CreateRoomRequest is the optional body of POST /rooms
+type CreateRoomRequest struct {
+	Config *gameconfig.Config `json:"config,omitempty"` // Missing fields use the defaults
+}
+
+type CreateRoomResponse struct {
+	RoomID     string            `json:"room_id"`
+	InviteCode string            `json:"invite_code"`
+	ExpiresAt  time.Time         `json:"expires_at"`
+	Status     string            `json:"status"`
+	Config     gameconfig.Config `json:"config"`
+}
+
+// newInviteCodeLocked returns a random code that isn't currently in use
+// Caller must hold mu
+func newInviteCodeLocked() (string, error) {
+	alphabetSize := big.NewInt(int64(len(inviteCodeAlphabet)))
+	for range 10 {
+		var code strings.Builder
+		for range inviteCodeLength {
+			n, err := rand.Int(rand.Reader, alphabetSize)
+			if err != nil {
+				return "", err
+			}
+			code.WriteByte(inviteCodeAlphabet[n.Int64()])
+		}
+		if _, taken := invites[code.String()]; !taken {
+			return code.String(), nil
+		}
+	}
+	return "", errors.New("could not find a free invite code")
+}
+
+// normalizeInviteCode accepts codes typed in lower case or with separators
+func normalizeInviteCode(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
+
+// expireInvitesLocked drops expired codes and closes private rooms nobody joined
+// Caller must hold mu
+func expireInvitesLocked(now time.Time) {
+	for code, inv := range invites {
+		if now.Before(inv.ExpiresAt) {
+			continue
+		}
+		delete(invites, code)
+
+		if room, exists := rooms[inv.RoomID]; exists && room.Status == "waiting" {
+			deleteRoomLocked(room, "invite_expired")
+			log.Printf("Invite %s expired, private room %s closed", code, room.ID)
+		}
+	}
+}
+
+// userInRoomLocked returns the room the user is already in, if any
+// Caller must hold mu
+func userInRoomLocked(userID string) *Room {
+	for _, room := range rooms {
+		for _, playerID := range room.Players {
+			if playerID == userID {
+				return room
+			}
+		}
+	}
+	return nil
+}
+
+// createPrivateRoomHandler creates a room that can only be entered with its invite code
+// Wrapped in middleware.RequireAuth
+func createPrivateRoomHandler(w http.ResponseWriter, r *http.Request) {
+	// 1. Only accept POST requests
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 2. Get user claims from JWT token (validated by middleware)
+	claims := middleware.GetUserClaims(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized - no user claims", http.StatusUnauthorized)
+		return
+	}
+
+	// 3. Body is optional, the creator may pick the game rules
+	var req CreateRoomRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	config := gameconfig.Default()
+	if req.Config != nil {
+		config = *req.Config
+	}
+	if err := config.Validate(); err != nil {
+		http.Error(w, "Invalid game config: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// 4. Verify user exists and is allowed to play
+	user, err := verifyUser(claims.UserID)
+	if err != nil {
+		respondVerifyError(w, err)
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	now := time.Now()
+	expireInvitesLocked(now)
+
+	// 5. One room at a time
+	if existing := userInRoomLocked(claims.UserID); existing != nil {
+		log.Printf("⚠️ User %s already in room %s", claims.UserID, existing.ID)
+		http.Error(w, "You are already in a room", http.StatusConflict)
+		return
+	}
+
+	// 6. Create the room and its code
+	code, err := newInviteCodeLocked()
+	if err != nil {
+		log.Printf("Failed to generate invite code: %v", err)
+		http.Error(w, "Failed to create room", http.StatusInternalServerError)
+		return
+	}
+
+	room := newRoom(claims.UserID, user.Rating, true)
+	room.Config = &config
+	rooms[room.ID] = room
+	invites[code] = &invite{RoomID: room.ID, ExpiresAt: now.Add(inviteCodeTTL)}
+
+	log.Printf("User %s created private room %s (invite %s)", claims.UserID, room.ID, code)
+
+	respondJSON(w, http.StatusCreated, CreateRoomResponse{
+		RoomID:     room.ID,
+		InviteCode: code,
+		ExpiresAt:  invites[code].ExpiresAt,
+		Status:     room.Status,
+		Config:     config,
+	})
+}
+
+// joinPrivateRoomHandler enters the private room behind an invite code
+// URL format: /rooms/join/{code}
+// Wrapped in middleware.RequireAuth
+func joinPrivateRoomHandler(w http.ResponseWriter, r *http.Request) {
+	// 1. Only accept POST requests
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 2. Get user claims from JWT token (validated by middleware)
+	claims := middleware.GetUserClaims(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized - no user claims", http.StatusUnauthorized)
+		return
+	}
+
+	// 3. Extract invite code from URL path
+	code := normalizeInviteCode(strings.TrimPrefix(r.URL.Path, "/rooms/join/"))
+	if code == "" {
+		http.Error(w, "Invite code required", http.StatusBadRequest)
+		return
+	}
+
+	// 4. Verify user exists and is allowed to play
+	user, err := verifyUser(claims.UserID)
+	if err != nil {
+		respondVerifyError(w, err)
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	// 5. Look up the code (expired codes are reported as such, not as unknown)
+	inv, exists := invites[code]
+	if !exists {
+		http.Error(w, "Invite code not found", http.StatusNotFound)
+		return
+	}
+	if time.Now().After(inv.ExpiresAt) {
+		expireInvitesLocked(time.Now())
+		http.Error(w, "Invite code has expired", http.StatusGone)
+		return
+	}
+
+	room, exists := rooms[inv.RoomID]
+	if !exists {
+		delete(invites, code)
+		http.Error(w, "Invite code not found", http.StatusNotFound)
+		return
+	}
+
+	// 6. Check the room can take this player
+	if room.Players[0] == claims.UserID {
+		http.Error(w, "Cannot join your own room - share the code with your opponent", http.StatusConflict)
+		return
+	}
+	if existing := userInRoomLocked(claims.UserID); existing != nil {
+		log.Printf("⚠️ User %s already in room %s", claims.UserID, existing.ID)
+		http.Error(w, "You are already in a room", http.StatusConflict)
+		return
+	}
+	if len(room.Players) >= 2 {
+		http.Error(w, "Room is full", http.StatusConflict)
+		return
+	}
+
+	// 7. Join and start the game; codes are single use
+	room.Players = append(room.Players, claims.UserID)
+	room.Ratings[claims.UserID] = user.Rating
+	room.Status = "full"
+	room.UpdatedAt = time.Now()
+	delete(invites, code)
+	publishLocked(room.ID, RoomEvent{Type: EventOpponentJoined, Players: room.Players})
+
+	log.Printf("User %s joined private room %s with invite %s (ROOM FULL - 2/2 players)", claims.UserID, room.ID, code)
+
+	gameStarts.Add(room) // Delivered in the background, with retries
+
+	respondJSON(w, http.StatusOK, JoinResponse{
+		RoomID:  room.ID,
+		Players: room.Players,
+		Status:  room.Status,
+		Message: fmt.Sprintf("Joined room %s", room.ID),
+	})
+}
+
+// respondVerifyError maps verifyUser errors to HTTP responses
+func respondVerifyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrUserNotFound):
+		http.Error(w, "User not found", http.StatusNotFound)
+	case errors.Is(err, ErrUserInactive):
+		http.Error(w, "Account is not active", http.StatusForbidden)
+	default:
+		http.Error(w, "User Service unavailable, try again later", http.StatusServiceUnavailable)
+	}
+}
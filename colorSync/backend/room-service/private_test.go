@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// addPrivateRoom opens a private room for a with an invite that expires at expiresAt
+func addPrivateRoom(expiresAt time.Time) (*Room, string) {
+	room := newRoom("a", 1500, true)
+	rooms[room.ID] = room
+	code, _ := newInviteCodeLocked()
+	invites[code] = &invite{RoomID: room.ID, ExpiresAt: expiresAt}
+	return room, code
+}
+
+func TestExpireInvites(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		expiresAt  time.Time
+		full       bool // An opponent already joined
+		wantInvite bool
+		wantRoom   bool
+	}{
+		{"unexpired invite is kept", now.Add(time.Second), false, true, true},
+		{"expired invite closes the unjoined room", now, false, false, false},
+		{"expired invite leaves a joined room alone", now.Add(-time.Minute), true, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetRooms(t)
+			room, code := addPrivateRoom(tt.expiresAt)
+			if tt.full {
+				room.Players = append(room.Players, "b")
+				room.Status = "full"
+			}
+
+			reapLocked(now)
+
+			if _, exists := invites[code]; exists != tt.wantInvite {
+				t.Fatalf("invite kept = %t, want %t", exists, tt.wantInvite)
+			}
+			if _, exists := rooms[room.ID]; exists != tt.wantRoom {
+				t.Fatalf("room kept = %t, want %t", exists, tt.wantRoom)
+			}
+		})
+	}
+}
+
+func TestInviteCodes(t *testing.T) {
+	resetRooms(t)
+
+	code, err := newInviteCodeLocked()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(code) != inviteCodeLength {
+		t.Fatalf("code %q has %d characters, want %d", code, len(code), inviteCodeLength)
+	}
+	for _, c := range code {
+		if !strings.ContainsRune(inviteCodeAlphabet, c) {
+			t.Fatalf("code %q uses %q, which is not in the alphabet", code, c)
+		}
+	}
+
+	for typed, want := range map[string]string{
+		"abc-def":    "ABCDEF",
+		" AB CD EF ": "ABCDEF",
+		"ABCDEF":     "ABCDEF",
+	} {
+		if got := normalizeInviteCode(typed); got != want {
+			t.Errorf("normalizeInviteCode(%q) = %q, want %q", typed, got, want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"log"
+	"slices"
+	"time"
+)
+
+// The reaper keeps rooms from piling up when clients vanish without leaving
+const (
+	reapInterval = 5 * time.Second
+
+	// A queued player with no heartbeat, ready poll or open event stream for this long is gone
+	queueHeartbeatTTL = 30 * time.Second
+
+	// Finished and failed rooms stay around briefly so players can still read them
+	finishedRoomRetention = 2 * time.Minute
+
+	// Upper bound for any room that stopped changing, e.g. both players crashed mid-game
+	staleRoomAge = 1 * time.Hour
+)
+
+// runReaper periodically removes abandoned and finished rooms
+func runReaper() {
+	ticker := time.NewTicker(reapInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		mu.Lock()
+		reapLocked(now)
+		mu.Unlock()
+	}
+}
+
+// reapLocked does one reaper pass
+// Caller must hold mu
+func reapLocked(now time.Time) {
+	expireInvitesLocked(now)
+
+	// Queue entries whose player stopped checking in
+	for _, entry := range slices.Clone(queue) {
+		if len(subscribers[entry.RoomID]) > 0 {
+			entry.LastHeartbeat = now // An open event stream is a heartbeat
+			continue
+		}
+		if now.Sub(entry.LastHeartbeat) <= queueHeartbeatTTL {
+			continue
+		}
+
+		dequeueLocked(entry.UserID)
+		if room, exists := rooms[entry.RoomID]; exists && room.Status == "waiting" {
+			deleteRoomLocked(room, "abandoned")
+		}
+		log.Printf("Reaped queue entry of %s (no heartbeat for %s)", entry.UserID, now.Sub(entry.LastHeartbeat).Round(time.Second))
+	}
+
+	// Rooms that are done or forgotten
+	for _, room := range rooms {
+		idle := now.Sub(room.UpdatedAt)
+		switch {
+		case (room.Status == "finished" || room.Status == "error") && idle > finishedRoomRetention:
+			deleteRoomLocked(room, room.Status)
+			log.Printf("Reaped %s room %s", room.Status, room.ID)
+		case idle > staleRoomAge:
+			deleteRoomLocked(room, "expired")
+			log.Printf("Reaped stale room %s (%s, idle %s)", room.ID, room.Status, idle.Round(time.Second))
+		}
+	}
+}
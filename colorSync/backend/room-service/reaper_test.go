@@ -0,0 +1,105 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReap(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		// setup fills the rooms and returns the one under test
+		setup      func() *Room
+		wantKept   bool
+		wantQueued bool // The room's first player is still queued
+	}{
+		{
+			name: "queued player with a recent heartbeat stays",
+			setup: func() *Room {
+				return queuePlayer("a", 1500, now.Add(-queueHeartbeatTTL/2))
+			},
+			wantKept:   true,
+			wantQueued: true,
+		},
+		{
+			name: "queued player without a heartbeat is reaped",
+			setup: func() *Room {
+				return queuePlayer("a", 1500, now.Add(-queueHeartbeatTTL-time.Second))
+			},
+		},
+		{
+			name: "open event stream counts as a heartbeat",
+			setup: func() *Room {
+				room := queuePlayer("a", 1500, now.Add(-queueHeartbeatTTL-time.Second))
+				subscribeLocked(room)
+				return room
+			},
+			wantKept:   true,
+			wantQueued: true,
+		},
+		{
+			name: "finished room is kept for a while",
+			setup: func() *Room {
+				return addRoom("finished", now.Add(-finishedRoomRetention/2))
+			},
+			wantKept: true,
+		},
+		{
+			name: "finished room is reaped after the retention",
+			setup: func() *Room {
+				return addRoom("finished", now.Add(-finishedRoomRetention-time.Second))
+			},
+		},
+		{
+			name: "error room is reaped after the retention",
+			setup: func() *Room {
+				return addRoom("error", now.Add(-finishedRoomRetention-time.Second))
+			},
+		},
+		{
+			name: "game in progress is kept",
+			setup: func() *Room {
+				return addRoom("in_progress", now.Add(-staleRoomAge/2))
+			},
+			wantKept: true,
+		},
+		{
+			name: "room idle past the stale age is reaped",
+			setup: func() *Room {
+				return addRoom("in_progress", now.Add(-staleRoomAge-time.Second))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetRooms(t)
+			room := tt.setup()
+
+			reapLocked(now)
+
+			if _, kept := rooms[room.ID]; kept != tt.wantKept {
+				t.Fatalf("room kept = %t, want %t", kept, tt.wantKept)
+			}
+			if queued := queueEntryLocked(room.Players[0]) != nil; queued != tt.wantQueued {
+				t.Fatalf("player queued = %t, want %t", queued, tt.wantQueued)
+			}
+		})
+	}
+}
+
+func TestReapClosesEventStreams(t *testing.T) {
+	resetRooms(t)
+	now := time.Now()
+	room := addRoom("finished", now.Add(-finishedRoomRetention-time.Second))
+	sub := subscribeLocked(room)
+	drainEvents(sub)
+
+	reapLocked(now)
+
+	event := <-sub.events
+	if event.Type != EventRoomClosed || event.Reason != "finished" {
+		t.Fatalf("event = %+v, want room_closed because the room finished", event)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Flokots/programming-5/colorSync/shared/auth"
+)
+
+// User Service lookups go through the authenticated internal endpoint,
+// so account status (banned, deleted) is enforced at join time
+var userServiceTokens = auth.NewServiceTokenSource(auth.RoomService, auth.UserService, auth.ScopeUsersRead)
+
+// userServiceClient never waits on a hung User Service for long
+var userServiceClient = &http.Client{Timeout: 3 * time.Second}
+
+const (
+	userLookupAttempts = 3
+	userLookupBackoff  = 200 * time.Millisecond // Doubled after each failed attempt
+)
+
+var (
+	ErrUserNotFound           = errors.New("user not found")
+	ErrUserInactive           = errors.New("user account is not active")
+	ErrUserServiceUnavailable = errors.New("user service unavailable")
+)
+
+// UserInfo is what User Service returns from GET /internal/users/{id}
+type UserInfo struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Status   string `json:"status"`
+	Rating   int    `json:"rating"`
+}
+
+// verifyUser checks with User Service that the user exists and may play
+// Returns the user (with their rating) or ErrUserNotFound, ErrUserInactive or ErrUserServiceUnavailable
+func verifyUser(userID string) (*UserInfo, error) {
+	backoff := userLookupBackoff
+
+	for attempt := 1; ; attempt++ {
+		user, err := lookupUser(userID)
+		if err == nil {
+			if user.Status != "active" {
+				log.Printf("User %s is %s", userID, user.Status)
+				return nil, ErrUserInactive
+			}
+			log.Printf("Verified user %s exists", userID)
+			return user, nil
+		}
+		if errors.Is(err, ErrUserNotFound) {
+			log.Printf("User %s not found in User Service", userID)
+			return nil, err
+		}
+
+		log.Printf("User lookup for %s failed (attempt %d/%d): %v", userID, attempt, userLookupAttempts, err)
+		if attempt == userLookupAttempts {
+			return nil, ErrUserServiceUnavailable
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// lookupUser makes one call to User Service
+// ErrUserNotFound is final, any other error is worth retrying
+func lookupUser(userID string) (*UserInfo, error) {
+	url := fmt.Sprintf("%s/internal/users/%s", userServiceURL, userID)
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := userServiceTokens.Do(userServiceClient, req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, ErrUserNotFound
+	default:
+		return nil, fmt.Errorf("user service returned status %d", resp.StatusCode)
+	}
+
+	var user UserInfo
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return nil, fmt.Errorf("failed to decode user: %w", err)
+	}
+	return &user, nil
+}
@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// JWK is a single public key in JSON Web Key format (RFC 7517 / RFC 8037)
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+}
+
+// JWKS is a JSON Web Key Set, served by user-service at /.well-known/jwks.json
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// userKeyResolver finds the public key that verifies a user token
+type userKeyResolver interface {
+	publicKey(kid string) (ed25519.PublicKey, error)
+}
+
+// userVerifier is the key source used by VerifyUserToken
+// Set by UseUserSigningKeys (issuer) or UseRemoteJWKS (verify-only services)
+var userVerifier userKeyResolver
+
+const (
+	// How long fetched keys are trusted before being refreshed
+	jwksRefreshInterval = 10 * time.Minute
+
+	// Minimum gap between fetches, so garbage tokens or an unreachable
+	// user-service can't make every request wait on a fetch of its own
+	jwksMinFetchInterval = 30 * time.Second
+)
+
+// RemoteJWKS fetches and caches the user token public keys from user-service
+type RemoteJWKS struct {
+	url        string
+	httpClient *http.Client
+
+	mu        sync.RWMutex
+	keys      map[string]ed25519.PublicKey // kid -> public key
+	fetchedAt time.Time
+	lastTry   time.Time
+
+	fetchMu sync.Mutex // Held for the duration of a fetch, so concurrent callers share one
+}
+
+// UseRemoteJWKS makes this service verify user tokens with keys fetched from url
+// The service can verify user tokens but never issue them
+func UseRemoteJWKS(url string) *RemoteJWKS {
+	remote := &RemoteJWKS{
+		url:        url,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+		keys:       make(map[string]ed25519.PublicKey),
+	}
+
+	// Warm the cache, failures are retried on first use
+	if err := remote.refresh(); err != nil {
+		log.Printf("Could not fetch user JWKS yet (will retry): %v", err)
+	}
+
+	userVerifier = remote
+	return remote
+}
+
+// publicKey implements userKeyResolver
+// A stale cache keeps serving known keys while it is refreshed in the background;
+// an unknown kid (key rotation) waits for a refresh. Both are throttled by refreshIfDue.
+func (j *RemoteJWKS) publicKey(kid string) (ed25519.PublicKey, error) {
+	j.mu.RLock()
+	key, ok := j.keys[kid]
+	stale := time.Since(j.fetchedAt) > jwksRefreshInterval
+	j.mu.RUnlock()
+
+	if ok {
+		if stale && j.due() {
+			go j.refreshIfDue()
+		}
+		return key, nil
+	}
+
+	j.refreshIfDue()
+	j.mu.RLock()
+	key, ok = j.keys[kid]
+	j.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown or retired key id %q", kid)
+	}
+	return key, nil
+}
+
+// due reports whether the last fetch was tried long enough ago to try again
+func (j *RemoteJWKS) due() bool {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return time.Since(j.lastTry) > jwksMinFetchInterval
+}
+
+// refreshIfDue refreshes the cache unless a fetch was tried within jwksMinFetchInterval
+// Callers arriving during a fetch wait for it, then find it recent and return
+func (j *RemoteJWKS) refreshIfDue() {
+	j.fetchMu.Lock()
+	defer j.fetchMu.Unlock()
+
+	if !j.due() {
+		return
+	}
+	if err := j.refresh(); err != nil {
+		log.Printf("Failed to refresh user JWKS: %v", err)
+	}
+}
+
+// refresh downloads the key set and replaces the cache
+func (j *RemoteJWKS) refresh() error {
+	j.mu.Lock()
+	j.lastTry = time.Now()
+	j.mu.Unlock()
+
+	resp, err := j.httpClient.Get(j.url)
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("JWKS endpoint returned status %d", resp.StatusCode)
+	}
+
+	var set JWKS
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]ed25519.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.KeyType != "OKP" || jwk.Curve != "Ed25519" {
+			continue
+		}
+		raw, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil || len(raw) != ed25519.PublicKeySize {
+			log.Printf("Skipping malformed JWK %q", jwk.KeyID)
+			continue
+		}
+		keys[jwk.KeyID] = ed25519.PublicKey(raw)
+	}
+
+	j.mu.Lock()
+	j.keys = keys
+	j.fetchedAt = time.Now()
+	j.mu.Unlock()
+
+	log.Printf("Fetched %d user verification keys from %s", len(keys), j.url)
+	return nil
+}
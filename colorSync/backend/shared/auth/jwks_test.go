@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// jwksServer serves the public half of a signer's keys and counts fetches
+type jwksServer struct {
+	*httptest.Server
+	signer  *UserSigner
+	fetches atomic.Int32
+	down    atomic.Bool   // Answer 503
+	delay   time.Duration // Before answering
+}
+
+func newJWKSServer(t *testing.T, keys ...ed25519.PrivateKey) *jwksServer {
+	t.Helper()
+	s := &jwksServer{signer: &UserSigner{}}
+	s.signer.setKeys(keys)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.fetches.Add(1)
+		time.Sleep(s.delay)
+		if s.down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(s.signer.JWKS())
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// newTestRemote is a RemoteJWKS that has fetched once
+func newTestRemote(t *testing.T, server *jwksServer) *RemoteJWKS {
+	t.Helper()
+	remote := UseRemoteJWKS(server.URL)
+	t.Cleanup(func() { userVerifier = nil })
+	return remote
+}
+
+// age makes the cache look fetched, and last tried, d ago
+func (j *RemoteJWKS) age(d time.Duration) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.fetchedAt = time.Now().Add(-d)
+	j.lastTry = j.fetchedAt
+}
+
+// lookupAll looks kid up from n goroutines at once
+func lookupAll(j *RemoteJWKS, kid string, n int) (errs int) {
+	var wg sync.WaitGroup
+	var failed atomic.Int32
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := j.publicKey(kid); err != nil {
+				failed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	return int(failed.Load())
+}
+
+func TestRemoteJWKSCaching(t *testing.T) {
+	key, rotated := newTestKey(t), newTestKey(t)
+	kid, rotatedKid := keyID(key.Public().(ed25519.PublicKey)), keyID(rotated.Public().(ed25519.PublicKey))
+
+	tests := []struct {
+		name        string
+		age         time.Duration // Since the cache was fetched
+		down        bool          // user-service unreachable
+		rotate      bool          // user-service now also has the rotated key
+		kid         string
+		wantErr     bool
+		wantFetches int32 // After the warm-up fetch, across 20 concurrent lookups
+	}{
+		{"fresh known key", time.Minute, false, false, kid, false, 0},
+		{"stale known key is refreshed once", jwksRefreshInterval + time.Minute, false, false, kid, false, 1},
+		{"stale known key served while user-service is down", jwksRefreshInterval + time.Minute, true, false, kid, false, 1},
+		{"unknown kid fetched once", time.Minute, false, true, rotatedKid, false, 1},
+		{"unknown kid not refetched within the minimum interval", jwksMinFetchInterval / 2, false, true, rotatedKid, true, 0},
+		{"unknown kid with user-service down", time.Minute, true, false, rotatedKid, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newJWKSServer(t, key)
+			remote := newTestRemote(t, server)
+			remote.age(tt.age)
+			server.fetches.Store(0)
+			server.down.Store(tt.down)
+			server.delay = 50 * time.Millisecond
+			if tt.rotate {
+				server.signer.setKeys([]ed25519.PrivateKey{rotated, key})
+			}
+
+			start := time.Now()
+			errs := lookupAll(remote, tt.kid, 20)
+			if tt.wantErr != (errs > 0) || (errs > 0 && errs != 20) {
+				t.Fatalf("%d of 20 lookups failed, want error=%t for all", errs, tt.wantErr)
+			}
+			if !tt.wantErr && tt.kid == kid && time.Since(start) >= server.delay {
+				t.Fatalf("known key lookups took %s, want them served from the cache", time.Since(start))
+			}
+
+			// Background refreshes finish on their own
+			deadline := time.Now().Add(2 * time.Second)
+			for server.fetches.Load() < tt.wantFetches && time.Now().Before(deadline) {
+				time.Sleep(5 * time.Millisecond)
+			}
+			time.Sleep(2 * server.delay)
+			if got := server.fetches.Load(); got != tt.wantFetches {
+				t.Fatalf("%d fetches, want %d", got, tt.wantFetches)
+			}
+		})
+	}
+}
+
+func TestRemoteJWKSVerifiesIssuedTokens(t *testing.T) {
+	useTestSigner(t, filepath.Join(t.TempDir(), "user.pem"))
+	token := issue(t)
+
+	_, key := userSigner.signingKey()
+	server := newJWKSServer(t, key)
+	newTestRemote(t, server)
+
+	claims, err := VerifyUserToken(token)
+	if err != nil || claims.Username != "alice" {
+		t.Fatalf("VerifyUserToken via JWKS = %+v, %v; want alice", claims, err)
+	}
+}
@@ -1,21 +1,15 @@
 package auth
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-// Secret keys - In production, use environment variables
-const (
-	// Used for user authentication tokens
-	UserSecretKey = "user-jwt-secret-key-change-in-production"
-
-	// Used for service-to-service authentication (Zero Trust)
-	ServiceSecretKey = "service-to-service-secret-key-change-in-production"
-)
-
 // Claims structure for user JWT tokens
 type UserClaims struct {
 	UserID   string `json:"user_id"`
@@ -24,46 +18,132 @@ type UserClaims struct {
 }
 
 // Claims structure for service JWT tokens (Zero Trust)
+// The audience (aud) names the service the token is meant for,
+// scopes list the operations the caller may perform there
 type ServiceClaims struct {
-	ServiceName string `json:"service_name"`
+	ServiceName string   `json:"service_name"`
+	Scopes      []string `json:"scopes,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// HasScope reports whether the token grants scope
+func (c *ServiceClaims) HasScope(scope string) bool {
+	return slices.Contains(c.Scopes, scope)
+}
+
+// Service names, used as ServiceName and audience values
+const (
+	UserService      = "user-service"
+	RoomService      = "room-service"
+	GameRulesService = "game-rules-service"
+
+	// AdminService is the identity operators use, through user-service's set-status command
+	AdminService = "admin"
+)
+
+// Scopes for internal endpoints
+const (
+	ScopeGameStart       = "game:start"       // game-rules-service: POST /game/start
+	ScopeRevocationsRead = "revocations:read" // user-service: GET /internal/revocations
+	ScopeUsersRead       = "users:read"       // user-service: GET /internal/users/{id}
+	ScopeUsersStatus     = "users:status"     // user-service: PUT /internal/users/{id}/status
+	ScopeRatingsWrite    = "ratings:write"    // user-service: POST /internal/matches
+	ScopeRoomsLifecycle  = "rooms:lifecycle"  // room-service: POST /internal/rooms/{id}/lifecycle
+)
+
+// AccessTokenTTL is how long a user access token stays valid
+// Kept short: sessions continue with refresh tokens, and a stolen token dies quickly
+const AccessTokenTTL = 15 * time.Minute
+
+// IssuedToken is a signed user token plus the details needed to revoke it later
+type IssuedToken struct {
+	Token     string
+	ID        string // jti claim
+	ExpiresAt time.Time
+}
+
 // GenerateUserToken creates a JWT token for authenticated users
-// Token expires in 24 hours
+// Token expires after AccessTokenTTL
 func GenerateUserToken(userID, username string) (string, error) {
+	issued, err := IssueUserToken(userID, username)
+	if err != nil {
+		return "", err
+	}
+	return issued.Token, nil
+}
+
+// IssueUserToken creates a user JWT with a unique ID (jti) so it can be revoked
+// Signed with EdDSA, so only the service that called UseUserSigningKeys can issue tokens
+func IssueUserToken(userID, username string) (*IssuedToken, error) {
+	if userSigner == nil {
+		return nil, fmt.Errorf("this service has no user signing keys")
+	}
+
+	tokenID, err := NewTokenID()
+	if err != nil {
+		return nil, err
+	}
+
 	// Create claims with user info and expiration
+	now := time.Now()
+	expiresAt := now.Add(AccessTokenTTL)
 	claims := UserClaims{
 		UserID:   userID,
 		Username: username,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
+			ID:        tokenID,
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
 		},
 	}
 
-	// Create token with claims
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	// Create token with claims, tagged with the signing key ID
+	kid, privateKey := userSigner.signingKey()
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = kid
 
-	// Sign token with secret key
-	tokenString, err := token.SignedString([]byte(UserSecretKey))
+	// Sign token with the active private key
+	tokenString, err := token.SignedString(privateKey)
 	if err != nil {
-		return "", fmt.Errorf("failed to sign token: %w", err)
+		return nil, fmt.Errorf("failed to sign token: %w", err)
 	}
 
-	return tokenString, nil
+	return &IssuedToken{
+		Token:     tokenString,
+		ID:        tokenID,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// NewTokenID returns a random identifier for jti claims and opaque tokens
+func NewTokenID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate token ID: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
 }
 
 // VerifyUserToken validates a JWT token and returns the claims
+// Needs a key source: UseUserSigningKeys (user-service) or UseRemoteJWKS (everyone else)
 func VerifyUserToken(tokenString string) (*UserClaims, error) {
+	if userVerifier == nil {
+		return nil, fmt.Errorf("no user token verification keys configured")
+	}
+
 	// Parse and validate token
 	token, err := jwt.ParseWithClaims(tokenString, &UserClaims{}, func(token *jwt.Token) (interface{}, error) {
-		// Verify signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		// Verify signing method (rejects HMAC tokens forged with a shared secret)
+		if _, ok := token.Method.(*jwt.SigningMethodEd25519); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return []byte(UserSecretKey), nil
+		// Look up the public key named in the header
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			return nil, fmt.Errorf("token has no key id")
+		}
+		return userVerifier.publicKey(kid)
 	})
 
 	if err != nil {
@@ -78,24 +158,53 @@ func VerifyUserToken(tokenString string) (*UserClaims, error) {
 	return nil, fmt.Errorf("invalid token")
 }
 
+// ServiceTokenTTL is how long a service token stays valid
+const ServiceTokenTTL = 1 * time.Hour
+
 // GenerateServiceToken creates a JWT token for service-to-service auth (Zero Trust)
 // Token expires in 1 hour
+// The token has no audience or scopes, so routes guarded by a ServicePolicy reject it;
+// prefer GenerateScopedServiceToken
 func GenerateServiceToken(serviceName string) (string, error) {
-	// Create claims with service name and expiration
+	return GenerateScopedServiceToken(serviceName, "")
+}
+
+// GenerateScopedServiceToken creates a service token for one target service (audience)
+// that only grants the listed scopes
+// serviceName must be the service the keys were loaded for
+// Token expires in 1 hour
+func GenerateScopedServiceToken(serviceName, audience string, scopes ...string) (string, error) {
+	if serviceKeys == nil {
+		return "", fmt.Errorf("service keys not loaded (call LoadServiceKeys)")
+	}
+	if serviceName != serviceKeys.self {
+		return "", fmt.Errorf("service keys belong to %s, can't sign as %s", serviceKeys.self, serviceName)
+	}
+
+	var aud jwt.ClaimStrings
+	if audience != "" {
+		aud = jwt.ClaimStrings{audience}
+	}
+
+	// Create claims with service name, audience, scopes and expiration
 	claims := ServiceClaims{
 		ServiceName: serviceName,
+		Scopes:      scopes,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(1 * time.Hour)),
+			Audience:  aud,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ServiceTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	// Create token with claims
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	// Create token with claims, tagged with the signing key ID
+	kid, key := serviceKeys.signingKey()
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = kid
 
-	// Sign token with service secret key
-	tokenString, err := token.SignedString([]byte(ServiceSecretKey))
+	// Sign token with this service's active key
+	tokenString, err := token.SignedString(key)
 	if err != nil {
 		return "", fmt.Errorf("failed to sign service token: %w", err)
 	}
@@ -104,14 +213,30 @@ func GenerateServiceToken(serviceName string) (string, error) {
 }
 
 // VerifyServiceToken validates a service JWT token
+// The key that signed it must belong to the service named in service_name
 func VerifyServiceToken(tokenString string) (*ServiceClaims, error) {
+	if serviceKeys == nil {
+		return nil, fmt.Errorf("service keys not loaded (call LoadServiceKeys)")
+	}
+
 	// Parse and validate token
+	var signer string
 	token, err := jwt.ParseWithClaims(tokenString, &ServiceClaims{}, func(token *jwt.Token) (interface{}, error) {
 		// Verify signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		if _, ok := token.Method.(*jwt.SigningMethodEd25519); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return []byte(ServiceSecretKey), nil
+		// Look up the key named in the header (old keys stay valid during rotation)
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			return nil, fmt.Errorf("token has no key id")
+		}
+		trusted, err := serviceKeys.publicKey(kid)
+		if err != nil {
+			return nil, err
+		}
+		signer = trusted.service
+		return trusted.key, nil
 	})
 
 	if err != nil {
@@ -120,6 +245,10 @@ func VerifyServiceToken(tokenString string) (*ServiceClaims, error) {
 
 	// Extract claims
 	if claims, ok := token.Claims.(*ServiceClaims); ok && token.Valid {
+		// One service's key can't vouch for another
+		if claims.ServiceName != signer {
+			return nil, fmt.Errorf("token for %s is signed with a key of %s", claims.ServiceName, signer)
+		}
 		return claims, nil
 	}
 
@@ -0,0 +1,304 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Service tokens are signed with Ed25519. Every service holds its own private
+// key, plus a directory of public keys filed by the service they belong to. A
+// token only verifies if it was signed by a key of the service it claims to
+// come from, so being able to check another service's tokens never means
+// being able to mint them.
+
+// Development seed, only used when AUTH_DEV_KEYS=1 opts into it
+// Every service's development key is derived from it and the service name;
+// it is public, so anyone could forge service tokens with it
+const devServiceKeySeed = "service-to-service-secret-key-change-in-production"
+
+// devServices get development keys, so they trust each other with AUTH_DEV_KEYS=1
+var devServices = []string{UserService, RoomService, GameRulesService, AdminService}
+
+// trustedKey is a public key and the service it belongs to
+type trustedKey struct {
+	service string
+	key     ed25519.PublicKey
+}
+
+// ServiceKeys holds the keys one service signs its tokens with,
+// and the public keys of every service whose tokens it accepts
+type ServiceKeys struct {
+	self string // The service these keys sign as
+
+	mu       sync.RWMutex
+	activeID string
+	private  map[string]ed25519.PrivateKey // kid -> own key
+	trusted  map[string]trustedKey         // kid -> public key and its owner, own keys included
+
+	// Set when the keys were loaded from files, used for reloading
+	privatePath string
+	publicDir   string
+	version     string // Names and modification times of the files loaded
+}
+
+// Keys for service tokens (user tokens are signed by user-service alone, see signer.go)
+// Set by LoadServiceKeys, nil until then
+var serviceKeys *ServiceKeys
+
+// LoadServiceKeys loads the keys service signs its tokens with and the public keys it trusts
+// Call once from main, before any service token is minted or checked
+//
+// SERVICE_JWT_PRIVATE_KEY_FILE is this service's PEM file of PKCS#8 Ed25519 keys; the first one signs.
+// SERVICE_JWT_PUBLIC_KEYS_DIR holds a <service-name>.pem file of PKIX public keys for each service.
+// Without them, AUTH_DEV_KEYS=1 derives development keys for every service.
+func LoadServiceKeys(service string) error {
+	keys, err := loadServiceKeys(service)
+	if err != nil {
+		return err
+	}
+	serviceKeys = keys
+	return nil
+}
+
+func loadServiceKeys(service string) (*ServiceKeys, error) {
+	keys := &ServiceKeys{self: service}
+
+	privatePath, publicDir := os.Getenv("SERVICE_JWT_PRIVATE_KEY_FILE"), os.Getenv("SERVICE_JWT_PUBLIC_KEYS_DIR")
+	switch {
+	case privatePath != "" || publicDir != "":
+		if privatePath == "" || publicDir == "" {
+			return nil, fmt.Errorf("SERVICE_JWT_PRIVATE_KEY_FILE and SERVICE_JWT_PUBLIC_KEYS_DIR must be set together")
+		}
+		keys.privatePath, keys.publicDir = privatePath, publicDir
+		if err := keys.Reload(); err != nil {
+			return nil, fmt.Errorf("failed to load service keys: %w", err)
+		}
+
+	case os.Getenv("AUTH_DEV_KEYS") == "1":
+		log.Printf("WARNING: AUTH_DEV_KEYS=1, using the public development service keys")
+		public := make(map[string][]ed25519.PublicKey, len(devServices))
+		for _, name := range devServices {
+			public[name] = []ed25519.PublicKey{devServiceKey(name).Public().(ed25519.PublicKey)}
+		}
+		if err := keys.set([]ed25519.PrivateKey{devServiceKey(service)}, public); err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, fmt.Errorf("no service keys configured: set SERVICE_JWT_PRIVATE_KEY_FILE and SERVICE_JWT_PUBLIC_KEYS_DIR (or AUTH_DEV_KEYS=1 for the development keys)")
+	}
+	return keys, nil
+}
+
+// devServiceKey derives the development key of a service
+func devServiceKey(service string) ed25519.PrivateKey {
+	seed := sha256.Sum256([]byte(devServiceKeySeed + "/" + service))
+	return ed25519.NewKeyFromSeed(seed[:])
+}
+
+// set replaces the keys, own[0] becomes the signing key
+// Own keys are trusted as this service's; a key listed for two services is an error
+func (k *ServiceKeys) set(own []ed25519.PrivateKey, public map[string][]ed25519.PublicKey) error {
+	if len(own) == 0 {
+		return fmt.Errorf("no private keys found")
+	}
+
+	private := make(map[string]ed25519.PrivateKey, len(own))
+	trusted := make(map[string]trustedKey)
+	trust := func(service string, key ed25519.PublicKey) error {
+		kid := keyID(key)
+		if existing, ok := trusted[kid]; ok && existing.service != service {
+			return fmt.Errorf("key %s is listed for both %s and %s", kid, existing.service, service)
+		}
+		trusted[kid] = trustedKey{service: service, key: key}
+		return nil
+	}
+
+	for _, key := range own {
+		pub := key.Public().(ed25519.PublicKey)
+		private[keyID(pub)] = key
+		if err := trust(k.self, pub); err != nil {
+			return err
+		}
+	}
+	for service, keys := range public {
+		for _, key := range keys {
+			if err := trust(service, key); err != nil {
+				return err
+			}
+		}
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.activeID = keyID(own[0].Public().(ed25519.PublicKey))
+	k.private = private
+	k.trusted = trusted
+	return nil
+}
+
+// Reload re-reads the private key file and the public key directory
+// A no-op for development keys; on error the current keys are kept
+func (k *ServiceKeys) Reload() error {
+	if k.privatePath == "" {
+		return nil
+	}
+
+	version, err := k.fileVersion()
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(k.privatePath)
+	if err != nil {
+		return fmt.Errorf("failed to read private key file: %w", err)
+	}
+	own, err := parsePrivateKeys(data)
+	if err != nil {
+		return err
+	}
+	public, err := readPublicKeys(k.publicDir)
+	if err != nil {
+		return err
+	}
+	if err := k.set(own, public); err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	k.version = version
+	active := k.activeID
+	k.mu.Unlock()
+
+	log.Printf("Loaded %d %s signing keys (active: %s), trusting keys of %d services",
+		len(own), k.self, active, len(public))
+	return nil
+}
+
+// readPublicKeys reads every <service-name>.pem file in dir
+func readPublicKeys(dir string) (map[string][]ed25519.PublicKey, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list public keys: %w", err)
+	}
+
+	public := make(map[string][]ed25519.PublicKey, len(files))
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read public key file: %w", err)
+		}
+		service := strings.TrimSuffix(filepath.Base(path), ".pem")
+
+		for {
+			var block *pem.Block
+			block, data = pem.Decode(data)
+			if block == nil {
+				break
+			}
+			if block.Type != "PUBLIC KEY" {
+				continue
+			}
+			parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse public key of %s: %w", service, err)
+			}
+			key, ok := parsed.(ed25519.PublicKey)
+			if !ok {
+				return nil, fmt.Errorf("public keys must be Ed25519, %s has %T", service, parsed)
+			}
+			public[service] = append(public[service], key)
+		}
+		if len(public[service]) == 0 {
+			return nil, fmt.Errorf("no public keys found in %s", path)
+		}
+	}
+	return public, nil
+}
+
+// fileVersion describes the key files as they are now, so changes can be noticed
+func (k *ServiceKeys) fileVersion() (string, error) {
+	files, err := filepath.Glob(filepath.Join(k.publicDir, "*.pem"))
+	if err != nil {
+		return "", fmt.Errorf("failed to list public keys: %w", err)
+	}
+
+	var version strings.Builder
+	for _, path := range append([]string{k.privatePath}, files...) {
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to stat key file: %w", err)
+		}
+		fmt.Fprintf(&version, "%s@%d;", path, info.ModTime().UnixNano())
+	}
+	return version.String(), nil
+}
+
+// reloadIfChanged reloads the keys when a key file is added, removed or modified
+func (k *ServiceKeys) reloadIfChanged() {
+	if k.privatePath == "" {
+		return
+	}
+
+	version, err := k.fileVersion()
+	if err != nil {
+		log.Printf("Failed to check service key files: %v", err)
+		return
+	}
+
+	k.mu.RLock()
+	changed := version != k.version
+	k.mu.RUnlock()
+
+	if changed {
+		if err := k.Reload(); err != nil {
+			log.Printf("Failed to reload service keys (keeping current keys): %v", err)
+		}
+	}
+}
+
+// signingKey returns the active kid and private key
+func (k *ServiceKeys) signingKey() (string, ed25519.PrivateKey) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.activeID, k.private[k.activeID]
+}
+
+// publicKey returns the key named kid and the service it belongs to
+func (k *ServiceKeys) publicKey(kid string) (trustedKey, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	trusted, ok := k.trusted[kid]
+	if !ok {
+		return trustedKey{}, fmt.Errorf("unknown or retired key id %q", kid)
+	}
+	return trusted, nil
+}
+
+// WatchKeyFiles polls the configured key files and reloads them when they change,
+// so keys can be added or retired without restarting the services
+// Call once from main; it returns immediately
+func WatchKeyFiles(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if serviceKeys != nil {
+				serviceKeys.reloadIfChanged()
+			}
+			if userSigner != nil {
+				userSigner.reloadIfChanged()
+			}
+		}
+	}()
+}
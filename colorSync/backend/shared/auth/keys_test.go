@@ -0,0 +1,320 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// touchFile writes data to path with a modification time later than the file had,
+// so reloads notice the change even within the file system's time resolution
+func touchFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	modTime := time.Now()
+	if info, err := os.Stat(path); err == nil && !modTime.After(info.ModTime()) {
+		modTime = info.ModTime().Add(time.Second)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// pemPublicKeys encodes the public halves of keys as a <service-name>.pem file
+func pemPublicKeys(t *testing.T, keys ...ed25519.PrivateKey) []byte {
+	t.Helper()
+	var data []byte
+	for _, key := range keys {
+		der, err := x509.MarshalPKIXPublicKey(key.Public())
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})...)
+	}
+	return data
+}
+
+// serviceKeyFiles is a public key directory plus a private key file per service
+type serviceKeyFiles struct {
+	dir  string
+	keys map[string]ed25519.PrivateKey
+}
+
+func newServiceKeyFiles(t *testing.T, services ...string) *serviceKeyFiles {
+	t.Helper()
+	files := &serviceKeyFiles{dir: t.TempDir(), keys: make(map[string]ed25519.PrivateKey)}
+	for _, service := range services {
+		key := newTestKey(t)
+		files.keys[service] = key
+		touchFile(t, files.privatePath(service), pemKeys(t, key))
+		touchFile(t, files.publicPath(service), pemPublicKeys(t, key))
+	}
+	return files
+}
+
+func (f *serviceKeyFiles) privatePath(service string) string {
+	return filepath.Join(f.dir, service+".key")
+}
+
+func (f *serviceKeyFiles) publicPath(service string) string {
+	return filepath.Join(f.dir, service+".pem")
+}
+
+// use loads service's keys through the environment, as main does
+func (f *serviceKeyFiles) use(t *testing.T, service string) error {
+	t.Helper()
+	t.Setenv("SERVICE_JWT_PRIVATE_KEY_FILE", f.privatePath(service))
+	t.Setenv("SERVICE_JWT_PUBLIC_KEYS_DIR", f.dir)
+	t.Setenv("AUTH_DEV_KEYS", "")
+	t.Cleanup(func() { serviceKeys = nil })
+	return LoadServiceKeys(service)
+}
+
+func kidOf(key ed25519.PrivateKey) string {
+	return keyID(key.Public().(ed25519.PublicKey))
+}
+
+func TestLoadServiceKeys(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecDER, err := x509.MarshalPKIXPublicKey(ecKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		// setup prepares the key files and returns the environment to load with
+		setup   func(t *testing.T, files *serviceKeyFiles) map[string]string
+		wantKid func(files *serviceKeyFiles) string // Signs room-service's tokens
+		wantErr string                              // Error substring, "" if loading must succeed
+	}{
+		{
+			name:    "nothing configured",
+			setup:   func(t *testing.T, files *serviceKeyFiles) map[string]string { return nil },
+			wantErr: "no service keys configured",
+		},
+		{
+			name: "development keys asked for",
+			setup: func(t *testing.T, files *serviceKeyFiles) map[string]string {
+				return map[string]string{"AUTH_DEV_KEYS": "1"}
+			},
+			wantKid: func(*serviceKeyFiles) string { return kidOf(devServiceKey(RoomService)) },
+		},
+		{
+			name: "key files win over development keys",
+			setup: func(t *testing.T, files *serviceKeyFiles) map[string]string {
+				return map[string]string{
+					"SERVICE_JWT_PRIVATE_KEY_FILE": files.privatePath(RoomService),
+					"SERVICE_JWT_PUBLIC_KEYS_DIR":  files.dir,
+					"AUTH_DEV_KEYS":                "1",
+				}
+			},
+			wantKid: func(files *serviceKeyFiles) string { return kidOf(files.keys[RoomService]) },
+		},
+		{
+			name: "private key without public key directory",
+			setup: func(t *testing.T, files *serviceKeyFiles) map[string]string {
+				return map[string]string{"SERVICE_JWT_PRIVATE_KEY_FILE": files.privatePath(RoomService)}
+			},
+			wantErr: "must be set together",
+		},
+		{
+			name: "missing private key file",
+			setup: func(t *testing.T, files *serviceKeyFiles) map[string]string {
+				return map[string]string{
+					"SERVICE_JWT_PRIVATE_KEY_FILE": filepath.Join(files.dir, "missing.key"),
+					"SERVICE_JWT_PUBLIC_KEYS_DIR":  files.dir,
+				}
+			},
+			wantErr: "failed to stat key file",
+		},
+		{
+			name: "private key file without keys",
+			setup: func(t *testing.T, files *serviceKeyFiles) map[string]string {
+				touchFile(t, files.privatePath(RoomService), nil)
+				return map[string]string{
+					"SERVICE_JWT_PRIVATE_KEY_FILE": files.privatePath(RoomService),
+					"SERVICE_JWT_PUBLIC_KEYS_DIR":  files.dir,
+				}
+			},
+			wantErr: "no private keys",
+		},
+		{
+			name: "one key listed for two services",
+			setup: func(t *testing.T, files *serviceKeyFiles) map[string]string {
+				touchFile(t, files.publicPath(UserService), pemPublicKeys(t, files.keys[UserService], files.keys[RoomService]))
+				return map[string]string{
+					"SERVICE_JWT_PRIVATE_KEY_FILE": files.privatePath(RoomService),
+					"SERVICE_JWT_PUBLIC_KEYS_DIR":  files.dir,
+				}
+			},
+			wantErr: "listed for both",
+		},
+		{
+			name: "public key that is not Ed25519",
+			setup: func(t *testing.T, files *serviceKeyFiles) map[string]string {
+				touchFile(t, files.publicPath(UserService), pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: ecDER}))
+				return map[string]string{
+					"SERVICE_JWT_PRIVATE_KEY_FILE": files.privatePath(RoomService),
+					"SERVICE_JWT_PUBLIC_KEYS_DIR":  files.dir,
+				}
+			},
+			wantErr: "must be Ed25519",
+		},
+		{
+			name: "public key file without keys",
+			setup: func(t *testing.T, files *serviceKeyFiles) map[string]string {
+				touchFile(t, files.publicPath(UserService), nil)
+				return map[string]string{
+					"SERVICE_JWT_PRIVATE_KEY_FILE": files.privatePath(RoomService),
+					"SERVICE_JWT_PUBLIC_KEYS_DIR":  files.dir,
+				}
+			},
+			wantErr: "no public keys",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := newServiceKeyFiles(t, UserService, RoomService)
+			env := tt.setup(t, files)
+			for _, name := range []string{"SERVICE_JWT_PRIVATE_KEY_FILE", "SERVICE_JWT_PUBLIC_KEYS_DIR", "AUTH_DEV_KEYS"} {
+				t.Setenv(name, env[name])
+			}
+
+			keys, err := loadServiceKeys(RoomService)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("loadServiceKeys error = %v, want one mentioning %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadServiceKeys: %v", err)
+			}
+			if kid, _ := keys.signingKey(); kid != tt.wantKid(files) {
+				t.Fatalf("signing with %s, want %s", kid, tt.wantKid(files))
+			}
+		})
+	}
+}
+
+func TestVerifyServiceToken(t *testing.T) {
+	files := newServiceKeyFiles(t, UserService, RoomService, GameRulesService)
+	if err := files.use(t, GameRulesService); err != nil {
+		t.Fatalf("LoadServiceKeys: %v", err)
+	}
+	roomKey, userKey := files.keys[RoomService], files.keys[UserService]
+
+	sign := func(method jwt.SigningMethod, key interface{}, kid, serviceName string, expiresAt time.Time) string {
+		token := jwt.NewWithClaims(method, ServiceClaims{
+			ServiceName: serviceName,
+			Scopes:      []string{ScopeGameStart},
+			RegisteredClaims: jwt.RegisteredClaims{
+				Audience:  jwt.ClaimStrings{GameRulesService},
+				ExpiresAt: jwt.NewNumericDate(expiresAt),
+			},
+		})
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	later := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name   string
+		token  string
+		wantOK bool
+	}{
+		{"signed by the service it names", sign(jwt.SigningMethodEdDSA, roomKey, kidOf(roomKey), RoomService, later), true},
+		{"user-service key claiming to be room-service", sign(jwt.SigningMethodEdDSA, userKey, kidOf(userKey), RoomService, later), false},
+		{"room-service kid on a user-service signature", sign(jwt.SigningMethodEdDSA, userKey, kidOf(roomKey), RoomService, later), false},
+		{"unknown key", sign(jwt.SigningMethodEdDSA, newTestKey(t), kidOf(newTestKey(t)), RoomService, later), false},
+		{"no kid", sign(jwt.SigningMethodEdDSA, roomKey, "", RoomService, later), false},
+		{"HMAC with the development seed", sign(jwt.SigningMethodHS256, []byte(devServiceKeySeed), kidOf(roomKey), RoomService, later), false},
+		{"expired", sign(jwt.SigningMethodEdDSA, roomKey, kidOf(roomKey), RoomService, time.Now().Add(-time.Minute)), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := VerifyServiceToken(tt.token)
+			if tt.wantOK && (err != nil || claims.ServiceName != RoomService) {
+				t.Fatalf("VerifyServiceToken = %+v, %v; want room-service's claims", claims, err)
+			}
+			if !tt.wantOK && err == nil {
+				t.Fatalf("accepted token with claims %+v", claims)
+			}
+		})
+	}
+
+	if _, err := GenerateScopedServiceToken(RoomService, UserService, ScopeUsersRead); err == nil {
+		t.Fatal("game-rules-service minted a token as room-service")
+	}
+}
+
+func TestServiceKeyRotation(t *testing.T) {
+	files := newServiceKeyFiles(t, RoomService)
+	if err := files.use(t, RoomService); err != nil {
+		t.Fatalf("LoadServiceKeys: %v", err)
+	}
+	oldKey, newKey := files.keys[RoomService], newTestKey(t)
+
+	oldToken, err := GenerateScopedServiceToken(RoomService, GameRulesService, ScopeGameStart)
+	if err != nil {
+		t.Fatalf("GenerateScopedServiceToken: %v", err)
+	}
+
+	steps := []struct {
+		name      string
+		private   []byte // New private key file, nil to leave it
+		public    []byte // New room-service.pem, nil to leave it
+		wantKid   string // Signs new tokens
+		wantValid bool   // Token from the first key still verifies
+	}{
+		{"new public key published", nil, pemPublicKeys(t, oldKey, newKey), kidOf(oldKey), true},
+		{"new key signs", pemKeys(t, newKey), nil, kidOf(newKey), true},
+		{"old key retired", nil, pemPublicKeys(t, newKey), kidOf(newKey), false},
+		{"broken file keeps the current keys", nil, []byte("not a key"), kidOf(newKey), false},
+	}
+	for _, step := range steps {
+		if step.private != nil {
+			touchFile(t, files.privatePath(RoomService), step.private)
+		}
+		if step.public != nil {
+			touchFile(t, files.publicPath(RoomService), step.public)
+		}
+		serviceKeys.reloadIfChanged()
+
+		if kid, _ := serviceKeys.signingKey(); kid != step.wantKid {
+			t.Fatalf("%s: signing with %q, want %q", step.name, kid, step.wantKid)
+		}
+		if _, err := VerifyServiceToken(oldToken); (err == nil) != step.wantValid {
+			t.Fatalf("%s: old token verify error = %v, want valid=%t", step.name, err, step.wantValid)
+		}
+		token, err := GenerateScopedServiceToken(RoomService, GameRulesService, ScopeGameStart)
+		if err != nil {
+			t.Fatalf("%s: GenerateScopedServiceToken: %v", step.name, err)
+		}
+		if _, err := VerifyServiceToken(token); err != nil {
+			t.Fatalf("%s: fresh token rejected: %v", step.name, err)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RevokedToken is an access token that must be rejected before it expires
+type RevokedToken struct {
+	ID        string    `json:"jti"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// revocationList holds revoked token IDs until the tokens would have expired anyway
+type revocationList struct {
+	mu      sync.RWMutex
+	entries map[string]time.Time // jti -> token expiry
+}
+
+// revocations is the process-wide list consulted by middleware.RequireAuth
+// user-service owns the authoritative copy, other services mirror it with SyncRevocations
+var revocations = &revocationList{entries: make(map[string]time.Time)}
+
+// RevokeToken rejects the access token with this jti from now on
+func RevokeToken(tokenID string, expiresAt time.Time) {
+	if tokenID == "" || time.Now().After(expiresAt) {
+		return // Nothing to do for expired tokens
+	}
+
+	revocations.mu.Lock()
+	revocations.entries[tokenID] = expiresAt
+	revocations.mu.Unlock()
+}
+
+// IsTokenRevoked reports whether the access token with this jti was revoked
+func IsTokenRevoked(tokenID string) bool {
+	revocations.mu.RLock()
+	defer revocations.mu.RUnlock()
+
+	_, revoked := revocations.entries[tokenID]
+	return revoked
+}
+
+// RevokedTokens returns every revocation that is still relevant
+func RevokedTokens() []RevokedToken {
+	pruneRevocations()
+
+	revocations.mu.RLock()
+	defer revocations.mu.RUnlock()
+
+	list := make([]RevokedToken, 0, len(revocations.entries))
+	for id, expiresAt := range revocations.entries {
+		list = append(list, RevokedToken{ID: id, ExpiresAt: expiresAt})
+	}
+	return list
+}
+
+// pruneRevocations drops entries for tokens that have expired on their own
+func pruneRevocations() {
+	revocations.mu.Lock()
+	defer revocations.mu.Unlock()
+
+	now := time.Now()
+	for id, expiresAt := range revocations.entries {
+		if now.After(expiresAt) {
+			delete(revocations.entries, id)
+		}
+	}
+}
+
+// SyncRevocations mirrors user-service's revocation list into this process
+// It polls url (GET /internal/revocations) every interval, authenticating as serviceName
+// Call once from main; it returns immediately
+func SyncRevocations(url, serviceName string, interval time.Duration) {
+	client := &http.Client{Timeout: 5 * time.Second}
+	tokens := NewServiceTokenSource(serviceName, UserService, ScopeRevocationsRead)
+
+	go func() {
+		for {
+			if err := fetchRevocations(client, tokens, url); err != nil {
+				log.Printf("Failed to sync token revocations: %v", err)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+func fetchRevocations(client *http.Client, tokens *ServiceTokenSource, url string) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := tokens.Do(client, req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("revocation endpoint returned status %d", resp.StatusCode)
+	}
+
+	var list []RevokedToken
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return fmt.Errorf("failed to decode revocations: %w", err)
+	}
+
+	for _, revoked := range list {
+		RevokeToken(revoked.ID, revoked.ExpiresAt)
+	}
+	pruneRevocations()
+	return nil
+}
@@ -0,0 +1,247 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// UserSigner holds the Ed25519 private keys used to issue user tokens
+// Only user-service should ever load one; other services verify with the public keys (JWKS)
+type UserSigner struct {
+	mu       sync.RWMutex
+	activeID string
+	keys     map[string]ed25519.PrivateKey // kid -> private key
+	order    []string                      // kids in file order, active first
+
+	// Set when the keys were loaded from a file, used for reloading
+	path    string
+	modTime time.Time
+}
+
+// userSigner is set by UseUserSigningKeys, nil in services that cannot issue user tokens
+var userSigner *UserSigner
+
+// UseUserSigningKeys loads the user token signing keys and makes this service the token issuer
+// Keys come from USER_JWT_PRIVATE_KEY_FILE, a PEM file with one or more PKCS#8 Ed25519 keys.
+// The first key signs new tokens, the others are still published so their tokens verify until removed.
+// If the variable points to a missing file a key is generated and written there.
+// If the variable is unset an ephemeral key is generated (tokens die with the process).
+func UseUserSigningKeys() error {
+	signer := &UserSigner{}
+
+	path := os.Getenv("USER_JWT_PRIVATE_KEY_FILE")
+	switch {
+	case path == "":
+		log.Printf("WARNING: USER_JWT_PRIVATE_KEY_FILE not set, using an ephemeral user signing key")
+		key, err := generateEd25519Key()
+		if err != nil {
+			return err
+		}
+		signer.setKeys([]ed25519.PrivateKey{key})
+
+	default:
+		signer.path = path
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			if err := writeNewKeyFile(path); err != nil {
+				return err
+			}
+			log.Printf("Generated new user signing key at %s", path)
+		}
+		if err := signer.Reload(); err != nil {
+			return err
+		}
+	}
+
+	userSigner = signer
+	userVerifier = signer // The issuer verifies its own tokens locally
+	return nil
+}
+
+func generateEd25519Key() (ed25519.PrivateKey, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	return key, nil
+}
+
+func writeNewKeyFile(path string) error {
+	key, err := generateEd25519Key()
+	if err != nil {
+		return err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to encode signing key: %w", err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write signing key: %w", err)
+	}
+	return nil
+}
+
+// parsePrivateKeys decodes every PKCS#8 Ed25519 key in a PEM file, in file order
+func parsePrivateKeys(data []byte) ([]ed25519.PrivateKey, error) {
+	var keys []ed25519.PrivateKey
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return keys, nil
+		}
+		if block.Type != "PRIVATE KEY" {
+			continue
+		}
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse signing key: %w", err)
+		}
+		key, ok := parsed.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("signing keys must be Ed25519, got %T", parsed)
+		}
+		keys = append(keys, key)
+	}
+}
+
+// keyID derives a stable kid from the public key, so no separate naming is needed
+func keyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
+
+func (s *UserSigner) setKeys(keys []ed25519.PrivateKey) {
+	byID := make(map[string]ed25519.PrivateKey, len(keys))
+	order := make([]string, 0, len(keys))
+	for _, key := range keys {
+		kid := keyID(key.Public().(ed25519.PublicKey))
+		if _, dup := byID[kid]; dup {
+			continue
+		}
+		byID[kid] = key
+		order = append(order, kid)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.activeID = order[0]
+	s.keys = byID
+	s.order = order
+}
+
+// Reload re-reads the PEM key file, a no-op for ephemeral signers
+// On error the current keys are kept
+func (s *UserSigner) Reload() error {
+	if s.path == "" {
+		return nil
+	}
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to stat signing key file: %w", err)
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read signing key file: %w", err)
+	}
+
+	keys, err := parsePrivateKeys(data)
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("no private keys found in %s", s.path)
+	}
+
+	s.setKeys(keys)
+
+	s.mu.Lock()
+	s.modTime = info.ModTime()
+	active := s.activeID
+	s.mu.Unlock()
+
+	log.Printf("Loaded %d user signing keys (active: %s)", len(keys), active)
+	return nil
+}
+
+// reloadIfChanged reloads the key file when its modification time changes
+func (s *UserSigner) reloadIfChanged() {
+	if s.path == "" {
+		return
+	}
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		log.Printf("Failed to check user signing key file: %v", err)
+		return
+	}
+
+	s.mu.RLock()
+	changed := !info.ModTime().Equal(s.modTime)
+	s.mu.RUnlock()
+
+	if changed {
+		if err := s.Reload(); err != nil {
+			log.Printf("Failed to reload user signing keys (keeping current keys): %v", err)
+		}
+	}
+}
+
+// signingKey returns the active kid and private key
+func (s *UserSigner) signingKey() (string, ed25519.PrivateKey) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.activeID, s.keys[s.activeID]
+}
+
+// publicKey implements userKeyResolver
+func (s *UserSigner) publicKey(kid string) (ed25519.PublicKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown or retired key id %q", kid)
+	}
+	return key.Public().(ed25519.PublicKey), nil
+}
+
+// JWKS returns the public half of every loaded key, for /.well-known/jwks.json
+func (s *UserSigner) JWKS() JWKS {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	set := JWKS{Keys: make([]JWK, 0, len(s.order))}
+	for _, kid := range s.order {
+		pub := s.keys[kid].Public().(ed25519.PublicKey)
+		set.Keys = append(set.Keys, JWK{
+			KeyType:   "OKP",
+			Curve:     "Ed25519",
+			X:         base64.RawURLEncoding.EncodeToString(pub),
+			KeyID:     kid,
+			Algorithm: "EdDSA",
+			Use:       "sig",
+		})
+	}
+	return set
+}
+
+// UserJWKS returns the public keys of this service's user signer
+// Returns an empty set if UseUserSigningKeys was not called
+func UserJWKS() JWKS {
+	if userSigner == nil {
+		return JWKS{Keys: []JWK{}}
+	}
+	return userSigner.JWKS()
+}
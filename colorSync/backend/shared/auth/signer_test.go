@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// useTestSigner makes this process the user token issuer with keys from path
+func useTestSigner(t *testing.T, path string) {
+	t.Helper()
+	t.Setenv("USER_JWT_PRIVATE_KEY_FILE", path)
+	if err := UseUserSigningKeys(); err != nil {
+		t.Fatalf("UseUserSigningKeys: %v", err)
+	}
+	t.Cleanup(func() { userSigner, userVerifier = nil, nil })
+}
+
+// pemKeys encodes keys as a signing key file, the first one active
+func pemKeys(t *testing.T, keys ...ed25519.PrivateKey) []byte {
+	t.Helper()
+	var data []byte
+	for _, key := range keys {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})...)
+	}
+	return data
+}
+
+func newTestKey(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	key, err := generateEd25519Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func issue(t *testing.T) string {
+	t.Helper()
+	issued, err := IssueUserToken("user-1", "alice")
+	if err != nil {
+		t.Fatalf("IssueUserToken: %v", err)
+	}
+	return issued.Token
+}
+
+func TestUserSignerGeneratesMissingKeyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "user.pem")
+	useTestSigner(t, path)
+
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("key file not written with mode 0600: %v", err)
+	}
+	claims, err := VerifyUserToken(issue(t))
+	if err != nil || claims.UserID != "user-1" || claims.Username != "alice" || claims.ID == "" {
+		t.Fatalf("VerifyUserToken = %+v, %v; want alice's claims with a jti", claims, err)
+	}
+}
+
+func TestUserSignerRotation(t *testing.T) {
+	oldKey, newKey := newTestKey(t), newTestKey(t)
+	oldKid, newKid := keyID(oldKey.Public().(ed25519.PublicKey)), keyID(newKey.Public().(ed25519.PublicKey))
+
+	path := filepath.Join(t.TempDir(), "user.pem")
+	touchFile(t, path, pemKeys(t, oldKey))
+	useTestSigner(t, path)
+	oldToken := issue(t)
+
+	steps := []struct {
+		name     string
+		keys     []ed25519.PrivateKey
+		wantJWKS []string // kids published, active first
+		oldValid bool
+	}{
+		{"new key prepended", []ed25519.PrivateKey{newKey, oldKey}, []string{newKid, oldKid}, true},
+		{"old key removed", []ed25519.PrivateKey{newKey}, []string{newKid}, false},
+	}
+	for _, step := range steps {
+		touchFile(t, path, pemKeys(t, step.keys...))
+		userSigner.reloadIfChanged()
+
+		var published []string
+		for _, jwk := range UserJWKS().Keys {
+			published = append(published, jwk.KeyID)
+		}
+		if len(published) != len(step.wantJWKS) || published[0] != step.wantJWKS[0] {
+			t.Fatalf("%s: JWKS lists %v, want %v", step.name, published, step.wantJWKS)
+		}
+		if _, err := VerifyUserToken(oldToken); (err == nil) != step.oldValid {
+			t.Fatalf("%s: old token verify error = %v, want valid=%t", step.name, err, step.oldValid)
+		}
+
+		token, _ := jwt.Parse(issue(t), nil)
+		if kid := token.Header["kid"]; kid != newKid {
+			t.Fatalf("%s: new token signed by %v, want the new key %s", step.name, kid, newKid)
+		}
+	}
+}
+
+func TestVerifyUserTokenRejects(t *testing.T) {
+	useTestSigner(t, filepath.Join(t.TempDir(), "user.pem"))
+	kid, key := userSigner.signingKey()
+
+	sign := func(method jwt.SigningMethod, signingKey interface{}, kid string, expiresAt time.Time) string {
+		token := jwt.NewWithClaims(method, UserClaims{
+			UserID: "user-1",
+			RegisteredClaims: jwt.RegisteredClaims{
+				ExpiresAt: jwt.NewNumericDate(expiresAt),
+			},
+		})
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(signingKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	later := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"HMAC token", sign(jwt.SigningMethodHS256, []byte(devServiceKeySeed), kid, later)},
+		{"no kid", sign(jwt.SigningMethodEdDSA, key, "", later)},
+		{"unknown kid", sign(jwt.SigningMethodEdDSA, key, "someone-else", later)},
+		{"signed by another key", sign(jwt.SigningMethodEdDSA, newTestKey(t), kid, later)},
+		{"expired", sign(jwt.SigningMethodEdDSA, key, kid, time.Now().Add(-time.Minute))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if claims, err := VerifyUserToken(tt.token); err == nil {
+				t.Fatalf("accepted token with claims %+v", claims)
+			}
+		})
+	}
+}
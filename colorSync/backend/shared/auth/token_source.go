@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// renewBefore is how long before expiry a cached service token is replaced
+const renewBefore = 5 * time.Minute
+
+// ServiceTokenSource hands out a cached service token for one audience and set of scopes,
+// minting a fresh one shortly before the current one expires
+// Safe for concurrent use
+type ServiceTokenSource struct {
+	serviceName string
+	audience    string
+	scopes      []string
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+// NewServiceTokenSource creates a token source for calls from serviceName to audience
+func NewServiceTokenSource(serviceName, audience string, scopes ...string) *ServiceTokenSource {
+	return &ServiceTokenSource{
+		serviceName: serviceName,
+		audience:    audience,
+		scopes:      scopes,
+	}
+}
+
+// Token returns a valid service token, renewing it if it is close to expiry
+func (s *ServiceTokenSource) Token() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && time.Until(s.expiresAt) > renewBefore {
+		return s.token, nil
+	}
+
+	issuedAt := time.Now()
+	token, err := GenerateScopedServiceToken(s.serviceName, s.audience, s.scopes...)
+	if err != nil {
+		return "", err
+	}
+
+	s.token = token
+	s.expiresAt = issuedAt.Add(ServiceTokenTTL)
+	return token, nil
+}
+
+// Invalidate drops the cached token so the next Token call mints a new one
+// Used when the receiver rejects the token (e.g. after a key rotation)
+func (s *ServiceTokenSource) Invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = ""
+}
+
+// Do sends req with the service token in X-Service-Token
+// On 401 it mints a new token and retries once; the request body must be
+// rewindable (http.NewRequest sets GetBody for bytes/strings readers)
+func (s *ServiceTokenSource) Do(client *http.Client, req *http.Request) (*http.Response, error) {
+	token, err := s.Token()
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Service-Token", token)
+
+	resp, err := client.Do(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	// Can't replay a body we can't rewind, hand back the 401
+	if req.Body != nil && req.GetBody == nil {
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	s.Invalidate()
+	token, err = s.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("failed to rewind request body: %w", err)
+		}
+		retry.Body = body
+	}
+	retry.Header.Set("X-Service-Token", token)
+
+	return client.Do(retry)
+}
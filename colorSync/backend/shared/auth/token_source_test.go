@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServiceTokenSourceRetriesOnce(t *testing.T) {
+	files := newServiceKeyFiles(t, RoomService)
+	if err := files.use(t, RoomService); err != nil {
+		t.Fatalf("LoadServiceKeys: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		body         string // "" sends none
+		oneShot      bool   // The body can't be rewound
+		rejections   int    // 401s before the receiver accepts
+		wantRequests int
+		wantStatus   int
+	}{
+		{"accepted first time", `{"room_id":"room-1"}`, false, 0, 1, http.StatusOK},
+		{"retried with a fresh token", `{"room_id":"room-1"}`, false, 1, 2, http.StatusOK},
+		{"retried without a body", "", false, 1, 2, http.StatusOK},
+		{"second 401 is handed back", `{"room_id":"room-1"}`, false, 2, 2, http.StatusUnauthorized},
+		{"body that can't be rewound", `{"room_id":"room-1"}`, true, 1, 1, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tokens, bodies []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				tokens = append(tokens, r.Header.Get("X-Service-Token"))
+				bodies = append(bodies, string(body))
+				if len(tokens) <= tt.rejections {
+					// As if the caller's key was rotated and the receiver dropped the old one
+					touchFile(t, files.privatePath(RoomService), pemKeys(t, newTestKey(t)))
+					serviceKeys.reloadIfChanged()
+					w.WriteHeader(http.StatusUnauthorized)
+				}
+			}))
+			defer server.Close()
+
+			var body io.Reader
+			if tt.body != "" {
+				body = strings.NewReader(tt.body)
+			}
+			if tt.oneShot {
+				body = io.NopCloser(body) // http.NewRequest only knows how to rewind plain readers
+			}
+			req, err := http.NewRequest(http.MethodPost, server.URL, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			source := NewServiceTokenSource(RoomService, GameRulesService, ScopeGameStart)
+			resp, err := source.Do(server.Client(), req)
+			if err != nil {
+				t.Fatalf("Do: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus || len(tokens) != tt.wantRequests {
+				t.Fatalf("status %d after %d requests, want %d after %d", resp.StatusCode, len(tokens), tt.wantStatus, tt.wantRequests)
+			}
+			for i := 1; i < len(tokens); i++ {
+				if tokens[i] == tokens[i-1] {
+					t.Fatalf("request %d reused the rejected token", i+1)
+				}
+				if bodies[i] != bodies[0] {
+					t.Fatalf("request %d sent body %q, want %q", i+1, bodies[i], bodies[0])
+				}
+			}
+		})
+	}
+}
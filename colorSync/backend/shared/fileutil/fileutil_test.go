@@ -0,0 +1,41 @@
+package fileutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+
+	for _, content := range []string{"first", "second"} {
+		if err := WriteAtomic(path, []byte(content)); err != nil {
+			t.Fatalf("WriteAtomic: %v", err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != content {
+			t.Fatalf("file holds %q, want %q", data, content)
+		}
+	}
+
+	// No temp files are left behind
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("directory holds %d files, want only the written one", len(entries))
+	}
+}
+
+func TestWriteAtomicMissingDirectory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "state.json")
+	if err := WriteAtomic(path, []byte("data")); err == nil {
+		t.Fatal("WriteAtomic succeeded in a missing directory")
+	}
+}
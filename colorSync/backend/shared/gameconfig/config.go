@@ -0,0 +1,158 @@
+// Package gameconfig describes the rules a single game is played with
+// Room Service collects them (private rooms let players choose), Game Service enforces them
+package gameconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Game modes: what players are asked each round
+const (
+	ModeClassic    = "classic"    // Name the ink color, ignore the word
+	ModeReverse    = "reverse"    // Name the word, ignore the ink
+	ModeMixed      = "mixed"      // Classic with balanced congruent and incongruent trials, reported apart in stats
+	ModeMatch      = "match"      // Say whether word and ink agree
+	ModeEscalating = "escalating" // Classic with more colors and less time every round
+)
+
+// Modes lists every game mode Game Service implements
+var Modes = []string{ModeClassic, ModeReverse, ModeMixed, ModeMatch, ModeEscalating}
+
+// Wrong-answer policies: what happens to a player who answers wrong
+const (
+	WrongAnswerLockout = "lockout" // Blocked for the rest of the round
+	WrongAnswerRetry   = "retry"   // May keep trying until someone is right
+	WrongAnswerForfeit = "forfeit" // The round goes to the opponent
+)
+
+// Scoring modes: how the game winner is decided
+const (
+	ScoringRounds = "rounds" // Most rounds won, ties broken by total latency
+	ScoringNet    = "net"    // Rounds won minus wrong answers, ties broken by total latency
+	ScoringPoints = "points" // Both players score every round, more for faster answers; wrong answers cost points
+)
+
+// Colors every client knows how to render
+var Colors = []string{"red", "blue", "green", "yellow", "purple", "cyan"}
+
+// Limits enforced by Validate
+const (
+	MinRounds         = 1
+	MaxRounds         = 25
+	MinRoundTimeout   = 1000  // ms
+	MaxRoundTimeout   = 30000 // ms
+	MaxPause          = 10000 // ms
+	MaxReconnectGrace = 60000 // ms
+	MinPalette        = 2
+)
+
+// Config is the rule set of one game
+// Fields missing from JSON keep their Default values
+type Config struct {
+	Mode           string   `json:"mode"`
+	Rounds         int      `json:"rounds"`
+	RoundTimeoutMs int      `json:"round_timeout_ms"` // How long players have to answer
+	PauseMs        int      `json:"pause_ms"`         // Pause between rounds
+	Palette        []string `json:"palette"`          // Colors used for both words and ink
+	WrongAnswer    string   `json:"wrong_answer"`
+	Scoring        string   `json:"scoring"`
+
+	// How long the game waits, paused, for a dropped player to reconnect before they forfeit
+	// 0 ends the game as soon as a player drops
+	ReconnectGraceMs int `json:"reconnect_grace_ms"`
+}
+
+// Default is the classic game: 5 rounds of 5 seconds with the four basic colors
+func Default() Config {
+	return Config{
+		Mode:           ModeClassic,
+		Rounds:         5,
+		RoundTimeoutMs: 5000,
+		PauseMs:        3000,
+		Palette:        []string{"red", "blue", "green", "yellow"},
+		WrongAnswer:    WrongAnswerLockout,
+		Scoring:        ScoringRounds,
+
+		ReconnectGraceMs: 15000,
+	}
+}
+
+// UnmarshalJSON decodes on top of Default so partial configs are complete
+func (c *Config) UnmarshalJSON(data []byte) error {
+	type plain Config // Drops the method, avoids recursion
+	cfg := plain(Default())
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return err
+	}
+	*c = Config(cfg)
+	return nil
+}
+
+// Validate reports the first rule that is out of range
+func (c Config) Validate() error {
+	if !slices.Contains(Modes, c.Mode) {
+		return fmt.Errorf("mode must be one of %s", strings.Join(Modes, ", "))
+	}
+	if c.Rounds < MinRounds || c.Rounds > MaxRounds {
+		return fmt.Errorf("rounds must be between %d and %d", MinRounds, MaxRounds)
+	}
+	if c.RoundTimeoutMs < MinRoundTimeout || c.RoundTimeoutMs > MaxRoundTimeout {
+		return fmt.Errorf("round_timeout_ms must be between %d and %d", MinRoundTimeout, MaxRoundTimeout)
+	}
+	if c.PauseMs < 0 || c.PauseMs > MaxPause {
+		return fmt.Errorf("pause_ms must be between 0 and %d", MaxPause)
+	}
+
+	if len(c.Palette) < MinPalette {
+		return fmt.Errorf("palette needs at least %d colors", MinPalette)
+	}
+	for i, color := range c.Palette {
+		if !slices.Contains(Colors, color) {
+			return fmt.Errorf("unknown color %q (allowed: %s)", color, strings.Join(Colors, ", "))
+		}
+		if slices.Contains(c.Palette[:i], color) {
+			return fmt.Errorf("color %q appears twice in palette", color)
+		}
+	}
+
+	if c.ReconnectGraceMs < 0 || c.ReconnectGraceMs > MaxReconnectGrace {
+		return fmt.Errorf("reconnect_grace_ms must be between 0 and %d", MaxReconnectGrace)
+	}
+
+	switch c.WrongAnswer {
+	case WrongAnswerLockout, WrongAnswerRetry, WrongAnswerForfeit:
+	default:
+		return fmt.Errorf("wrong_answer must be %s, %s or %s", WrongAnswerLockout, WrongAnswerRetry, WrongAnswerForfeit)
+	}
+
+	switch c.Scoring {
+	case ScoringRounds, ScoringNet, ScoringPoints:
+	default:
+		return fmt.Errorf("scoring must be %s, %s or %s", ScoringRounds, ScoringNet, ScoringPoints)
+	}
+	return nil
+}
+
+// RoundTimeout is RoundTimeoutMs as a duration
+func (c Config) RoundTimeout() time.Duration {
+	return time.Duration(c.RoundTimeoutMs) * time.Millisecond
+}
+
+// Pause is PauseMs as a duration
+func (c Config) Pause() time.Duration {
+	return time.Duration(c.PauseMs) * time.Millisecond
+}
+
+// ReconnectGrace is ReconnectGraceMs as a duration
+func (c Config) ReconnectGrace() time.Duration {
+	return time.Duration(c.ReconnectGraceMs) * time.Millisecond
+}
+
+// Word is the text shown for a palette color
+func Word(color string) string {
+	return strings.ToUpper(color)
+}
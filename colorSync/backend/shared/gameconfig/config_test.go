@@ -0,0 +1,72 @@
+package gameconfig
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(c *Config)
+		wantErr string // Part of the error, empty if the config is valid
+	}{
+		{"default", func(c *Config) {}, ""},
+		{"every mode", func(c *Config) { c.Mode = ModeEscalating }, ""},
+		{"unknown mode", func(c *Config) { c.Mode = "speedrun" }, "mode must be one of"},
+		{"too few rounds", func(c *Config) { c.Rounds = MinRounds - 1 }, "rounds must be between"},
+		{"too many rounds", func(c *Config) { c.Rounds = MaxRounds + 1 }, "rounds must be between"},
+		{"fewest rounds", func(c *Config) { c.Rounds = MinRounds }, ""},
+		{"timeout too short", func(c *Config) { c.RoundTimeoutMs = MinRoundTimeout - 1 }, "round_timeout_ms"},
+		{"timeout too long", func(c *Config) { c.RoundTimeoutMs = MaxRoundTimeout + 1 }, "round_timeout_ms"},
+		{"negative pause", func(c *Config) { c.PauseMs = -1 }, "pause_ms"},
+		{"pause too long", func(c *Config) { c.PauseMs = MaxPause + 1 }, "pause_ms"},
+		{"no pause", func(c *Config) { c.PauseMs = 0 }, ""},
+		{"palette too small", func(c *Config) { c.Palette = []string{"red"} }, "at least"},
+		{"unknown color", func(c *Config) { c.Palette = []string{"red", "mauve"} }, `unknown color "mauve"`},
+		{"duplicate color", func(c *Config) { c.Palette = []string{"red", "blue", "red"} }, `"red" appears twice`},
+		{"every color", func(c *Config) { c.Palette = slices.Clone(Colors) }, ""},
+		{"negative grace", func(c *Config) { c.ReconnectGraceMs = -1 }, "reconnect_grace_ms"},
+		{"grace too long", func(c *Config) { c.ReconnectGraceMs = MaxReconnectGrace + 1 }, "reconnect_grace_ms"},
+		{"unknown wrong-answer policy", func(c *Config) { c.WrongAnswer = "ignore" }, "wrong_answer"},
+		{"retry policy", func(c *Config) { c.WrongAnswer = WrongAnswerRetry }, ""},
+		{"unknown scoring", func(c *Config) { c.Scoring = "elo" }, "scoring"},
+		{"points scoring", func(c *Config) { c.Scoring = ScoringPoints }, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := Default()
+			tt.change(&config)
+
+			err := config.Validate()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("Validate: %v", err)
+			case tt.wantErr != "" && err == nil:
+				t.Fatalf("Validate accepted %+v, want an error about %s", config, tt.wantErr)
+			case tt.wantErr != "" && !strings.Contains(err.Error(), tt.wantErr):
+				t.Fatalf("Validate = %v, want an error about %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUnmarshalKeepsDefaults(t *testing.T) {
+	var config Config
+	if err := json.Unmarshal([]byte(`{"mode":"reverse","rounds":9}`), &config); err != nil {
+		t.Fatal(err)
+	}
+
+	want := Default()
+	want.Mode = ModeReverse
+	want.Rounds = 9
+	if config.Mode != want.Mode || config.Rounds != want.Rounds || config.RoundTimeoutMs != want.RoundTimeoutMs ||
+		!slices.Equal(config.Palette, want.Palette) || config.Scoring != want.Scoring || config.ReconnectGraceMs != want.ReconnectGraceMs {
+		t.Fatalf("config = %+v, want %+v", config, want)
+	}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+}
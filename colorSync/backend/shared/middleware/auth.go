@@ -2,7 +2,9 @@ package middleware
 
 import (
 	"context"
+	"log"
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/Flokots/programming-5/colorSync/shared/auth"
@@ -48,6 +50,12 @@ func RequireAuth(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
+		// Reject tokens killed by logout or refresh token reuse
+		if auth.IsTokenRevoked(claims.ID) {
+			http.Error(w, `{"error": "Token has been revoked"}`, http.StatusUnauthorized)
+			return
+		}
+
 		// Token is valid! Add claims to request context
 		// Next handlers can retrieve user info from context
 		ctx := context.WithValue(r.Context(), UserClaimsKey, claims)
@@ -86,6 +94,58 @@ func RequireServiceAuth(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// ServicePolicy lists who may call an internal route and what their token must grant
+type ServicePolicy struct {
+	Audience string   // The receiving service, e.g. auth.GameRulesService
+	Callers  []string // Allowed calling services, e.g. auth.RoomService
+	Scopes   []string // Every scope the token must carry
+}
+
+// RequireServicePolicy is RequireServiceAuth plus per-route authorization
+// The token must be minted for policy.Audience, come from one of policy.Callers
+// and carry all of policy.Scopes, otherwise the request is rejected with 403
+// Usage:
+//
+//	mux.HandleFunc("/game/start", middleware.RequireServicePolicy(middleware.ServicePolicy{
+//	    Audience: auth.GameRulesService,
+//	    Callers:  []string{auth.RoomService},
+//	    Scopes:   []string{auth.ScopeGameStart},
+//	}, startGameHandler))
+func RequireServicePolicy(policy ServicePolicy, next http.HandlerFunc) http.HandlerFunc {
+	return RequireServiceAuth(func(w http.ResponseWriter, r *http.Request) {
+		claims := GetServiceClaims(r)
+		if claims == nil {
+			http.Error(w, `{"error": "Missing service claims"}`, http.StatusUnauthorized)
+			return
+		}
+
+		// Token must be addressed to this service
+		if !slices.Contains(claims.Audience, policy.Audience) {
+			log.Printf("Rejected %s token for %s: audience %v", claims.ServiceName, r.URL.Path, claims.Audience)
+			http.Error(w, `{"error": "Service token not valid for this service"}`, http.StatusForbidden)
+			return
+		}
+
+		// Caller must be on the allow-list
+		if !slices.Contains(policy.Callers, claims.ServiceName) {
+			log.Printf("Rejected call to %s from %s: caller not allowed", r.URL.Path, claims.ServiceName)
+			http.Error(w, `{"error": "Service not allowed to call this endpoint"}`, http.StatusForbidden)
+			return
+		}
+
+		// Every required scope must be granted
+		for _, scope := range policy.Scopes {
+			if !claims.HasScope(scope) {
+				log.Printf("Rejected call to %s from %s: missing scope %s", r.URL.Path, claims.ServiceName, scope)
+				http.Error(w, `{"error": "Service token missing required scope"}`, http.StatusForbidden)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 // GetUserClaims extracts user JWT claims from request context
 // Returns nil if no claims found (user not authenticated)
 // Usage in handler:
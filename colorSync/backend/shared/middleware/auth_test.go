@@ -0,0 +1,190 @@
+package middleware
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/Flokots/programming-5/colorSync/shared/auth"
+)
+
+// issueUserToken signs a user token with a key made for this test
+func issueUserToken(t *testing.T) *auth.IssuedToken {
+	t.Helper()
+	t.Setenv("USER_JWT_PRIVATE_KEY_FILE", filepath.Join(t.TempDir(), "user.pem"))
+	if err := auth.UseUserSigningKeys(); err != nil {
+		t.Fatalf("UseUserSigningKeys: %v", err)
+	}
+	issued, err := auth.IssueUserToken("user-1", "alice")
+	if err != nil {
+		t.Fatalf("IssueUserToken: %v", err)
+	}
+	return issued
+}
+
+func TestRequireAuth(t *testing.T) {
+	valid := issueUserToken(t)
+	revoked, err := auth.IssueUserToken("user-1", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth.RevokeToken(revoked.ID, revoked.ExpiresAt)
+
+	tests := []struct {
+		name       string
+		header     string
+		wantStatus int
+	}{
+		{"valid token", "Bearer " + valid.Token, http.StatusOK},
+		{"revoked token", "Bearer " + revoked.Token, http.StatusUnauthorized},
+		{"no header", "", http.StatusUnauthorized},
+		{"no Bearer prefix", valid.Token, http.StatusUnauthorized},
+		{"garbage", "Bearer not-a-jwt", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen *auth.UserClaims
+			handler := RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+				seen = GetUserClaims(r)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if reached := seen != nil; reached != (tt.wantStatus == http.StatusOK) {
+				t.Fatalf("handler reached = %t with status %d", reached, rec.Code)
+			}
+			if seen != nil && seen.ID != valid.ID {
+				t.Fatalf("claims for token %s, want %s", seen.ID, valid.ID)
+			}
+		})
+	}
+}
+
+// writeServiceKeys gives every service a key: dir/<service>.key and its public half in dir/<service>.pem
+func writeServiceKeys(t *testing.T) (string, map[string]ed25519.PrivateKey) {
+	t.Helper()
+	dir := t.TempDir()
+	keys := make(map[string]ed25519.PrivateKey)
+	for _, service := range []string{auth.UserService, auth.RoomService, auth.GameRulesService} {
+		pub, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		privateDER, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		publicDER, err := x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, service+".key"), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, service+".pem"), pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}), 0600); err != nil {
+			t.Fatal(err)
+		}
+		keys[service] = key
+	}
+	return dir, keys
+}
+
+// loadServiceKeysAs makes this process service, with the keys in dir
+func loadServiceKeysAs(t *testing.T, dir, service string) {
+	t.Helper()
+	t.Setenv("SERVICE_JWT_PRIVATE_KEY_FILE", filepath.Join(dir, service+".key"))
+	t.Setenv("SERVICE_JWT_PUBLIC_KEYS_DIR", dir)
+	if err := auth.LoadServiceKeys(service); err != nil {
+		t.Fatalf("LoadServiceKeys(%s): %v", service, err)
+	}
+}
+
+func TestRequireServicePolicy(t *testing.T) {
+	dir, keys := writeServiceKeys(t)
+
+	// mint issues a token as caller, the way its ServiceTokenSource would
+	mint := func(caller, audience string, scopes ...string) string {
+		loadServiceKeysAs(t, dir, caller)
+		token, err := auth.GenerateScopedServiceToken(caller, audience, scopes...)
+		if err != nil {
+			t.Fatalf("GenerateScopedServiceToken: %v", err)
+		}
+		return token
+	}
+
+	// forged names room-service but is signed with user-service's own, trusted key
+	userToken, _, err := jwt.NewParser().ParseUnverified(mint(auth.UserService, auth.GameRulesService), &auth.ServiceClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := jwt.NewWithClaims(jwt.SigningMethodEdDSA, auth.ServiceClaims{
+		ServiceName: auth.RoomService,
+		Scopes:      []string{auth.ScopeGameStart},
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{auth.GameRulesService},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	})
+	forged.Header["kid"] = userToken.Header["kid"]
+	forgedToken, err := forged.SignedString(keys[auth.UserService])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userAccess := issueUserToken(t)
+
+	tests := []struct {
+		name       string
+		token      string
+		wantStatus int
+	}{
+		{"allowed caller with the scope", mint(auth.RoomService, auth.GameRulesService, auth.ScopeGameStart), http.StatusOK},
+		{"extra scopes are fine", mint(auth.RoomService, auth.GameRulesService, auth.ScopeUsersRead, auth.ScopeGameStart), http.StatusOK},
+		{"token for another service", mint(auth.RoomService, auth.UserService, auth.ScopeGameStart), http.StatusForbidden},
+		{"caller not allowed", mint(auth.UserService, auth.GameRulesService, auth.ScopeGameStart), http.StatusForbidden},
+		{"missing scope", mint(auth.RoomService, auth.GameRulesService, auth.ScopeUsersRead), http.StatusForbidden},
+		{"no audience", mint(auth.RoomService, "", auth.ScopeGameStart), http.StatusForbidden},
+		{"user-service key posing as room-service", forgedToken, http.StatusUnauthorized},
+		{"user access token", userAccess.Token, http.StatusUnauthorized},
+		{"no token", "", http.StatusUnauthorized},
+	}
+
+	loadServiceKeysAs(t, dir, auth.GameRulesService)
+	handler := RequireServicePolicy(ServicePolicy{
+		Audience: auth.GameRulesService,
+		Callers:  []string{auth.RoomService},
+		Scopes:   []string{auth.ScopeGameStart},
+	}, func(w http.ResponseWriter, r *http.Request) {})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/game/start", nil)
+			if tt.token != "" {
+				req.Header.Set("X-Service-Token", tt.token)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
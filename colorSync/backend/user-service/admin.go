@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Flokots/programming-5/colorSync/shared/auth"
+)
+
+// runSetStatus implements `user-service set-status <user-id> <status>`
+// It calls a running user-service as the admin identity, so the change goes
+// through the live store and ends the user's sessions like any other request
+func runSetStatus(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: user-service set-status <user-id> <active|banned|deleted>")
+	}
+
+	if err := auth.LoadServiceKeys(auth.AdminService); err != nil {
+		return fmt.Errorf("failed to load admin keys: %w", err)
+	}
+
+	body, err := json.Marshal(StatusRequest{Status: args[1]})
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("%s/internal/users/%s/status", getEnv("USER_SERVICE_URL", "http://localhost:8001"), args[0])
+	req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	tokens := auth.NewServiceTokenSource(auth.AdminService, auth.UserService, auth.ScopeUsersStatus)
+	resp, err := tokens.Do(&http.Client{Timeout: 5 * time.Second}, req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("user service returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	fmt.Print(string(data))
+	return nil
+}
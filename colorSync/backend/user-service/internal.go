@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Flokots/programming-5/colorSync/shared/auth"
+	"github.com/Flokots/programming-5/colorSync/shared/middleware"
+)
+
+// InternalUserResponse is the service-facing view of a user, including account status
+type InternalUserResponse struct {
+	ID        string    `json:"id"`
+	Username  string    `json:"username"`
+	Status    string    `json:"status"`
+	Rating    int       `json:"rating"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// internalUsersHandler routes /internal/users/{id} and /internal/users/{id}/status,
+// each behind its own service policy
+func internalUsersHandler() http.HandlerFunc {
+	getUser := middleware.RequireServicePolicy(middleware.ServicePolicy{
+		Audience: auth.UserService,
+		Callers:  []string{auth.RoomService},
+		Scopes:   []string{auth.ScopeUsersRead},
+	}, internalGetUserHandler)
+	setStatus := middleware.RequireServicePolicy(middleware.ServicePolicy{
+		Audience: auth.UserService,
+		Callers:  []string{auth.AdminService},
+		Scopes:   []string{auth.ScopeUsersStatus},
+	}, internalSetStatusHandler)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		// /internal/users/{id}/status is for operators, the plain lookup for room-service
+		if strings.HasSuffix(r.URL.Path, "/status") {
+			setStatus(w, r)
+			return
+		}
+		getUser(w, r)
+	}
+}
+
+// internalGetUserHandler lets other services check that a user exists and may play
+// Wrapped in middleware.RequireServicePolicy
+func internalGetUserHandler(w http.ResponseWriter, r *http.Request) {
+	// 1. Only accept GET requests
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 2. Extract user ID from URL path: /internal/users/{id}
+	userID := strings.TrimPrefix(r.URL.Path, "/internal/users/")
+	if userID == "" || strings.Contains(userID, "/") {
+		http.Error(w, "User ID required", http.StatusBadRequest)
+		return
+	}
+
+	// 3. Look up user
+	user, err := store.GetByID(userID)
+	if errors.Is(err, ErrUserNotFound) {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to look up user %s: %v", userID, err)
+		http.Error(w, "Failed to look up user", http.StatusInternalServerError)
+		return
+	}
+
+	// 4. Return user info with status
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(InternalUserResponse{
+		ID:        user.ID,
+		Username:  user.Username,
+		Status:    user.Status,
+		Rating:    user.Rating,
+		CreatedAt: user.CreatedAt,
+	})
+}
+
+// StatusRequest is the body of PUT /internal/users/{id}/status
+type StatusRequest struct {
+	Status string `json:"status"`
+}
+
+// internalSetStatusHandler bans, deletes or reinstates an account
+// Banning or deleting also ends every session of the user
+// Wrapped in middleware.RequireServicePolicy
+func internalSetStatusHandler(w http.ResponseWriter, r *http.Request) {
+	// 1. Only accept PUT requests
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 2. Extract user ID from URL path: /internal/users/{id}/status
+	userID := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/internal/users/"), "/status")
+	if userID == "" || strings.Contains(userID, "/") {
+		http.Error(w, "User ID required", http.StatusBadRequest)
+		return
+	}
+
+	// 3. Parse JSON from request body
+	var req StatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// 4. Change the status
+	err := store.SetStatus(userID, req.Status)
+	switch {
+	case errors.Is(err, ErrInvalidStatus):
+		http.Error(w, "Status must be active, banned or deleted", http.StatusBadRequest)
+		return
+	case errors.Is(err, ErrUserNotFound):
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	case err != nil:
+		log.Printf("Failed to set status of user %s: %v", userID, err)
+		http.Error(w, "Failed to update user", http.StatusInternalServerError)
+		return
+	}
+
+	// 5. A banned or deleted player must not keep playing on tokens they already hold
+	if req.Status != UserStatusActive {
+		if err := sessions.revokeUser(userID); err != nil {
+			log.Printf("Failed to end sessions of user %s: %v", userID, err)
+			http.Error(w, "Status changed, but failed to end sessions", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	user, err := store.GetByID(userID)
+	if err != nil {
+		log.Printf("Failed to look up user %s: %v", userID, err)
+		http.Error(w, "Failed to look up user", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("User %s (ID: %s) is now %s", user.Username, user.ID, user.Status)
+
+	// 6. Return the updated user
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(InternalUserResponse{
+		ID:        user.ID,
+		Username:  user.Username,
+		Status:    user.Status,
+		Rating:    user.Rating,
+		CreatedAt: user.CreatedAt,
+	})
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Flokots/programming-5/colorSync/shared/auth"
+)
+
+// serviceToken mints a token as caller with the development keys,
+// then loads user-service's own keys back to check it
+func serviceToken(t *testing.T, caller, audience string, scopes ...string) string {
+	t.Helper()
+	t.Setenv("AUTH_DEV_KEYS", "1")
+	if err := auth.LoadServiceKeys(caller); err != nil {
+		t.Fatal(err)
+	}
+	token, err := auth.GenerateScopedServiceToken(caller, audience, scopes...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := auth.LoadServiceKeys(auth.UserService); err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestInternalUsers(t *testing.T) {
+	alice := useTestAuth(t)
+	sessions = newMemorySessionStore()
+
+	roomToken := serviceToken(t, auth.RoomService, auth.UserService, auth.ScopeUsersRead)
+	adminToken := serviceToken(t, auth.AdminService, auth.UserService, auth.ScopeUsersStatus)
+	wrongAudience := serviceToken(t, auth.RoomService, auth.GameRulesService, auth.ScopeUsersRead)
+	noScope := serviceToken(t, auth.AdminService, auth.UserService)
+	userToken, err := auth.IssueUserToken(alice.ID, alice.Username)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		body       string
+		wantCode   int
+		wantStatus string // The account status in the response
+	}{
+		{"room service looks a user up", http.MethodGet, "/internal/users/" + alice.ID, roomToken, "", http.StatusOK, UserStatusActive},
+		{"unknown user", http.MethodGet, "/internal/users/nobody", roomToken, "", http.StatusNotFound, ""},
+		{"no token", http.MethodGet, "/internal/users/" + alice.ID, "", "", http.StatusUnauthorized, ""},
+		{"user token", http.MethodGet, "/internal/users/" + alice.ID, userToken.Token, "", http.StatusUnauthorized, ""},
+		{"token for another service", http.MethodGet, "/internal/users/" + alice.ID, wrongAudience, "", http.StatusForbidden, ""},
+		{"room service can't change a status", http.MethodPut, "/internal/users/" + alice.ID + "/status", roomToken, `{"status": "banned"}`, http.StatusForbidden, ""},
+		{"admin without the scope", http.MethodPut, "/internal/users/" + alice.ID + "/status", noScope, `{"status": "banned"}`, http.StatusForbidden, ""},
+		{"admin can't look users up", http.MethodGet, "/internal/users/" + alice.ID, adminToken, "", http.StatusForbidden, ""},
+		{"invalid status", http.MethodPut, "/internal/users/" + alice.ID + "/status", adminToken, `{"status": "suspended"}`, http.StatusBadRequest, ""},
+		{"invalid body", http.MethodPut, "/internal/users/" + alice.ID + "/status", adminToken, `status=banned`, http.StatusBadRequest, ""},
+		{"status of an unknown user", http.MethodPut, "/internal/users/nobody/status", adminToken, `{"status": "banned"}`, http.StatusNotFound, ""},
+		{"admin bans a user", http.MethodPut, "/internal/users/" + alice.ID + "/status", adminToken, `{"status": "banned"}`, http.StatusOK, UserStatusBanned},
+	}
+	handler := internalUsersHandler()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.token != "" {
+				r.Header.Set("X-Service-Token", tt.token)
+			}
+			w := httptest.NewRecorder()
+			handler(w, r)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var got InternalUserResponse
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got.ID != alice.ID || got.Status != tt.wantStatus {
+				t.Fatalf("response = %+v, want %s %s", got, alice.ID, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestRunSetStatus(t *testing.T) {
+	alice := useTestAuth(t)
+	sessions = newMemorySessionStore()
+	serviceToken(t, auth.AdminService, auth.UserService) // Development keys for both sides
+
+	server := httptest.NewServer(internalUsersHandler())
+	defer server.Close()
+	t.Setenv("USER_SERVICE_URL", server.URL)
+
+	if err := runSetStatus([]string{alice.ID}); err == nil {
+		t.Fatal("runSetStatus without a status succeeded, want usage")
+	}
+	if err := runSetStatus([]string{alice.ID, "suspended"}); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Fatalf("runSetStatus(suspended) = %v, want the 400 reported", err)
+	}
+	if err := runSetStatus([]string{"nobody", UserStatusBanned}); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("runSetStatus(nobody) = %v, want the 404 reported", err)
+	}
+	if err := runSetStatus([]string{alice.ID, UserStatusBanned}); err != nil {
+		t.Fatalf("runSetStatus: %v", err)
+	}
+	if user, _ := store.GetByID(alice.ID); user.Status != UserStatusBanned {
+		t.Fatalf("alice is %s, want banned", user.Status)
+	}
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/Flokots/programming-5/colorSync/shared/middleware"
+)
+
+// Leaderboard periods: all time, or the current UTC day or week (from Monday)
+const (
+	PeriodAllTime = "all"
+	PeriodWeekly  = "weekly"
+	PeriodDaily   = "daily"
+)
+
+// Leaderboard metrics, each ranking the best first
+const (
+	MetricRating  = "rating"  // Highest Elo rating among players who played in the period
+	MetricWins    = "wins"    // Most games won in the period
+	MetricLatency = "latency" // Lowest average reaction time on correct answers in the period
+)
+
+const (
+	defaultLeaderboardLimit = 20
+	maxLeaderboardLimit     = 100
+
+	// Ranking by latency takes this many correct answers, so one lucky click doesn't top the board
+	minLatencyAnswers = 5
+)
+
+// LeaderboardEntry is one ranked player
+type LeaderboardEntry struct {
+	Rank          int    `json:"rank"` // Players tied on the metric share a rank
+	UserID        string `json:"user_id"`
+	Username      string `json:"username"`
+	Rating        int    `json:"rating"`
+	GamesPlayed   int    `json:"games_played"` // In the period, like the fields below
+	GamesWon      int    `json:"games_won"`
+	AvgReactionMs int64  `json:"avg_reaction_ms"` // 0 without correct answers
+}
+
+// LeaderboardResponse is a page of GET /leaderboard
+type LeaderboardResponse struct {
+	Period  string             `json:"period"`
+	Metric  string             `json:"metric"`
+	Since   *time.Time         `json:"since,omitempty"` // Start of the period, absent for all time
+	Entries []LeaderboardEntry `json:"entries"`
+	Total   int                `json:"total"` // Players ranked
+	Limit   int                `json:"limit"`
+	Offset  int                `json:"offset"`
+}
+
+// MyRankResponse is the body of GET /leaderboard/me
+type MyRankResponse struct {
+	Period string           `json:"period"`
+	Metric string           `json:"metric"`
+	Since  *time.Time       `json:"since,omitempty"`
+	Total  int              `json:"total"`
+	Entry  LeaderboardEntry `json:"entry"`
+}
+
+// periodStart is when the period that now falls in began, nil for all time
+func periodStart(period string, now time.Time) (*time.Time, error) {
+	now = now.UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	switch period {
+	case PeriodAllTime:
+		return nil, nil
+	case PeriodDaily:
+		return &today, nil
+	case PeriodWeekly:
+		monday := today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+		return &monday, nil
+	default:
+		return nil, fmt.Errorf("period must be %s, %s or %s", PeriodAllTime, PeriodWeekly, PeriodDaily)
+	}
+}
+
+// standing is a player's totals over a period
+type standing struct {
+	user           *User
+	gamesPlayed    int
+	gamesWon       int
+	correctAnswers int
+	reactionMs     int64
+}
+
+// standingSince totals the player's games from since on, or every game if since is nil
+func standingSince(user *User, since *time.Time) standing {
+	st := standing{user: user}
+	if since == nil {
+		st.gamesPlayed = user.Stats.GamesPlayed
+		st.gamesWon = user.Stats.GamesWon
+		st.correctAnswers = user.Stats.CorrectAnswers
+		st.reactionMs = user.Stats.ReactionMs
+		return st
+	}
+
+	from := dayKey(*since)
+	for _, day := range user.Stats.Days {
+		if day.Date >= from {
+			st.gamesPlayed += day.GamesPlayed
+			st.gamesWon += day.GamesWon
+			st.correctAnswers += day.CorrectAnswers
+			st.reactionMs += day.ReactionMs
+		}
+	}
+	return st
+}
+
+func (st standing) avgReactionMs() int64 {
+	if st.correctAnswers == 0 {
+		return 0
+	}
+	return st.reactionMs / int64(st.correctAnswers)
+}
+
+// metricValue is what players are ranked on; players with the same value share a rank
+func (st standing) metricValue(metric string) int64 {
+	switch metric {
+	case MetricWins:
+		return int64(st.gamesWon)
+	case MetricLatency:
+		return st.avgReactionMs()
+	default:
+		return int64(st.user.Rating)
+	}
+}
+
+// compareStandings orders two players by metric, best first
+// Ties are broken so the order is stable: better record, then username
+func compareStandings(metric string) func(a, b standing) int {
+	return func(a, b standing) int {
+		var c int
+		switch metric {
+		case MetricWins:
+			c = cmp.Or(cmp.Compare(b.gamesWon, a.gamesWon), cmp.Compare(a.gamesPlayed, b.gamesPlayed))
+		case MetricLatency:
+			c = cmp.Or(cmp.Compare(a.avgReactionMs(), b.avgReactionMs()), cmp.Compare(b.correctAnswers, a.correctAnswers))
+		default:
+			c = cmp.Or(cmp.Compare(b.user.Rating, a.user.Rating), cmp.Compare(b.gamesWon, a.gamesWon))
+		}
+		return cmp.Or(c, cmp.Compare(a.user.Username, b.user.Username))
+	}
+}
+
+// leaderboardQuery is which leaderboard was asked for
+type leaderboardQuery struct {
+	period string
+	metric string
+	since  *time.Time // Start of the period, nil for all time
+}
+
+// parseLeaderboardQuery reads the period and metric query parameters
+func parseLeaderboardQuery(r *http.Request, now time.Time) (leaderboardQuery, error) {
+	q := leaderboardQuery{
+		period: cmp.Or(r.URL.Query().Get("period"), PeriodAllTime),
+		metric: cmp.Or(r.URL.Query().Get("metric"), MetricRating),
+	}
+	if q.metric != MetricRating && q.metric != MetricWins && q.metric != MetricLatency {
+		return q, fmt.Errorf("metric must be %s, %s or %s", MetricRating, MetricWins, MetricLatency)
+	}
+
+	var err error
+	q.since, err = periodStart(q.period, now)
+	return q, err
+}
+
+// rankPlayers ranks every active player who played in the period
+func rankPlayers(users []*User, q leaderboardQuery) []LeaderboardEntry {
+	standings := []standing{}
+	for _, user := range users {
+		st := standingSince(user, q.since)
+		if user.Status != UserStatusActive || st.gamesPlayed == 0 {
+			continue
+		}
+		if q.metric == MetricLatency && st.correctAnswers < minLatencyAnswers {
+			continue
+		}
+		standings = append(standings, st)
+	}
+	slices.SortFunc(standings, compareStandings(q.metric))
+
+	entries := make([]LeaderboardEntry, len(standings))
+	for i, st := range standings {
+		rank := i + 1
+		if i > 0 && st.metricValue(q.metric) == standings[i-1].metricValue(q.metric) {
+			rank = entries[i-1].Rank
+		}
+		entries[i] = LeaderboardEntry{
+			Rank:          rank,
+			UserID:        st.user.ID,
+			Username:      st.user.Username,
+			Rating:        st.user.Rating,
+			GamesPlayed:   st.gamesPlayed,
+			GamesWon:      st.gamesWon,
+			AvgReactionMs: st.avgReactionMs(),
+		}
+	}
+	return entries
+}
+
+// pageOf returns up to limit entries from offset on, empty past the end
+// Offsets come from the query string, so offset+limit must never be computed: it could overflow
+func pageOf(entries []LeaderboardEntry, limit, offset int) []LeaderboardEntry {
+	start := min(offset, len(entries))
+	return entries[start : start+min(limit, len(entries)-start)]
+}
+
+// rankAll ranks every player in the store
+func rankAll(q leaderboardQuery) ([]LeaderboardEntry, error) {
+	users, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	return rankPlayers(users, q), nil
+}
+
+// leaderboardHandler returns a page of the leaderboard
+// URL format: /leaderboard?period=all|weekly|daily&metric=rating|wins|latency&limit=&offset=
+func leaderboardHandler(w http.ResponseWriter, r *http.Request) {
+	// 1. Only accept GET requests
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 2. Parse the query
+	q, err := parseLeaderboardQuery(r, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit, err := queryInt(r, "limit", defaultLeaderboardLimit)
+	if err != nil || limit < 1 || limit > maxLeaderboardLimit {
+		http.Error(w, "limit must be between 1 and "+strconv.Itoa(maxLeaderboardLimit), http.StatusBadRequest)
+		return
+	}
+	offset, err := queryInt(r, "offset", 0)
+	if err != nil || offset < 0 {
+		http.Error(w, "offset must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	// 3. Rank everyone
+	entries, err := rankAll(q)
+	if err != nil {
+		log.Printf("Failed to build leaderboard: %v", err)
+		http.Error(w, "Failed to build leaderboard", http.StatusInternalServerError)
+		return
+	}
+
+	// 4. Return the page asked for
+	page := pageOf(entries, limit, offset)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(LeaderboardResponse{
+		Period:  q.period,
+		Metric:  q.metric,
+		Since:   q.since,
+		Entries: page,
+		Total:   len(entries),
+		Limit:   limit,
+		Offset:  offset,
+	})
+}
+
+// myRankHandler returns where the caller stands on the leaderboard
+// URL format: /leaderboard/me?period=&metric=
+// Wrapped in middleware.RequireAuth
+func myRankHandler(w http.ResponseWriter, r *http.Request) {
+	// 1. Only accept GET requests
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 2. Get user claims from JWT token (validated by middleware)
+	claims := middleware.GetUserClaims(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized - no user claims", http.StatusUnauthorized)
+		return
+	}
+
+	// 3. Rank everyone
+	q, err := parseLeaderboardQuery(r, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	entries, err := rankAll(q)
+	if err != nil {
+		log.Printf("Failed to build leaderboard: %v", err)
+		http.Error(w, "Failed to build leaderboard", http.StatusInternalServerError)
+		return
+	}
+
+	// 4. Find the caller
+	i := slices.IndexFunc(entries, func(entry LeaderboardEntry) bool {
+		return entry.UserID == claims.UserID
+	})
+	if i < 0 {
+		http.Error(w, "Not ranked for this period", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(MyRankResponse{
+		Period: q.period,
+		Metric: q.metric,
+		Since:  q.since,
+		Total:  len(entries),
+		Entry:  entries[i],
+	})
+}
+
+// queryInt reads an integer query parameter, or fallback when it is absent
+func queryInt(r *http.Request, name string, fallback int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+	return strconv.Atoi(value)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// rankedUser is a player with all-time totals and, if today is set, the same totals for that day
+func rankedUser(name string, rating, played, won, correct int, reactionMs int64, today string) *User {
+	user := &User{
+		ID:       "id-" + name,
+		Username: name,
+		Status:   UserStatusActive,
+		Rating:   rating,
+		Stats: PlayerStats{
+			GamesPlayed:    played,
+			GamesWon:       won,
+			CorrectAnswers: correct,
+			ReactionMs:     reactionMs,
+		},
+	}
+	if today != "" {
+		user.Stats.Days = []DayStats{{Date: today, GamesPlayed: played, GamesWon: won, CorrectAnswers: correct, ReactionMs: reactionMs}}
+	}
+	return user
+}
+
+// ranking is "rank:username" for every entry, for compact comparisons
+func ranking(entries []LeaderboardEntry) []string {
+	var out []string
+	for _, entry := range entries {
+		out = append(out, strconv.Itoa(entry.Rank)+":"+entry.Username)
+	}
+	return out
+}
+
+func TestRankPlayers(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC) // A Friday
+	today := dayKey(now)
+
+	banned := rankedUser("mallory", 2400, 9, 9, 40, 8000, today)
+	banned.Status = UserStatusBanned
+	lastWeek := rankedUser("olga", 2000, 8, 8, 30, 9000, "")
+	lastWeek.Stats.Days = []DayStats{{Date: "2026-10-11", GamesPlayed: 8, GamesWon: 8, CorrectAnswers: 30, ReactionMs: 9000}}
+
+	users := []*User{
+		rankedUser("carol", 1600, 4, 3, 12, 6000, today),
+		rankedUser("alice", 1600, 5, 3, 10, 4000, today),
+		rankedUser("bob", 1700, 2, 1, 4, 1200, today),
+		rankedUser("dave", 1900, 0, 0, 0, 0, ""), // Never played
+		banned,
+		lastWeek,
+	}
+
+	tests := []struct {
+		name   string
+		period string
+		metric string
+		want   []string
+	}{
+		{"rating, all time", PeriodAllTime, MetricRating, []string{"1:olga", "2:bob", "3:alice", "3:carol"}},
+		{"rating, this week", PeriodWeekly, MetricRating, []string{"1:bob", "2:alice", "2:carol"}},
+		{"wins, all time", PeriodAllTime, MetricWins, []string{"1:olga", "2:carol", "2:alice", "4:bob"}},
+		// bob has too few correct answers to be ranked on latency
+		{"latency, today", PeriodDaily, MetricLatency, []string{"1:alice", "2:carol"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			since, err := periodStart(tt.period, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := ranking(rankPlayers(users, leaderboardQuery{period: tt.period, metric: tt.metric, since: since}))
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("ranking = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPeriodStart(t *testing.T) {
+	now := time.Date(2026, 10, 18, 23, 30, 0, 0, time.UTC) // A Sunday
+
+	tests := []struct {
+		period string
+		want   string
+	}{
+		{PeriodDaily, "2026-10-18"},
+		{PeriodWeekly, "2026-10-12"}, // Weeks start on Monday
+	}
+	for _, tt := range tests {
+		since, err := periodStart(tt.period, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := dayKey(*since); got != tt.want {
+			t.Errorf("periodStart(%s) = %s, want %s", tt.period, got, tt.want)
+		}
+	}
+	if since, err := periodStart(PeriodAllTime, now); since != nil || err != nil {
+		t.Errorf("periodStart(all) = %v, %v, want nil", since, err)
+	}
+	if _, err := periodStart("monthly", now); err == nil {
+		t.Error("periodStart(monthly) succeeded, want an error")
+	}
+}
+
+func TestLeaderboardPages(t *testing.T) {
+	store = newMemoryUserStore()
+	for i, name := range []string{"alice", "bob", "carol", "dave", "erin"} {
+		user := rankedUser(name, 2000-i*100, 1, 1, 5, 1000, "")
+		user.CreatedAt = time.Now()
+		if err := store.Create(user); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		wantCode int
+		want     []string
+	}{
+		{"first page", "?limit=2", http.StatusOK, []string{"1:alice", "2:bob"}},
+		{"middle page", "?limit=2&offset=2", http.StatusOK, []string{"3:carol", "4:dave"}},
+		{"last page is short", "?limit=2&offset=4", http.StatusOK, []string{"5:erin"}},
+		{"offset at the end", "?offset=5", http.StatusOK, nil},
+		{"offset past the end", "?offset=1000", http.StatusOK, nil},
+		{"offset that would overflow", "?limit=100&offset=" + strconv.Itoa(math.MaxInt), http.StatusOK, nil},
+		{"negative offset", "?offset=-1", http.StatusBadRequest, nil},
+		{"zero limit", "?limit=0", http.StatusBadRequest, nil},
+		{"limit too large", "?limit=101", http.StatusBadRequest, nil},
+		{"offset not a number", "?offset=x", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			leaderboardHandler(w, httptest.NewRequest(http.MethodGet, "/leaderboard"+tt.query, nil))
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var response LeaderboardResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			if got := ranking(response.Entries); !slices.Equal(got, tt.want) || response.Total != 5 {
+				t.Fatalf("page = %v of %d, want %v of 5", got, response.Total, tt.want)
+			}
+		})
+	}
+}
@@ -2,33 +2,43 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"sync"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 
 	"github.com/Flokots/programming-5/colorSync/shared/auth"
+	"github.com/Flokots/programming-5/colorSync/shared/middleware"
 )
 
 // User represents a registered user
 type User struct {
-	ID        string    `json:"id"`
-	Username  string    `json:"username"`
-	Password  string    `json:"-"` // Hashed password
-	CreatedAt time.Time `json:"created_at"`
+	ID         string      `json:"id"`
+	Username   string      `json:"username"`
+	Password   string      `json:"-"`           // Hashed password
+	Status     string      `json:"status"`      // UserStatusActive, UserStatusBanned or UserStatusDeleted
+	Rating     int         `json:"rating"`      // Elo rating, starts at InitialRating
+	RatedGames int         `json:"rated_games"` // Number of games that changed the rating
+	Stats      PlayerStats `json:"stats"`       // Totals over every finished game
+	CreatedAt  time.Time   `json:"created_at"`
 }
 
-// In-memory storage
-var (
-	users       = make(map[string]*User) // userID -> User
-	usersByName = make(map[string]*User) // username -> User
-	mu          sync.RWMutex             // Mutex for thread-safe access
+// Account statuses
+const (
+	UserStatusActive  = "active"
+	UserStatusBanned  = "banned"
+	UserStatusDeleted = "deleted"
 )
 
+// User storage, selected at startup (see newUserStore)
+var store UserStore
+
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Allow requests from React DEV server
@@ -45,14 +55,67 @@ func corsMiddleware(next http.Handler) http.Handler {
 }
 
 func main() {
+	// Operator commands talk to a running user-service and exit
+	if len(os.Args) > 1 && os.Args[1] == "set-status" {
+		if err := runSetStatus(os.Args[2:]); err != nil {
+			log.Fatalf("set-status: %v", err)
+		}
+		return
+	}
+
+	// Keys for service-to-service tokens
+	if err := auth.LoadServiceKeys(auth.UserService); err != nil {
+		log.Fatalf("Failed to load service keys: %v", err)
+	}
+
+	// Open user storage
+	var err error
+	store, err = newUserStore()
+	if err != nil {
+		log.Fatalf("Failed to open user store: %v", err)
+	}
+	defer store.Close()
+
+	// Open session storage, alongside the users
+	sessions, err = newSessionStore()
+	if err != nil {
+		log.Fatalf("Failed to open session store: %v", err)
+	}
+
+	// Load the user token signing keys; this is the only service that issues user tokens
+	if err := auth.UseUserSigningKeys(); err != nil {
+		log.Fatalf("Failed to load user signing keys: %v", err)
+	}
+
+	// Pick up rotated signing keys without a restart
+	auth.WatchKeyFiles(30 * time.Second)
+
 	// Create a new ServerMux(router)
 	mux := http.NewServeMux()
 
 	// Register routes
 	mux.HandleFunc("/register", registerHandler)
 	mux.HandleFunc("/login", loginHandler)
-	mux.HandleFunc("/users/", getUserHandler) // trailing slash for /users/{id}
+	mux.HandleFunc("/users/", usersHandler) // trailing slash for /users/{id} and /users/{id}/stats
 	mux.HandleFunc("/health", healthHandler)
+	mux.HandleFunc("/.well-known/jwks.json", jwksHandler)
+	mux.HandleFunc("/token/refresh", refreshTokenHandler)
+	mux.HandleFunc("/logout", middleware.RequireAuth(logoutHandler))
+	mux.HandleFunc("/leaderboard", leaderboardHandler)
+	mux.HandleFunc("/leaderboard/me", middleware.RequireAuth(myRankHandler))
+
+	// Internal routes (service tokens only)
+	mux.HandleFunc("/internal/users/", internalUsersHandler())
+	mux.HandleFunc("/internal/matches", middleware.RequireServicePolicy(middleware.ServicePolicy{
+		Audience: auth.UserService,
+		Callers:  []string{auth.GameRulesService},
+		Scopes:   []string{auth.ScopeRatingsWrite},
+	}, matchResultHandler))
+	mux.HandleFunc("/internal/revocations", middleware.RequireServicePolicy(middleware.ServicePolicy{
+		Audience: auth.UserService,
+		Callers:  []string{auth.RoomService, auth.GameRulesService},
+		Scopes:   []string{auth.ScopeRevocationsRead},
+	}, revocationsHandler))
 
 	handler := corsMiddleware(mux) // Wrap with CORS middleware
 
@@ -62,15 +125,26 @@ func main() {
 	fmt.Printf("   POST /register - Create new user (username + password)\n")
 	fmt.Printf("   POST /login    - Authenticate user (returns JWT token)\n")
 	fmt.Printf("   GET  /users/:id - Get user info\n")
+	fmt.Printf("   GET  /users/:id/stats - Player statistics\n")
 	fmt.Printf("   GET  /health   - Health check\n")
+	fmt.Printf("   GET  /.well-known/jwks.json - Public keys for verifying user tokens\n")
+	fmt.Printf("   POST /token/refresh - Exchange refresh token for new tokens\n")
+	fmt.Printf("   POST /logout   - Revoke session (requires JWT)\n")
+	fmt.Printf("   GET  /leaderboard - Ranked players (period, metric, limit, offset)\n")
+	fmt.Printf("   GET  /leaderboard/me - Your rank (requires JWT)\n")
+	fmt.Printf("   GET  /internal/users/:id - User lookup with account status (service token)\n")
+	fmt.Printf("   PUT  /internal/users/:id/status - Ban, delete or reinstate a user (admin token)\n")
+	fmt.Printf("   POST /internal/matches - Report a game outcome, updates ratings (service token)\n")
+	fmt.Printf("   GET  /internal/revocations - Revoked token IDs (service token)\n")
 	fmt.Printf("\n")
 	log.Fatal(http.ListenAndServe(port, handler))
 }
 
 func healthHandler(w http.ResponseWriter, r *http.Request) {
-	mu.RLock()
-	userCount := len(users)
-	mu.RUnlock()
+	userCount, err := store.Count()
+	if err != nil {
+		log.Printf("Failed to count users: %v", err)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -81,17 +155,31 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// jwksHandler publishes the public user token keys so other services can verify (but not issue) tokens
+func jwksHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(auth.UserJWKS())
+}
+
 type RegisterRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
 }
 
 type RegisterResponse struct {
-	ID        string    `json:"id"`
-	Username  string    `json:"username"`
-	Token     string    `json:"token"`
-	CreatedAt time.Time `json:"created_at"`
-	Message   string    `json:"message"`
+	ID           string    `json:"id"`
+	Username     string    `json:"username"`
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresIn    int       `json:"expires_in"`
+	CreatedAt    time.Time `json:"created_at"`
+	Message      string    `json:"message"`
 }
 
 // registerHandler creates a new user with hashed password and returns a JWT token
@@ -132,14 +220,14 @@ func registerHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 6. Check if username already exists (thread-safe read)
-	mu.RLock()
-	_, exists := usersByName[req.Username]
-	mu.RUnlock()
-
-	if exists {
+	// 6. Check if username already exists
+	if _, err := store.GetByUsername(req.Username); err == nil {
 		http.Error(w, "Username already taken", http.StatusConflict)
 		return
+	} else if !errors.Is(err, ErrUserNotFound) {
+		log.Printf("Failed to look up user %s: %v", req.Username, err)
+		http.Error(w, "Failed to create user", http.StatusInternalServerError)
+		return
 	}
 
 	// 7. Hash password using bcrypt
@@ -158,17 +246,24 @@ func registerHandler(w http.ResponseWriter, r *http.Request) {
 		ID:        uuid.New().String(),
 		Username:  req.Username,
 		Password:  string(hashedPassword),
+		Status:    UserStatusActive,
+		Rating:    InitialRating,
 		CreatedAt: time.Now(),
 	}
 
-	// 9. Store user in memory (thread-safe write)
-	mu.Lock()
-	users[user.ID] = user
-	usersByName[user.Username] = user
-	mu.Unlock()
+	// 9. Store user (the store re-checks the username under its own lock)
+	if err := store.Create(user); err != nil {
+		if errors.Is(err, ErrUsernameTaken) {
+			http.Error(w, "Username already taken", http.StatusConflict)
+			return
+		}
+		log.Printf("Failed to store user: %v", err)
+		http.Error(w, "Failed to create user", http.StatusInternalServerError)
+		return
+	}
 
-	// 10. Generate JWT token
-	token, err := auth.GenerateUserToken(user.ID, user.Username)
+	// 10. Generate JWT access token and refresh token
+	pair, err := sessions.startSession(user)
 	if err != nil {
 		log.Printf("Failed to generate token: %v", err)
 		http.Error(w, "User created but failed to generate token", http.StatusInternalServerError)
@@ -182,11 +277,13 @@ func registerHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(RegisterResponse{
-		ID:        user.ID,
-		Username:  user.Username,
-		Token:     token,
-		CreatedAt: user.CreatedAt,
-		Message:   "User registered successfully",
+		ID:           user.ID,
+		Username:     user.Username,
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresIn:    pair.ExpiresIn,
+		CreatedAt:    user.CreatedAt,
+		Message:      "User registered successfully",
 	})
 
 	log.Printf("Registered new user: %s (ID: %s)", user.Username, user.ID)
@@ -195,9 +292,19 @@ func registerHandler(w http.ResponseWriter, r *http.Request) {
 type UserResponse struct {
 	ID        string    `json:"id"`
 	Username  string    `json:"username"`
+	Rating    int       `json:"rating"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// usersHandler routes /users/{id} and /users/{id}/stats
+func usersHandler(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(r.URL.Path, "/stats") {
+		userStatsHandler(w, r)
+		return
+	}
+	getUserHandler(w, r)
+}
+
 func getUserHandler(w http.ResponseWriter, r *http.Request) {
 	// 1. Only accept GET requests
 	if r.Method != http.MethodGet {
@@ -215,16 +322,19 @@ func getUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	userID := path[len(usersPrefix):]
-	// 3. Look up user (thread-safe read)
-	mu.RLock()
-	user, exists := users[userID]
-	mu.RUnlock()
+	// 3. Look up user
+	user, err := store.GetByID(userID)
 
 	// 4. Check if user exists
-	if !exists {
+	if errors.Is(err, ErrUserNotFound) {
 		http.Error(w, "User not found", http.StatusNotFound)
 		return
 	}
+	if err != nil {
+		log.Printf("Failed to look up user %s: %v", userID, err)
+		http.Error(w, "Failed to look up user", http.StatusInternalServerError)
+		return
+	}
 
 	// 5. Return user info
 	w.Header().Set("Content-Type", "application/json")
@@ -232,6 +342,7 @@ func getUserHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(UserResponse{
 		ID:        user.ID,
 		Username:  user.Username,
+		Rating:    user.Rating,
 		CreatedAt: user.CreatedAt,
 	})
 
@@ -244,11 +355,13 @@ type LoginRequest struct {
 }
 
 type LoginResponse struct {
-	ID        string    `json:"id"`
-	Username  string    `json:"username"`
-	Token     string    `json:"token"`
-	CreatedAt time.Time `json:"created_at"`
-	Message   string    `json:"message"`
+	ID           string    `json:"id"`
+	Username     string    `json:"username"`
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresIn    int       `json:"expires_in"`
+	CreatedAt    time.Time `json:"created_at"`
+	Message      string    `json:"message"`
 }
 
 // loginHandler authenticates user and returns JWT token
@@ -272,20 +385,22 @@ func loginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 4. Check if user exists (thread-safe read)
-	mu.RLock()
-	user, exists := usersByName[req.Username]
-	mu.RUnlock()
-
-	if !exists {
+	// 4. Check if user exists
+	user, err := store.GetByUsername(req.Username)
+	if errors.Is(err, ErrUserNotFound) {
 		// Use generic error to prevent username enumeration
 		log.Printf("Login attempt for non-existent user: %s", req.Username)
 		http.Error(w, "Invalid username or password", http.StatusUnauthorized)
 		return
 	}
+	if err != nil {
+		log.Printf("Failed to look up user %s: %v", req.Username, err)
+		http.Error(w, "Login failed", http.StatusInternalServerError)
+		return
+	}
 
 	// 5. Verify password using bcrypt
-	err := bcrypt.CompareHashAndPassword(
+	err = bcrypt.CompareHashAndPassword(
 		[]byte(user.Password), // Hashed password from storage
 		[]byte(req.Password),  // Plain text password from request
 	)
@@ -296,8 +411,15 @@ func loginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 6. Generate JWT token
-	token, err := auth.GenerateUserToken(user.ID, user.Username)
+	// 6. Refuse banned or deleted accounts
+	if user.Status != UserStatusActive {
+		log.Printf("Login attempt for %s account: %s", user.Status, req.Username)
+		http.Error(w, "Account is not active", http.StatusForbidden)
+		return
+	}
+
+	// 7. Generate JWT access token and refresh token
+	pair, err := sessions.startSession(user)
 	if err != nil {
 		log.Printf("Failed to generate token: %v", err)
 		http.Error(w, "Login successful but failed to generate token", http.StatusInternalServerError)
@@ -307,14 +429,16 @@ func loginHandler(w http.ResponseWriter, r *http.Request) {
 	log.Printf("User logged in: %s (ID: %s)", user.Username, user.ID)
 	log.Printf("JWT token generated for: %s", user.Username)
 
-	// 6. Return user info (successful login) with JWT token
+	// 8. Return user info (successful login) with JWT token
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(LoginResponse{
-		ID:        user.ID,
-		Username:  user.Username,
-		Token:     token,
-		CreatedAt: user.CreatedAt,
-		Message:   "Login successful",
+		ID:           user.ID,
+		Username:     user.Username,
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresIn:    pair.ExpiresIn,
+		CreatedAt:    user.CreatedAt,
+		Message:      "Login successful",
 	})
 }
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// InitialRating is where every new player starts
+	InitialRating = 1500
+
+	// Rating change factors: provisional players move faster until their rating settles
+	eloKFactor            = 32
+	eloProvisionalKFactor = 48
+	eloProvisionalGames   = 10
+
+	// How long a room ID is remembered so retried reports aren't counted twice
+	matchResultRetention = 24 * time.Hour
+)
+
+// MatchResultRequest is sent by Game Rules Service when a game ends
+type MatchResultRequest struct {
+	RoomID   string                `json:"room_id"`
+	Players  []string              `json:"players"`
+	WinnerID string                `json:"winner_id"`       // Empty for a draw
+	Stats    map[string]MatchStats `json:"stats,omitempty"` // Player ID to how they played, for their statistics
+}
+
+// RatingChange is one player's rating before and after a game
+type RatingChange struct {
+	UserID string `json:"user_id"`
+	Old    int    `json:"old_rating"`
+	New    int    `json:"new_rating"`
+}
+
+type MatchResultResponse struct {
+	RoomID  string         `json:"room_id"`
+	Changes []RatingChange `json:"changes"`
+}
+
+// matchResults serializes applyMatchResult, so a room reported twice at once is only rated once
+var matchResults sync.Mutex
+
+// expectedScore is the Elo win probability of a rating against an opponent's
+func expectedScore(rating, opponent int) float64 {
+	return 1 / (1 + math.Pow(10, float64(opponent-rating)/400))
+}
+
+// kFactor is larger while a player's rating is still provisional
+func kFactor(user *User) float64 {
+	if user.RatedGames < eloProvisionalGames {
+		return eloProvisionalKFactor
+	}
+	return eloKFactor
+}
+
+// newRatings applies one game to both players; score is 1 for a win, 0.5 draw, 0 loss (for a)
+func newRatings(a, b *User, score float64) (int, int) {
+	expectedA := expectedScore(a.Rating, b.Rating)
+	expectedB := expectedScore(b.Rating, a.Rating)
+
+	ratingA := a.Rating + int(math.Round(kFactor(a)*(score-expectedA)))
+	ratingB := b.Rating + int(math.Round(kFactor(b)*((1-score)-expectedB)))
+	return ratingA, ratingB
+}
+
+// applyMatchResult updates both players' ratings and statistics once per room
+func applyMatchResult(req MatchResultRequest) (*MatchResultResponse, error) {
+	matchResults.Lock()
+	defer matchResults.Unlock()
+
+	previous, err := store.GetMatch(req.RoomID)
+	switch {
+	case err == nil && time.Since(previous.AppliedAt) <= matchResultRetention:
+		log.Printf("Result for room %s already applied, ignoring duplicate", req.RoomID)
+		return &previous.Result, nil
+	case err != nil && !errors.Is(err, ErrMatchNotFound):
+		return nil, err
+	}
+
+	a, err := store.GetByID(req.Players[0])
+	if err != nil {
+		return nil, err
+	}
+	b, err := store.GetByID(req.Players[1])
+	if err != nil {
+		return nil, err
+	}
+
+	score := 0.5
+	switch req.WinnerID {
+	case a.ID:
+		score = 1
+	case b.ID:
+		score = 0
+	}
+
+	ratingA, ratingB := newRatings(a, b, score)
+	response := MatchResultResponse{
+		RoomID: req.RoomID,
+		Changes: []RatingChange{
+			{UserID: a.ID, Old: a.Rating, New: ratingA},
+			{UserID: b.ID, Old: b.Rating, New: ratingB},
+		},
+	}
+
+	a.Rating, b.Rating = ratingA, ratingB
+	a.RatedGames++
+	b.RatedGames++
+	now := time.Now()
+	a.Stats.recordGame(now, score, req.Stats[a.ID])
+	b.Stats.recordGame(now, 1-score, req.Stats[b.ID])
+	// Both players and the room ID are stored together, so a failed write can be retried safely
+	if err := store.RecordMatch(&AppliedMatch{Result: response, AppliedAt: now}, []*User{a, b}); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// matchResultHandler records a finished game and returns the rating changes
+// Wrapped in middleware.RequireServicePolicy
+func matchResultHandler(w http.ResponseWriter, r *http.Request) {
+	// 1. Only accept POST requests
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 2. Parse and validate the result
+	var req MatchResultRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.RoomID == "" || len(req.Players) != 2 || req.Players[0] == req.Players[1] {
+		http.Error(w, "room_id and two distinct players required", http.StatusBadRequest)
+		return
+	}
+	if req.WinnerID != "" && req.WinnerID != req.Players[0] && req.WinnerID != req.Players[1] {
+		http.Error(w, "winner_id must be one of the players", http.StatusBadRequest)
+		return
+	}
+
+	// 3. Update ratings
+	response, err := applyMatchResult(req)
+	if errors.Is(err, ErrUserNotFound) {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to apply result for room %s: %v", req.RoomID, err)
+		http.Error(w, "Failed to update ratings", http.StatusInternalServerError)
+		return
+	}
+
+	for _, change := range response.Changes {
+		log.Printf("Rating %s: %d -> %d (room %s)", change.UserID, change.Old, change.New, req.RoomID)
+	}
+
+	// 4. Return the rating changes
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
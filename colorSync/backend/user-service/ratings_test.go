@@ -0,0 +1,124 @@
+package main
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExpectedScore(t *testing.T) {
+	tests := []struct {
+		rating, opponent int
+		want             float64
+	}{
+		{1500, 1500, 0.5},
+		{1900, 1500, 10.0 / 11},
+		{1500, 1900, 1.0 / 11},
+		{2300, 1500, 100.0 / 101},
+	}
+	for _, tt := range tests {
+		if got := expectedScore(tt.rating, tt.opponent); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("expectedScore(%d, %d) = %f, want %f", tt.rating, tt.opponent, got, tt.want)
+		}
+	}
+}
+
+func TestNewRatings(t *testing.T) {
+	settled := eloProvisionalGames
+
+	tests := []struct {
+		name         string
+		a, b         User
+		score        float64
+		wantA, wantB int
+	}{
+		{"equal players, a wins", User{Rating: 1500, RatedGames: settled}, User{Rating: 1500, RatedGames: settled}, 1, 1516, 1484},
+		{"equal players, draw", User{Rating: 1500, RatedGames: settled}, User{Rating: 1500, RatedGames: settled}, 0.5, 1500, 1500},
+		{"favourite wins, small gain", User{Rating: 1900, RatedGames: settled}, User{Rating: 1500, RatedGames: settled}, 1, 1903, 1497},
+		{"upset, big swing", User{Rating: 1500, RatedGames: settled}, User{Rating: 1900, RatedGames: settled}, 1, 1529, 1871},
+		{"provisional player moves faster", User{Rating: 1500}, User{Rating: 1500, RatedGames: settled}, 1, 1524, 1484},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotA, gotB := newRatings(&tt.a, &tt.b, tt.score)
+			if gotA != tt.wantA || gotB != tt.wantB {
+				t.Fatalf("newRatings = %d, %d, want %d, %d", gotA, gotB, tt.wantA, tt.wantB)
+			}
+		})
+	}
+}
+
+// useTestPlayers fills the store with two unrated players
+func useTestPlayers(t *testing.T, s UserStore) (*User, *User) {
+	t.Helper()
+	store = s
+	alice := &User{ID: "user-1", Username: "alice", Status: UserStatusActive, Rating: InitialRating, CreatedAt: time.Now()}
+	bob := &User{ID: "user-2", Username: "bob", Status: UserStatusActive, Rating: InitialRating, CreatedAt: time.Now()}
+	for _, user := range []*User{alice, bob} {
+		if err := store.Create(user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return alice, bob
+}
+
+func TestApplyMatchResultOnce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+	fileStore, err := openFileUserStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice, bob := useTestPlayers(t, fileStore)
+
+	req := MatchResultRequest{RoomID: "room-1", Players: []string{alice.ID, bob.ID}, WinnerID: alice.ID}
+	first, err := applyMatchResult(req)
+	if err != nil {
+		t.Fatalf("applyMatchResult: %v", err)
+	}
+
+	// A retry after a restart still finds the room and changes nothing
+	reopened, err := openFileUserStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store = reopened
+	again, err := applyMatchResult(req)
+	if err != nil {
+		t.Fatalf("applyMatchResult retry: %v", err)
+	}
+	if again.Changes[0] != first.Changes[0] || again.Changes[1] != first.Changes[1] {
+		t.Fatalf("retry returned %+v, want the first result %+v", again.Changes, first.Changes)
+	}
+
+	for _, change := range first.Changes {
+		user, err := store.GetByID(change.UserID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.Rating != change.New || user.RatedGames != 1 {
+			t.Fatalf("%s has rating %d after %d games, want %d after 1", user.Username, user.Rating, user.RatedGames, change.New)
+		}
+	}
+}
+
+func TestApplyMatchResultMissingPlayerChangesNothing(t *testing.T) {
+	alice, _ := useTestPlayers(t, newMemoryUserStore())
+
+	ghost := &User{ID: "ghost"}
+	if err := store.RecordMatch(&AppliedMatch{Result: MatchResultResponse{RoomID: "room-1"}, AppliedAt: time.Now()},
+		[]*User{{ID: alice.ID, Rating: 2000, RatedGames: 1}, ghost}); err != ErrUserNotFound {
+		t.Fatalf("RecordMatch = %v, want ErrUserNotFound", err)
+	}
+
+	stored, err := store.GetByID(alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Rating != InitialRating || stored.RatedGames != 0 {
+		t.Fatalf("alice has rating %d after %d games, want the rating untouched", stored.Rating, stored.RatedGames)
+	}
+	if _, err := store.GetMatch("room-1"); err != ErrMatchNotFound {
+		t.Fatalf("GetMatch = %v, want ErrMatchNotFound", err)
+	}
+}
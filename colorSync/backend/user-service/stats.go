@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Daily totals are kept for this many days, enough for the weekly leaderboard
+const statsDaysKept = 7
+
+// PlayerStats are a player's totals over every game they finished
+// Kept on the User and updated with each match result (see applyMatchResult)
+type PlayerStats struct {
+	GamesPlayed    int   `json:"games_played"`
+	GamesWon       int   `json:"games_won"`
+	GamesLost      int   `json:"games_lost"`
+	GamesDrawn     int   `json:"games_drawn"`
+	RoundsPlayed   int   `json:"rounds_played"`
+	RoundsWon      int   `json:"rounds_won"`
+	CorrectAnswers int   `json:"correct_answers"`
+	WrongAnswers   int   `json:"wrong_answers"`
+	ReactionMs     int64 `json:"reaction_ms"`      // Sum over correct answers
+	BestReactionMs int64 `json:"best_reaction_ms"` // Fastest correct answer, 0 if none yet
+	Streak         int   `json:"streak"`           // Wins in a row if positive, losses if negative
+
+	Days []DayStats `json:"days"` // The last statsDaysKept days played, oldest first
+}
+
+// DayStats are a player's totals over one UTC day
+type DayStats struct {
+	Date           string `json:"date"` // YYYY-MM-DD
+	GamesPlayed    int    `json:"games_played"`
+	GamesWon       int    `json:"games_won"`
+	CorrectAnswers int    `json:"correct_answers"`
+	ReactionMs     int64  `json:"reaction_ms"` // Sum over correct answers
+}
+
+// dayKey is the DayStats.Date of the day t falls on
+func dayKey(t time.Time) string {
+	return t.UTC().Format(time.DateOnly)
+}
+
+// MatchStats is one player's part in a game, as reported by Game Rules Service
+type MatchStats struct {
+	RoundsPlayed   int   `json:"rounds_played"`
+	RoundsWon      int   `json:"rounds_won"`
+	CorrectAnswers int   `json:"correct_answers"`
+	WrongAnswers   int   `json:"wrong_answers"`
+	ReactionMs     int64 `json:"reaction_ms"`      // Sum over correct answers
+	BestReactionMs int64 `json:"best_reaction_ms"` // Fastest correct answer, 0 if none
+}
+
+// recordGame adds one game played at the given time to the totals
+// score is 1 for a win, 0.5 draw, 0 loss
+func (s *PlayerStats) recordGame(at time.Time, score float64, match MatchStats) {
+	s.GamesPlayed++
+	switch score {
+	case 1:
+		s.GamesWon++
+		s.Streak = max(s.Streak, 0) + 1
+	case 0:
+		s.GamesLost++
+		s.Streak = min(s.Streak, 0) - 1
+	default:
+		s.GamesDrawn++
+		s.Streak = 0
+	}
+
+	s.RoundsPlayed += match.RoundsPlayed
+	s.RoundsWon += match.RoundsWon
+	s.CorrectAnswers += match.CorrectAnswers
+	s.WrongAnswers += match.WrongAnswers
+	s.ReactionMs += match.ReactionMs
+	if match.BestReactionMs > 0 && (s.BestReactionMs == 0 || match.BestReactionMs < s.BestReactionMs) {
+		s.BestReactionMs = match.BestReactionMs
+	}
+
+	// Today's totals, dropping days too old to matter
+	// Copied first, stored users may share the slice with this one
+	s.Days = slices.Clone(s.Days)
+	today := dayKey(at)
+	if len(s.Days) == 0 || s.Days[len(s.Days)-1].Date != today {
+		s.Days = append(s.Days, DayStats{Date: today})
+	}
+	oldest := dayKey(at.AddDate(0, 0, 1-statsDaysKept))
+	for len(s.Days) > 0 && s.Days[0].Date < oldest {
+		s.Days = s.Days[1:]
+	}
+	day := &s.Days[len(s.Days)-1]
+	day.GamesPlayed++
+	if score == 1 {
+		day.GamesWon++
+	}
+	day.CorrectAnswers += match.CorrectAnswers
+	day.ReactionMs += match.ReactionMs
+}
+
+// UserStatsResponse is the body of GET /users/{id}/stats
+type UserStatsResponse struct {
+	UserID          string  `json:"user_id"`
+	Username        string  `json:"username"`
+	Rating          int     `json:"rating"`
+	GamesPlayed     int     `json:"games_played"`
+	GamesWon        int     `json:"games_won"`
+	GamesLost       int     `json:"games_lost"`
+	GamesDrawn      int     `json:"games_drawn"`
+	RoundsPlayed    int     `json:"rounds_played"`
+	RoundsWon       int     `json:"rounds_won"`
+	AvgReactionMs   int64   `json:"avg_reaction_ms"`   // Over correct answers
+	BestReactionMs  int64   `json:"best_reaction_ms"`  // 0 if no correct answer yet
+	Accuracy        float64 `json:"accuracy"`          // Share of answers that were correct
+	WrongAnswerRate float64 `json:"wrong_answer_rate"` // Wrong answers per round played
+	CurrentStreak   int     `json:"current_streak"`    // Wins in a row if positive, losses if negative
+}
+
+func newUserStatsResponse(user *User) UserStatsResponse {
+	stats := user.Stats
+	response := UserStatsResponse{
+		UserID:         user.ID,
+		Username:       user.Username,
+		Rating:         user.Rating,
+		GamesPlayed:    stats.GamesPlayed,
+		GamesWon:       stats.GamesWon,
+		GamesLost:      stats.GamesLost,
+		GamesDrawn:     stats.GamesDrawn,
+		RoundsPlayed:   stats.RoundsPlayed,
+		RoundsWon:      stats.RoundsWon,
+		BestReactionMs: stats.BestReactionMs,
+		CurrentStreak:  stats.Streak,
+	}
+	if stats.CorrectAnswers > 0 {
+		response.AvgReactionMs = stats.ReactionMs / int64(stats.CorrectAnswers)
+	}
+	if answers := stats.CorrectAnswers + stats.WrongAnswers; answers > 0 {
+		response.Accuracy = ratio(stats.CorrectAnswers, answers)
+	}
+	if stats.RoundsPlayed > 0 {
+		response.WrongAnswerRate = ratio(stats.WrongAnswers, stats.RoundsPlayed)
+	}
+	return response
+}
+
+// ratio is n/d rounded to three decimals
+func ratio(n, d int) float64 {
+	return math.Round(float64(n)/float64(d)*1000) / 1000
+}
+
+// userStatsHandler returns a player's lifetime statistics
+// URL format: /users/{id}/stats
+func userStatsHandler(w http.ResponseWriter, r *http.Request) {
+	// 1. Only accept GET requests
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 2. Extract user ID from URL path
+	userID := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/users/"), "/stats")
+	if userID == "" || strings.Contains(userID, "/") {
+		http.Error(w, "User ID required", http.StatusBadRequest)
+		return
+	}
+
+	// 3. Look up user
+	user, err := store.GetByID(userID)
+	if errors.Is(err, ErrUserNotFound) {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to look up user %s: %v", userID, err)
+		http.Error(w, "Failed to look up user", http.StatusInternalServerError)
+		return
+	}
+
+	// 4. Return the statistics
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newUserStatsResponse(user))
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRecordGame(t *testing.T) {
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	var stats PlayerStats
+
+	// Games played in order, each checked against the totals it leaves
+	tests := []struct {
+		name       string
+		day        int // Days after start
+		score      float64
+		match      MatchStats
+		wantStreak int
+		wantBest   int64
+		wantDays   int    // Days kept
+		wantOldest string // Date of the oldest day kept
+	}{
+		{"first win", 0, 1, MatchStats{RoundsPlayed: 5, RoundsWon: 3, CorrectAnswers: 3, ReactionMs: 1800, BestReactionMs: 500}, 1, 500, 1, "2026-10-01"},
+		{"second win the same day", 0, 1, MatchStats{RoundsPlayed: 5, RoundsWon: 4, CorrectAnswers: 4, ReactionMs: 2000, BestReactionMs: 400}, 2, 400, 1, "2026-10-01"},
+		{"loss ends the winning streak", 2, 0, MatchStats{RoundsPlayed: 5, RoundsWon: 1, CorrectAnswers: 1, WrongAnswers: 2, ReactionMs: 900, BestReactionMs: 900}, -1, 400, 2, "2026-10-01"},
+		{"losses add up", 3, 0, MatchStats{RoundsPlayed: 5, WrongAnswers: 1}, -2, 400, 3, "2026-10-01"},
+		{"draw resets the streak", 4, 0.5, MatchStats{RoundsPlayed: 5, RoundsWon: 2, CorrectAnswers: 2, ReactionMs: 1000, BestReactionMs: 450}, 0, 400, 4, "2026-10-01"},
+		{"win after a draw", 6, 1, MatchStats{RoundsPlayed: 5, RoundsWon: 3, CorrectAnswers: 3, ReactionMs: 1500, BestReactionMs: 300}, 1, 300, 5, "2026-10-01"},
+		{"a week on, the first day is dropped", 7, 1, MatchStats{RoundsPlayed: 5, RoundsWon: 3, CorrectAnswers: 3, ReactionMs: 1500, BestReactionMs: 350}, 2, 300, 5, "2026-10-03"},
+		{"a long break leaves only today", 30, 0, MatchStats{RoundsPlayed: 5}, -1, 300, 1, "2026-10-31"},
+	}
+	for _, tt := range tests {
+		stats.recordGame(start.AddDate(0, 0, tt.day), tt.score, tt.match)
+
+		if stats.Streak != tt.wantStreak || stats.BestReactionMs != tt.wantBest {
+			t.Fatalf("%s: streak %d, best %dms, want %d, %dms", tt.name, stats.Streak, stats.BestReactionMs, tt.wantStreak, tt.wantBest)
+		}
+		if len(stats.Days) != tt.wantDays || stats.Days[0].Date != tt.wantOldest {
+			t.Fatalf("%s: days %+v, want %d from %s", tt.name, stats.Days, tt.wantDays, tt.wantOldest)
+		}
+	}
+
+	want := PlayerStats{
+		GamesPlayed: 8, GamesWon: 4, GamesLost: 3, GamesDrawn: 1,
+		RoundsPlayed: 40, RoundsWon: 16, CorrectAnswers: 16, WrongAnswers: 3,
+		ReactionMs: 8700, BestReactionMs: 300, Streak: -1,
+	}
+	stats.Days = nil
+	if !reflect.DeepEqual(stats, want) {
+		t.Fatalf("totals = %+v, want %+v", stats, want)
+	}
+}
+
+func TestRecordGameCountsToday(t *testing.T) {
+	at := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	var stats PlayerStats
+	stats.recordGame(at, 1, MatchStats{CorrectAnswers: 3, ReactionMs: 1500})
+	stats.recordGame(at.Add(time.Hour), 0, MatchStats{CorrectAnswers: 1, ReactionMs: 700})
+
+	want := DayStats{Date: "2026-10-16", GamesPlayed: 2, GamesWon: 1, CorrectAnswers: 4, ReactionMs: 2200}
+	if len(stats.Days) != 1 || stats.Days[0] != want {
+		t.Fatalf("days = %+v, want %+v", stats.Days, want)
+	}
+}
+
+func TestNewUserStatsResponse(t *testing.T) {
+	tests := []struct {
+		name  string
+		stats PlayerStats
+		want  UserStatsResponse
+	}{
+		{"never played", PlayerStats{}, UserStatsResponse{}},
+		{
+			"rates rounded to three decimals",
+			PlayerStats{GamesPlayed: 3, GamesWon: 2, GamesLost: 1, RoundsPlayed: 15, RoundsWon: 8, CorrectAnswers: 8, WrongAnswers: 4, ReactionMs: 5000, BestReactionMs: 320, Streak: 2},
+			UserStatsResponse{GamesPlayed: 3, GamesWon: 2, GamesLost: 1, RoundsPlayed: 15, RoundsWon: 8, AvgReactionMs: 625, BestReactionMs: 320, Accuracy: 0.667, WrongAnswerRate: 0.267, CurrentStreak: 2},
+		},
+		{
+			"only wrong answers",
+			PlayerStats{GamesPlayed: 1, GamesLost: 1, RoundsPlayed: 5, WrongAnswers: 5, Streak: -1},
+			UserStatsResponse{GamesPlayed: 1, GamesLost: 1, RoundsPlayed: 5, WrongAnswerRate: 1, CurrentStreak: -1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newUserStatsResponse(&User{Stats: tt.stats})
+			if got != tt.want {
+				t.Fatalf("response = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserStatsHandler(t *testing.T) {
+	store = newMemoryUserStore()
+	alice := rankedUser("alice", 1580, 4, 3, 12, 6000, "")
+	if err := store.Create(alice); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		wantCode int
+	}{
+		{"known player", http.MethodGet, "/users/" + alice.ID + "/stats", http.StatusOK},
+		{"unknown player", http.MethodGet, "/users/nobody/stats", http.StatusNotFound},
+		{"no id", http.MethodGet, "/users//stats", http.StatusBadRequest},
+		{"wrong method", http.MethodPost, "/users/" + alice.ID + "/stats", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			userStatsHandler(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+				t.Fatalf("Content-Type = %q, want application/json", ct)
+			}
+			var got map[string]interface{}
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got["user_id"] != alice.ID || got["username"] != "alice" || got["rating"] != 1580.0 ||
+				got["games_won"] != 3.0 || got["avg_reaction_ms"] != 500.0 {
+				t.Fatalf("response = %v, want alice's stats", got)
+			}
+			for _, field := range []string{"games_lost", "games_drawn", "rounds_played", "rounds_won", "best_reaction_ms", "accuracy", "wrong_answer_rate", "current_streak"} {
+				if _, ok := got[field]; !ok {
+					t.Errorf("response has no %s", field)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Errors returned by UserStore implementations
+var (
+	ErrUserNotFound  = errors.New("user not found")
+	ErrUsernameTaken = errors.New("username already taken")
+	ErrInvalidStatus = errors.New("invalid account status")
+	ErrMatchNotFound = errors.New("match result not found")
+)
+
+// UserStore abstracts where user accounts are kept
+// Handlers only talk to this interface, so the backend can be swapped at startup
+type UserStore interface {
+	// Create stores a new user, returns ErrUsernameTaken if the name is in use
+	Create(user *User) error
+
+	// GetByID looks up a user by ID, returns ErrUserNotFound if missing
+	GetByID(id string) (*User, error)
+
+	// GetByUsername looks up a user by username, returns ErrUserNotFound if missing
+	GetByUsername(username string) (*User, error)
+
+	// Update replaces a stored user (matched by ID), returns ErrUserNotFound if missing
+	// The username and status can't be changed, so an update from a stale copy can't lift a ban
+	Update(user *User) error
+
+	// SetStatus changes a user's account status, returns ErrUserNotFound if missing
+	// or ErrInvalidStatus for anything but UserStatusActive, UserStatusBanned and UserStatusDeleted
+	SetStatus(id, status string) error
+
+	// RecordMatch replaces the players of a rated game and remembers its result by room ID, in one write
+	// Either every change is stored or none is; returns ErrUserNotFound if a player is missing
+	// Results recorded more than matchResultRetention before this one are forgotten
+	RecordMatch(match *AppliedMatch, players []*User) error
+
+	// GetMatch returns the result recorded for a room, ErrMatchNotFound if there is none
+	GetMatch(roomID string) (*AppliedMatch, error)
+
+	// Count returns the number of registered users
+	Count() (int, error)
+
+	// List returns every registered user, in no particular order
+	List() ([]*User, error)
+
+	// Close releases any resources held by the store
+	Close() error
+}
+
+// newUserStore picks a store implementation based on the environment
+// USER_STORE=memory (default) keeps users in memory only
+// USER_STORE=file persists users to USER_STORE_PATH (default users.json)
+func newUserStore() (UserStore, error) {
+	switch kind := getEnv("USER_STORE", "memory"); kind {
+	case "memory":
+		return newMemoryUserStore(), nil
+	case "file":
+		return openFileUserStore(getEnv("USER_STORE_PATH", "users.json"))
+	default:
+		return nil, fmt.Errorf("unknown USER_STORE %q (use memory or file)", kind)
+	}
+}
+
+// getEnv returns the environment variable value or a fallback
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// AppliedMatch is a game result that has been applied to ratings
+// Kept so a retried report returns the first result instead of rating again
+type AppliedMatch struct {
+	Result    MatchResultResponse `json:"result"`
+	AppliedAt time.Time           `json:"applied_at"`
+}
+
+// memoryUserStore keeps users in maps guarded by a mutex
+// Everything is lost on restart
+type memoryUserStore struct {
+	users       map[string]*User         // userID -> User
+	usersByName map[string]*User         // username -> User
+	matches     map[string]*AppliedMatch // roomID -> applied result
+	mu          sync.RWMutex             // Mutex for thread-safe access
+}
+
+func newMemoryUserStore() *memoryUserStore {
+	return &memoryUserStore{
+		users:       make(map[string]*User),
+		usersByName: make(map[string]*User),
+		matches:     make(map[string]*AppliedMatch),
+	}
+}
+
+func (s *memoryUserStore) Create(user *User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.usersByName[user.Username]; exists {
+		return ErrUsernameTaken
+	}
+
+	stored := *user
+	s.users[stored.ID] = &stored
+	s.usersByName[stored.Username] = &stored
+	return nil
+}
+
+func (s *memoryUserStore) GetByID(id string) (*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, exists := s.users[id]
+	if !exists {
+		return nil, ErrUserNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (s *memoryUserStore) GetByUsername(username string) (*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, exists := s.usersByName[username]
+	if !exists {
+		return nil, ErrUserNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (s *memoryUserStore) Update(user *User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.updateLocked(user)
+}
+
+// updateLocked is Update, caller must hold s.mu
+func (s *memoryUserStore) updateLocked(user *User) error {
+	existing, exists := s.users[user.ID]
+	if !exists {
+		return ErrUserNotFound
+	}
+
+	stored := *user
+	stored.Username = existing.Username
+	stored.Status = existing.Status
+	s.users[stored.ID] = &stored
+	s.usersByName[stored.Username] = &stored
+	return nil
+}
+
+func (s *memoryUserStore) RecordMatch(match *AppliedMatch, players []*User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Check every player first, so nothing changes if one is missing
+	for _, user := range players {
+		if _, exists := s.users[user.ID]; !exists {
+			return ErrUserNotFound
+		}
+	}
+	for _, user := range players {
+		s.updateLocked(user)
+	}
+
+	// Forget old rooms
+	for roomID, old := range s.matches {
+		if match.AppliedAt.Sub(old.AppliedAt) > matchResultRetention {
+			delete(s.matches, roomID)
+		}
+	}
+	stored := *match
+	s.matches[match.Result.RoomID] = &stored
+	return nil
+}
+
+func (s *memoryUserStore) GetMatch(roomID string) (*AppliedMatch, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	match, exists := s.matches[roomID]
+	if !exists {
+		return nil, ErrMatchNotFound
+	}
+	copied := *match
+	return &copied, nil
+}
+
+func (s *memoryUserStore) SetStatus(id, status string) error {
+	switch status {
+	case UserStatusActive, UserStatusBanned, UserStatusDeleted:
+	default:
+		return ErrInvalidStatus
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, exists := s.users[id]
+	if !exists {
+		return ErrUserNotFound
+	}
+	user.Status = status
+	return nil
+}
+
+func (s *memoryUserStore) Count() (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.users), nil
+}
+
+func (s *memoryUserStore) List() ([]*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]*User, 0, len(s.users))
+	for _, user := range s.users {
+		copied := *user
+		users = append(users, &copied)
+	}
+	return users, nil
+}
+
+// snapshotMatches copies the remembered results, so a failed write can be rolled back
+func (s *memoryUserStore) snapshotMatches() map[string]*AppliedMatch {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	matches := make(map[string]*AppliedMatch, len(s.matches))
+	for roomID, match := range s.matches {
+		matches[roomID] = match
+	}
+	return matches
+}
+
+// restoreMatches replaces the remembered results, used to roll back failed writes
+func (s *memoryUserStore) restoreMatches(matches map[string]*AppliedMatch) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.matches = matches
+}
+
+// remove deletes a user, used to roll back failed writes
+func (s *memoryUserStore) remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if user, exists := s.users[id]; exists {
+		delete(s.usersByName, user.Username)
+		delete(s.users, id)
+	}
+}
+
+func (s *memoryUserStore) Close() error {
+	return nil
+}
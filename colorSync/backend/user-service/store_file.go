@@ -0,0 +1,379 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Flokots/programming-5/colorSync/shared/fileutil"
+)
+
+// userRecord is the on-disk representation of a User
+// User hides the password hash from JSON, so the file store needs its own shape
+type userRecord struct {
+	ID           string      `json:"id"`
+	Username     string      `json:"username"`
+	PasswordHash string      `json:"password_hash"`
+	Status       string      `json:"status"`
+	Rating       int         `json:"rating"`
+	RatedGames   int         `json:"rated_games"`
+	Stats        PlayerStats `json:"stats"`
+	CreatedAt    time.Time   `json:"created_at"`
+}
+
+func recordFromUser(user *User) userRecord {
+	return userRecord{
+		ID:           user.ID,
+		Username:     user.Username,
+		PasswordHash: user.Password,
+		Status:       user.Status,
+		Rating:       user.Rating,
+		RatedGames:   user.RatedGames,
+		Stats:        user.Stats,
+		CreatedAt:    user.CreatedAt,
+	}
+}
+
+func (rec userRecord) toUser() *User {
+	return &User{
+		ID:         rec.ID,
+		Username:   rec.Username,
+		Password:   rec.PasswordHash,
+		Status:     rec.Status,
+		Rating:     rec.Rating,
+		RatedGames: rec.RatedGames,
+		Stats:      rec.Stats,
+		CreatedAt:  rec.CreatedAt,
+	}
+}
+
+// fileDocument is the whole store file
+// Users are kept as raw objects while migrating so old layouts can be rewritten
+type fileDocument struct {
+	SchemaVersion int                      `json:"schema_version"`
+	Users         []map[string]interface{} `json:"users"`
+	Matches       []AppliedMatch           `json:"matches"`
+}
+
+// migration upgrades a document from Version-1 to Version
+type migration struct {
+	Version     int
+	Description string
+	Apply       func(doc *fileDocument) error
+}
+
+// migrations are applied in order on startup, never edit a released one
+// To change the schema, append a new migration and bump nothing else
+var migrations = []migration{
+	{
+		Version:     1,
+		Description: "initial users collection",
+		Apply: func(doc *fileDocument) error {
+			if doc.Users == nil {
+				doc.Users = []map[string]interface{}{}
+			}
+			return nil
+		},
+	},
+	{
+		Version:     2,
+		Description: "add account status (existing users become active)",
+		Apply: func(doc *fileDocument) error {
+			for _, user := range doc.Users {
+				if _, ok := user["status"]; !ok {
+					user["status"] = UserStatusActive
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version:     3,
+		Description: "add Elo rating (existing users start unrated)",
+		Apply: func(doc *fileDocument) error {
+			for _, user := range doc.Users {
+				if _, ok := user["rating"]; !ok {
+					user["rating"] = InitialRating
+				}
+				if _, ok := user["rated_games"]; !ok {
+					user["rated_games"] = 0
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version:     4,
+		Description: "add player statistics (existing users start with none)",
+		Apply: func(doc *fileDocument) error {
+			for _, user := range doc.Users {
+				if _, ok := user["stats"]; !ok {
+					user["stats"] = map[string]interface{}{}
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version:     5,
+		Description: "add daily totals to player statistics, for periodic leaderboards",
+		Apply: func(doc *fileDocument) error {
+			for _, user := range doc.Users {
+				stats, ok := user["stats"].(map[string]interface{})
+				if !ok {
+					return fmt.Errorf("user %v has no stats", user["id"])
+				}
+				if _, ok := stats["days"]; !ok {
+					stats["days"] = []interface{}{}
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version:     6,
+		Description: "remember applied match results, so retried reports aren't rated twice after a restart",
+		Apply: func(doc *fileDocument) error {
+			if doc.Matches == nil {
+				doc.Matches = []AppliedMatch{}
+			}
+			return nil
+		},
+	},
+}
+
+// currentSchemaVersion is the version written by this build
+func currentSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// migrate brings doc up to currentSchemaVersion
+func migrate(doc *fileDocument) error {
+	if doc.SchemaVersion > currentSchemaVersion() {
+		return fmt.Errorf("store schema version %d is newer than supported version %d",
+			doc.SchemaVersion, currentSchemaVersion())
+	}
+
+	for _, m := range migrations {
+		if m.Version <= doc.SchemaVersion {
+			continue
+		}
+		if err := m.Apply(doc); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Description, err)
+		}
+		doc.SchemaVersion = m.Version
+		log.Printf("Applied user store migration %d: %s", m.Version, m.Description)
+	}
+	return nil
+}
+
+// fileUserStore keeps users in memory and writes every change to a JSON file
+// Writes go to a temp file first and are renamed into place, so a crash never leaves a half-written store
+type fileUserStore struct {
+	path  string
+	cache *memoryUserStore
+	mu    sync.Mutex // Serializes writes to the file
+}
+
+// openFileUserStore loads (and migrates) the store at path, creating it if missing
+func openFileUserStore(path string) (*fileUserStore, error) {
+	s := &fileUserStore{
+		path:  path,
+		cache: newMemoryUserStore(),
+	}
+
+	doc := &fileDocument{}
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		log.Printf("User store %s not found, creating a new one", path)
+	case err != nil:
+		return nil, fmt.Errorf("failed to read user store: %w", err)
+	default:
+		if err := json.Unmarshal(data, doc); err != nil {
+			return nil, fmt.Errorf("failed to parse user store: %w", err)
+		}
+	}
+
+	startVersion := doc.SchemaVersion
+	if err := migrate(doc); err != nil {
+		return nil, err
+	}
+
+	// Decode the migrated raw users into records
+	raw, err := json.Marshal(doc.Users)
+	if err != nil {
+		return nil, fmt.Errorf("failed to re-encode users: %w", err)
+	}
+	var records []userRecord
+	if err := json.Unmarshal(raw, &records); err != nil {
+		return nil, fmt.Errorf("failed to decode users: %w", err)
+	}
+
+	for _, rec := range records {
+		if err := s.cache.Create(rec.toUser()); err != nil {
+			return nil, fmt.Errorf("duplicate user %q in store: %w", rec.Username, err)
+		}
+	}
+	for i := range doc.Matches {
+		s.cache.matches[doc.Matches[i].Result.RoomID] = &doc.Matches[i]
+	}
+
+	// Persist straight away if the file is new or was migrated
+	if startVersion != doc.SchemaVersion {
+		if err := s.save(); err != nil {
+			return nil, err
+		}
+	}
+
+	log.Printf("User store loaded from %s (%d users, schema v%d)", path, len(records), doc.SchemaVersion)
+	return s, nil
+}
+
+func (s *fileUserStore) Create(user *User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.cache.Create(user); err != nil {
+		return err
+	}
+	if err := s.save(); err != nil {
+		// Keep memory and disk in agreement
+		s.cache.remove(user.ID)
+		return err
+	}
+	return nil
+}
+
+func (s *fileUserStore) Update(user *User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, err := s.cache.GetByID(user.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.cache.Update(user); err != nil {
+		return err
+	}
+	if err := s.save(); err != nil {
+		// Keep memory and disk in agreement
+		s.cache.Update(previous)
+		return err
+	}
+	return nil
+}
+
+func (s *fileUserStore) SetStatus(id, status string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, err := s.cache.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if err := s.cache.SetStatus(id, status); err != nil {
+		return err
+	}
+	if err := s.save(); err != nil {
+		// Keep memory and disk in agreement
+		s.cache.SetStatus(id, previous.Status)
+		return err
+	}
+	return nil
+}
+
+func (s *fileUserStore) RecordMatch(match *AppliedMatch, players []*User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previousUsers := make([]*User, 0, len(players))
+	for _, user := range players {
+		previous, err := s.cache.GetByID(user.ID)
+		if err != nil {
+			return err
+		}
+		previousUsers = append(previousUsers, previous)
+	}
+	previousMatches := s.cache.snapshotMatches()
+
+	if err := s.cache.RecordMatch(match, players); err != nil {
+		return err
+	}
+	if err := s.save(); err != nil {
+		// Keep memory and disk in agreement
+		for _, previous := range previousUsers {
+			s.cache.Update(previous)
+		}
+		s.cache.restoreMatches(previousMatches)
+		return err
+	}
+	return nil
+}
+
+func (s *fileUserStore) GetMatch(roomID string) (*AppliedMatch, error) {
+	return s.cache.GetMatch(roomID)
+}
+
+func (s *fileUserStore) GetByID(id string) (*User, error) {
+	return s.cache.GetByID(id)
+}
+
+func (s *fileUserStore) GetByUsername(username string) (*User, error) {
+	return s.cache.GetByUsername(username)
+}
+
+func (s *fileUserStore) Count() (int, error) {
+	return s.cache.Count()
+}
+
+func (s *fileUserStore) List() ([]*User, error) {
+	return s.cache.List()
+}
+
+func (s *fileUserStore) Close() error {
+	return nil
+}
+
+// save writes the whole store to disk atomically
+// Caller must hold s.mu
+func (s *fileUserStore) save() error {
+	s.cache.mu.RLock()
+	records := make([]userRecord, 0, len(s.cache.users))
+	for _, user := range s.cache.users {
+		records = append(records, recordFromUser(user))
+	}
+	matches := make([]AppliedMatch, 0, len(s.cache.matches))
+	for _, match := range s.cache.matches {
+		matches = append(matches, *match)
+	}
+	s.cache.mu.RUnlock()
+
+	// Stable order keeps the file diffable
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].CreatedAt.Before(records[j].CreatedAt)
+	})
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].AppliedAt.Before(matches[j].AppliedAt)
+	})
+
+	data, err := json.MarshalIndent(struct {
+		SchemaVersion int            `json:"schema_version"`
+		Users         []userRecord   `json:"users"`
+		Matches       []AppliedMatch `json:"matches"`
+	}{
+		SchemaVersion: currentSchemaVersion(),
+		Users:         records,
+		Matches:       matches,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode user store: %w", err)
+	}
+
+	return fileutil.WriteAtomic(s.path, data)
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func openTestStore(t *testing.T, path string) *fileUserStore {
+	t.Helper()
+	s, err := openFileUserStore(path)
+	if err != nil {
+		t.Fatalf("openFileUserStore: %v", err)
+	}
+	return s
+}
+
+func TestFileUserStoreReopens(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+	s := openTestStore(t, path)
+
+	alice := &User{ID: "user-1", Username: "alice", Password: "hash", Status: UserStatusActive, Rating: InitialRating, CreatedAt: time.Now()}
+	if err := s.Create(alice); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Create(&User{ID: "user-2", Username: "alice", Status: UserStatusActive}); err != ErrUsernameTaken {
+		t.Fatalf("Create with a taken username = %v, want ErrUsernameTaken", err)
+	}
+
+	updated := *alice
+	updated.Rating = 1532
+	updated.RatedGames = 1
+	updated.Stats.GamesPlayed = 1
+	if err := s.Update(&updated); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetStatus(alice.ID, UserStatusBanned); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := openTestStore(t, path)
+	got, err := reopened.GetByUsername("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Password != "hash" || got.Rating != 1532 || got.RatedGames != 1 || got.Stats.GamesPlayed != 1 || got.Status != UserStatusBanned {
+		t.Fatalf("reopened alice = %+v, want the updated, banned user with her password hash", got)
+	}
+	if count, _ := reopened.Count(); count != 1 {
+		t.Fatalf("Count = %d, want 1", count)
+	}
+}
+
+func TestFileUserStoreMigratesVersion1(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+	v1 := `{"schema_version": 1, "users": [
+		{"id": "user-1", "username": "alice", "password_hash": "hash", "created_at": "2025-01-01T00:00:00Z"}
+	]}`
+	if err := os.WriteFile(path, []byte(v1), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := openTestStore(t, path)
+	alice, err := s.GetByID("user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alice.Status != UserStatusActive || alice.Rating != InitialRating || alice.RatedGames != 0 || alice.Password != "hash" {
+		t.Fatalf("migrated alice = %+v, want an active, unrated user", alice)
+	}
+
+	// The file is rewritten at the current version, with every field the migrations add
+	var doc struct {
+		SchemaVersion int                      `json:"schema_version"`
+		Users         []map[string]interface{} `json:"users"`
+		Matches       []interface{}            `json:"matches"`
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.SchemaVersion != currentSchemaVersion() {
+		t.Fatalf("schema version = %d, want %d", doc.SchemaVersion, currentSchemaVersion())
+	}
+	if doc.Matches == nil {
+		t.Fatal("migrated store has no matches")
+	}
+	user := doc.Users[0]
+	for _, field := range []string{"status", "rating", "rated_games", "stats"} {
+		if _, ok := user[field]; !ok {
+			t.Errorf("migrated user has no %s", field)
+		}
+	}
+	if stats, _ := user["stats"].(map[string]interface{}); stats["days"] == nil {
+		t.Errorf("migrated stats = %v, want days", user["stats"])
+	}
+}
+
+func TestMigrateEachVersion(t *testing.T) {
+	tests := []struct {
+		name  string
+		from  int
+		user  map[string]interface{}
+		check func(user map[string]interface{}) bool
+	}{
+		{"migration 2 adds status", 1, map[string]interface{}{}, func(user map[string]interface{}) bool {
+			return user["status"] == UserStatusActive
+		}},
+		{"migration 2 keeps an existing status", 1, map[string]interface{}{"status": UserStatusBanned}, func(user map[string]interface{}) bool {
+			return user["status"] == UserStatusBanned
+		}},
+		{"migration 3 adds rating", 2, map[string]interface{}{}, func(user map[string]interface{}) bool {
+			return user["rating"] == InitialRating && user["rated_games"] == 0
+		}},
+		{"migration 4 adds stats", 3, map[string]interface{}{}, func(user map[string]interface{}) bool {
+			_, ok := user["stats"].(map[string]interface{})
+			return ok
+		}},
+		{"migration 5 adds days to stats", 4, map[string]interface{}{"stats": map[string]interface{}{"games_played": 3.0}}, func(user map[string]interface{}) bool {
+			stats := user["stats"].(map[string]interface{})
+			return stats["days"] != nil && stats["games_played"] == 3.0
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := &fileDocument{SchemaVersion: tt.from, Users: []map[string]interface{}{tt.user}}
+			if err := migrate(doc); err != nil {
+				t.Fatalf("migrate: %v", err)
+			}
+			if doc.SchemaVersion != currentSchemaVersion() || !tt.check(doc.Users[0]) {
+				t.Fatalf("migrated to v%d: %v", doc.SchemaVersion, doc.Users[0])
+			}
+		})
+	}
+
+	// Stats that aren't an object can't be given daily totals
+	doc := &fileDocument{SchemaVersion: 4, Users: []map[string]interface{}{{"id": "user-1", "stats": "none"}}}
+	if err := migrate(doc); err == nil || doc.SchemaVersion != 4 {
+		t.Fatalf("migrate of broken stats = %v at v%d, want migration 5 to fail", err, doc.SchemaVersion)
+	}
+}
+
+func TestFileUserStoreRefusesNewerSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+	newer := `{"schema_version": 999, "users": []}`
+	if err := os.WriteFile(path, []byte(newer), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := openFileUserStore(path); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Fatalf("openFileUserStore = %v, want the newer schema refused", err)
+	}
+	// and the file is left as it was
+	if data, _ := os.ReadFile(path); string(data) != newer {
+		t.Fatalf("store rewritten to %s", data)
+	}
+}
+
+func TestFileUserStoreRollsBackFailedSave(t *testing.T) {
+	dir := t.TempDir()
+	s := openTestStore(t, filepath.Join(dir, "users.json"))
+	alice := &User{ID: "user-1", Username: "alice", Status: UserStatusActive, Rating: InitialRating, CreatedAt: time.Now()}
+	if err := s.Create(alice); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every save fails from now on
+	s.path = filepath.Join(dir, "missing", "users.json")
+
+	if err := s.Create(&User{ID: "user-2", Username: "bob", Status: UserStatusActive}); err == nil {
+		t.Fatal("Create succeeded, want the failed save reported")
+	}
+	if _, err := s.GetByUsername("bob"); err != ErrUserNotFound {
+		t.Fatalf("GetByUsername(bob) = %v, want the user rolled back", err)
+	}
+
+	updated := *alice
+	updated.Rating = 2000
+	if err := s.Update(&updated); err == nil {
+		t.Fatal("Update succeeded, want the failed save reported")
+	}
+	if err := s.SetStatus(alice.ID, UserStatusBanned); err == nil {
+		t.Fatal("SetStatus succeeded, want the failed save reported")
+	}
+	got, err := s.GetByID(alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Rating != InitialRating || got.Status != UserStatusActive {
+		t.Fatalf("alice = %+v, want the changes rolled back", got)
+	}
+}
@@ -0,0 +1,461 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Flokots/programming-5/colorSync/shared/auth"
+	"github.com/Flokots/programming-5/colorSync/shared/fileutil"
+	"github.com/Flokots/programming-5/colorSync/shared/middleware"
+)
+
+// refreshTokenTTL is how long a session survives without being refreshed
+const refreshTokenTTL = 30 * 24 * time.Hour
+
+var (
+	errInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	errRefreshTokenReused  = errors.New("refresh token reused")
+)
+
+// refreshToken is one link in a rotation chain
+// Only the SHA-256 of the opaque token is kept, so a leaked store can't be replayed
+type refreshToken struct {
+	UserID    string    `json:"user_id"`
+	FamilyID  string    `json:"family_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Used      bool      `json:"used"` // Set once exchanged; presenting it again means it was stolen
+}
+
+// tokenFamily groups every token descended from one login
+type tokenFamily struct {
+	UserID       string               `json:"user_id"`
+	Revoked      bool                 `json:"revoked"`
+	AccessTokens map[string]time.Time `json:"access_tokens"` // jti -> expiry, revoked together with the family
+}
+
+// sessionStore tracks refresh tokens, their families and revoked access tokens
+// With a path every change is written to disk, so a restart neither ends
+// sessions nor brings revoked tokens back
+type sessionStore struct {
+	mu       sync.Mutex
+	path     string                   // "" keeps everything in memory
+	tokens   map[string]*refreshToken // sha256(token) -> token
+	families map[string]*tokenFamily  // familyID -> family
+}
+
+// sessionFile is the on-disk shape of a sessionStore
+type sessionFile struct {
+	Tokens   map[string]*refreshToken `json:"tokens"`
+	Families map[string]*tokenFamily  `json:"families"`
+	Revoked  []auth.RevokedToken      `json:"revoked"`
+}
+
+// Session storage, selected at startup (see newSessionStore)
+var sessions *sessionStore
+
+// newSessionStore follows USER_STORE, so sessions live as long as the accounts they belong to
+// USER_STORE=file persists them to SESSION_STORE_PATH (default sessions.json)
+func newSessionStore() (*sessionStore, error) {
+	if getEnv("USER_STORE", "memory") != "file" {
+		return newMemorySessionStore(), nil
+	}
+	return openSessionStore(getEnv("SESSION_STORE_PATH", "sessions.json"))
+}
+
+func newMemorySessionStore() *sessionStore {
+	return &sessionStore{
+		tokens:   make(map[string]*refreshToken),
+		families: make(map[string]*tokenFamily),
+	}
+}
+
+// openSessionStore loads the sessions at path, creating the file if missing
+// Stored revocations are handed back to auth, so RequireAuth rejects those tokens again
+func openSessionStore(path string) (*sessionStore, error) {
+	s := newMemorySessionStore()
+	s.path = path
+
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		log.Printf("Session store %s not found, creating a new one", path)
+	case err != nil:
+		return nil, fmt.Errorf("failed to read session store: %w", err)
+	default:
+		var file sessionFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("failed to parse session store: %w", err)
+		}
+		for hash, token := range file.Tokens {
+			s.tokens[hash] = token
+		}
+		for familyID, family := range file.Families {
+			if family.AccessTokens == nil {
+				family.AccessTokens = make(map[string]time.Time)
+			}
+			s.families[familyID] = family
+		}
+		for _, revoked := range file.Revoked {
+			auth.RevokeToken(revoked.ID, revoked.ExpiresAt)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked()
+	if err := s.saveLocked(); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Session store loaded from %s (%d sessions, %d revoked tokens)",
+		path, len(s.families), len(auth.RevokedTokens()))
+	return s, nil
+}
+
+// TokenPair is what clients get back from login, register and refresh
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int // Access token lifetime in seconds
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// startSession issues the first token pair of a new family
+func (s *sessionStore) startSession(user *User) (*TokenPair, error) {
+	familyID, err := auth.NewTokenID()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pruneLocked()
+	s.families[familyID] = &tokenFamily{
+		UserID:       user.ID,
+		AccessTokens: make(map[string]time.Time),
+	}
+	pair, _, err := s.issueLocked(user, familyID)
+	if err == nil {
+		err = s.saveLocked()
+	}
+	if err != nil {
+		// Nobody has seen the pair yet, so the family can just go
+		s.forgetFamilyLocked(familyID)
+		return nil, err
+	}
+	return pair, nil
+}
+
+// rotate exchanges a refresh token for a new pair in the same family
+// Reusing an already exchanged token revokes the whole family
+func (s *sessionStore) rotate(presented string) (*TokenPair, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, exists := s.tokens[hashToken(presented)]
+	if !exists || time.Now().After(current.ExpiresAt) {
+		return nil, errInvalidRefreshToken
+	}
+
+	family := s.families[current.FamilyID]
+	if family == nil || family.Revoked {
+		return nil, errInvalidRefreshToken
+	}
+
+	if current.Used {
+		log.Printf("Refresh token reuse detected for user %s - revoking session family", current.UserID)
+		s.revokeFamilyLocked(current.FamilyID)
+		if err := s.saveLocked(); err != nil {
+			log.Printf("Failed to persist revoked session: %v", err)
+		}
+		return nil, errRefreshTokenReused
+	}
+
+	user, err := store.GetByID(current.UserID)
+	if err != nil || user.Status != UserStatusActive {
+		return nil, errInvalidRefreshToken
+	}
+
+	pair, undo, err := s.issueLocked(user, current.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+	current.Used = true
+	if err := s.saveLocked(); err != nil {
+		// The presented token stays valid, so the client can simply retry
+		undo()
+		current.Used = false
+		return nil, err
+	}
+	return pair, nil
+}
+
+// revokeSession ends the family the refresh token belongs to
+// The token must belong to userID so one user can't log another out
+func (s *sessionStore) revokeSession(presented, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, exists := s.tokens[hashToken(presented)]
+	if !exists || current.UserID != userID {
+		return errInvalidRefreshToken
+	}
+	s.revokeFamilyLocked(current.FamilyID)
+	return s.saveLocked()
+}
+
+// revokeUser ends every session of userID, for when the account is banned or deleted
+func (s *sessionStore) revokeUser(userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for familyID, family := range s.families {
+		if family.UserID == userID && !family.Revoked {
+			s.revokeFamilyLocked(familyID)
+		}
+	}
+	return s.saveLocked()
+}
+
+// revokeAccessToken rejects a single access token from now on
+func (s *sessionStore) revokeAccessToken(tokenID string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	auth.RevokeToken(tokenID, expiresAt)
+	return s.saveLocked()
+}
+
+// issueLocked signs an access token and a new refresh token for familyID
+// undo takes both back out of the store, for when they can't be persisted
+// Caller must hold s.mu
+func (s *sessionStore) issueLocked(user *User, familyID string) (pair *TokenPair, undo func(), err error) {
+	access, err := auth.IssueUserToken(user.ID, user.Username)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	refresh, err := auth.NewTokenID()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	refreshHash := hashToken(refresh)
+	family := s.families[familyID]
+	s.tokens[refreshHash] = &refreshToken{
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		ExpiresAt: time.Now().Add(refreshTokenTTL),
+	}
+	family.AccessTokens[access.ID] = access.ExpiresAt
+
+	undo = func() {
+		delete(s.tokens, refreshHash)
+		delete(family.AccessTokens, access.ID)
+	}
+	return &TokenPair{
+		AccessToken:  access.Token,
+		RefreshToken: refresh,
+		ExpiresIn:    int(auth.AccessTokenTTL.Seconds()),
+	}, undo, nil
+}
+
+// revokeFamilyLocked kills every refresh and access token in the family
+// Caller must hold s.mu
+func (s *sessionStore) revokeFamilyLocked(familyID string) {
+	family, exists := s.families[familyID]
+	if !exists {
+		return
+	}
+
+	family.Revoked = true
+	for jti, expiresAt := range family.AccessTokens {
+		auth.RevokeToken(jti, expiresAt)
+	}
+	for hash, token := range s.tokens {
+		if token.FamilyID == familyID {
+			delete(s.tokens, hash)
+		}
+	}
+}
+
+// forgetFamilyLocked drops a family and its refresh tokens without revoking anything
+// Caller must hold s.mu
+func (s *sessionStore) forgetFamilyLocked(familyID string) {
+	delete(s.families, familyID)
+	for hash, token := range s.tokens {
+		if token.FamilyID == familyID {
+			delete(s.tokens, hash)
+		}
+	}
+}
+
+// saveLocked writes the sessions and the revocation list to disk, if the store has a path
+// Caller must hold s.mu
+func (s *sessionStore) saveLocked() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(sessionFile{
+		Tokens:   s.tokens,
+		Families: s.families,
+		Revoked:  auth.RevokedTokens(),
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode session store: %w", err)
+	}
+	return fileutil.WriteAtomic(s.path, data)
+}
+
+// pruneLocked forgets expired refresh tokens and families with nothing left in them
+// Caller must hold s.mu
+func (s *sessionStore) pruneLocked() {
+	now := time.Now()
+	live := make(map[string]bool)
+	for hash, token := range s.tokens {
+		if now.After(token.ExpiresAt) {
+			delete(s.tokens, hash)
+			continue
+		}
+		live[token.FamilyID] = true
+	}
+
+	for familyID, family := range s.families {
+		for jti, expiresAt := range family.AccessTokens {
+			if now.After(expiresAt) {
+				delete(family.AccessTokens, jti)
+			}
+		}
+		if !live[familyID] && len(family.AccessTokens) == 0 {
+			delete(s.families, familyID)
+		}
+	}
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// refreshTokenHandler exchanges a refresh token for a new access/refresh pair
+func refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	// 1. Only accept POST requests
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 2. Parse JSON from request body
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		http.Error(w, "refresh_token required", http.StatusBadRequest)
+		return
+	}
+
+	// 3. Rotate (old token becomes single-use history)
+	pair, err := sessions.rotate(req.RefreshToken)
+	if err != nil {
+		if !errors.Is(err, errInvalidRefreshToken) && !errors.Is(err, errRefreshTokenReused) {
+			log.Printf("Failed to refresh session: %v", err)
+		}
+		http.Error(w, "Invalid or expired refresh token", http.StatusUnauthorized)
+		return
+	}
+
+	// 4. Return the new pair
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(TokenResponse{
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresIn:    pair.ExpiresIn,
+	})
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// logoutHandler revokes the caller's access token and, if given, its refresh token family
+// Wrapped in middleware.RequireAuth
+func logoutHandler(w http.ResponseWriter, r *http.Request) {
+	// 1. Only accept POST requests
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 2. Get user claims from JWT token (validated by middleware)
+	claims := middleware.GetUserClaims(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized - no user claims", http.StatusUnauthorized)
+		return
+	}
+
+	// 3. Body is optional, a bare logout only kills the access token
+	var req LogoutRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// 4. Revoke the refresh token family
+	if req.RefreshToken != "" {
+		err := sessions.revokeSession(req.RefreshToken, claims.UserID)
+		if errors.Is(err, errInvalidRefreshToken) {
+			http.Error(w, "Invalid refresh token", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to persist logout: %v", err)
+			http.Error(w, "Failed to log out", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// 5. Revoke the access token used for this request
+	if err := sessions.revokeAccessToken(claims.ID, claims.ExpiresAt.Time); err != nil {
+		log.Printf("Failed to persist logout: %v", err)
+		http.Error(w, "Failed to log out", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("User logged out: %s (ID: %s)", claims.Username, claims.UserID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Logged out"})
+}
+
+// revocationsHandler lists revoked access tokens for other services to mirror
+// Wrapped in middleware.RequireServicePolicy
+func revocationsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(auth.RevokedTokens())
+}
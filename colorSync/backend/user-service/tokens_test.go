@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Flokots/programming-5/colorSync/shared/auth"
+)
+
+// useTestAuth gives the test a signing key, an in-memory user store and alice
+func useTestAuth(t *testing.T) *User {
+	t.Helper()
+	t.Setenv("USER_JWT_PRIVATE_KEY_FILE", filepath.Join(t.TempDir(), "user.pem"))
+	if err := auth.UseUserSigningKeys(); err != nil {
+		t.Fatalf("UseUserSigningKeys: %v", err)
+	}
+
+	store = newMemoryUserStore()
+	alice := &User{ID: "user-1", Username: "alice", Status: UserStatusActive, CreatedAt: time.Now()}
+	if err := store.Create(alice); err != nil {
+		t.Fatal(err)
+	}
+	return alice
+}
+
+// accessTokenID is the jti of a pair's access token
+func accessTokenID(t *testing.T, pair *TokenPair) string {
+	t.Helper()
+	claims, err := auth.VerifyUserToken(pair.AccessToken)
+	if err != nil {
+		t.Fatalf("VerifyUserToken: %v", err)
+	}
+	return claims.ID
+}
+
+func TestSessionRotation(t *testing.T) {
+	alice := useTestAuth(t)
+
+	tests := []struct {
+		name string
+		// run plays the scenario and returns the pairs it saw and the last error
+		run           func(s *sessionStore, first *TokenPair) ([]*TokenPair, error)
+		wantErr       error
+		wantRevoked   bool // Every access token seen must be revoked
+		wantLiveToken bool // The newest refresh token still rotates
+	}{
+		{
+			name: "rotation hands out a new pair",
+			run: func(s *sessionStore, first *TokenPair) ([]*TokenPair, error) {
+				next, err := s.rotate(first.RefreshToken)
+				return []*TokenPair{first, next}, err
+			},
+			wantLiveToken: true,
+		},
+		{
+			name: "reusing an exchanged token revokes the family",
+			run: func(s *sessionStore, first *TokenPair) ([]*TokenPair, error) {
+				next, _ := s.rotate(first.RefreshToken)
+				_, err := s.rotate(first.RefreshToken)
+				return []*TokenPair{first, next}, err
+			},
+			wantErr:     errRefreshTokenReused,
+			wantRevoked: true,
+		},
+		{
+			name: "logout revokes the family",
+			run: func(s *sessionStore, first *TokenPair) ([]*TokenPair, error) {
+				next, _ := s.rotate(first.RefreshToken)
+				err := s.revokeSession(next.RefreshToken, alice.ID)
+				return []*TokenPair{first, next}, err
+			},
+			wantRevoked: true,
+		},
+		{
+			name: "another user can't end the session",
+			run: func(s *sessionStore, first *TokenPair) ([]*TokenPair, error) {
+				return []*TokenPair{first}, s.revokeSession(first.RefreshToken, "user-2")
+			},
+			wantErr:       errInvalidRefreshToken,
+			wantLiveToken: true,
+		},
+		{
+			name: "unknown token",
+			run: func(s *sessionStore, first *TokenPair) ([]*TokenPair, error) {
+				_, err := s.rotate("not-a-token")
+				return []*TokenPair{first}, err
+			},
+			wantErr:       errInvalidRefreshToken,
+			wantLiveToken: true,
+		},
+		{
+			name: "expired token",
+			run: func(s *sessionStore, first *TokenPair) ([]*TokenPair, error) {
+				s.tokens[hashToken(first.RefreshToken)].ExpiresAt = time.Now().Add(-time.Minute)
+				_, err := s.rotate(first.RefreshToken)
+				return []*TokenPair{first}, err
+			},
+			wantErr: errInvalidRefreshToken,
+		},
+		{
+			name: "banned user can't refresh",
+			run: func(s *sessionStore, first *TokenPair) ([]*TokenPair, error) {
+				store.SetStatus(alice.ID, UserStatusBanned)
+				defer store.SetStatus(alice.ID, UserStatusActive)
+				_, err := s.rotate(first.RefreshToken)
+				return []*TokenPair{first}, err
+			},
+			wantErr:       errInvalidRefreshToken,
+			wantLiveToken: true, // Once unbanned
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newMemorySessionStore()
+			first, err := s.startSession(alice)
+			if err != nil {
+				t.Fatalf("startSession: %v", err)
+			}
+
+			pairs, err := tt.run(s, first)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			for _, pair := range pairs {
+				if revoked := auth.IsTokenRevoked(accessTokenID(t, pair)); revoked != tt.wantRevoked {
+					t.Fatalf("access token revoked = %t, want %t", revoked, tt.wantRevoked)
+				}
+			}
+			newest := pairs[len(pairs)-1]
+			if _, err := s.rotate(newest.RefreshToken); (err == nil) != tt.wantLiveToken {
+				t.Fatalf("rotating the newest refresh token: %v, want live=%t", err, tt.wantLiveToken)
+			}
+		})
+	}
+}
+
+func TestSessionsSurviveRestart(t *testing.T) {
+	alice := useTestAuth(t)
+	path := filepath.Join(t.TempDir(), "sessions.json")
+
+	s, err := openSessionStore(path)
+	if err != nil {
+		t.Fatalf("openSessionStore: %v", err)
+	}
+	kept, _ := s.startSession(alice)
+	exchanged := kept
+	kept, _ = s.rotate(kept.RefreshToken)
+	ended, _ := s.startSession(alice)
+	if err := s.revokeSession(ended.RefreshToken, alice.ID); err != nil {
+		t.Fatalf("revokeSession: %v", err)
+	}
+	loggedOut, _ := s.startSession(alice)
+	if err := s.revokeAccessToken(accessTokenID(t, loggedOut), time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("revokeAccessToken: %v", err)
+	}
+
+	// The file alone must bring the revocations back
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file sessionFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+	stored := make(map[string]bool)
+	for _, revoked := range file.Revoked {
+		stored[revoked.ID] = true
+	}
+	for name, pair := range map[string]*TokenPair{"ended session": ended, "logged out": loggedOut} {
+		if !stored[accessTokenID(t, pair)] {
+			t.Fatalf("%s: access token not in the stored revocation list", name)
+		}
+	}
+	if stored[accessTokenID(t, kept)] {
+		t.Fatal("live session's access token stored as revoked")
+	}
+
+	restarted, err := openSessionStore(path)
+	if err != nil {
+		t.Fatalf("reopening: %v", err)
+	}
+	if _, err := restarted.rotate(ended.RefreshToken); !errors.Is(err, errInvalidRefreshToken) {
+		t.Fatalf("ended session refreshed after restart: %v", err)
+	}
+	if _, err := restarted.rotate(kept.RefreshToken); err != nil {
+		t.Fatalf("live session not refreshed after restart: %v", err)
+	}
+	if _, err := restarted.rotate(exchanged.RefreshToken); !errors.Is(err, errRefreshTokenReused) {
+		t.Fatalf("exchanged token reuse after restart: %v, want it detected", err)
+	}
+}
@@ -1,11 +1,16 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -14,7 +19,9 @@ type APIClient struct {
 	userServiceURL string
 	roomServiceURL string
 	httpClient     *http.Client
+	streamClient   *http.Client // No overall timeout, for long-lived event streams
 	token          string
+	refreshToken   string
 }
 
 // newAPIClient creates a new APIClient
@@ -23,6 +30,7 @@ func newAPIClient() *APIClient {
 		userServiceURL: "http://localhost:8001",
 		roomServiceURL: "http://localhost:8002",
 		httpClient:     &http.Client{Timeout: 10 * time.Second},
+		streamClient:   &http.Client{},
 		token:          "",
 	}
 }
@@ -34,10 +42,11 @@ type loginRequest struct {
 }
 
 type loginResponse struct {
-	ID       string `json:"id"`
-	Username string `json:"username"`
-	Token    string `json:"token"`
-	Message  string `json:"message"`
+	ID           string `json:"id"`
+	Username     string `json:"username"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	Message      string `json:"message"`
 }
 
 func (a *APIClient) login(username, password string) (string, error) {
@@ -74,8 +83,9 @@ func (a *APIClient) login(username, password string) (string, error) {
 		return "", fmt.Errorf("failed to parse response: %w", err)
 	}
 
-	// Store token
+	// Store tokens
 	a.token = result.Token
+	a.refreshToken = result.RefreshToken
 
 	return result.ID, nil
 }
@@ -87,10 +97,11 @@ type registerRequest struct {
 }
 
 type registerResponse struct {
-	ID       string `json:"id"`
-	Username string `json:"username"`
-	Token    string `json:"token"`
-	Message  string `json:"message"`
+	ID           string `json:"id"`
+	Username     string `json:"username"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	Message      string `json:"message"`
 }
 
 func (a *APIClient) register(username, password string) (string, error) {
@@ -123,10 +134,216 @@ func (a *APIClient) register(username, password string) (string, error) {
 	}
 
 	a.token = result.Token
+	a.refreshToken = result.RefreshToken
 
 	return result.ID, nil
 }
 
+// REFRESH / LOGOUT
+type tokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// refresh exchanges the refresh token for a new access token (and a new refresh token)
+func (a *APIClient) refresh() error {
+	if a.refreshToken == "" {
+		return fmt.Errorf("no refresh token - please log in again")
+	}
+
+	body, _ := json.Marshal(map[string]string{"refresh_token": a.refreshToken})
+	resp, err := a.httpClient.Post(
+		a.userServiceURL+"/token/refresh",
+		"application/json",
+		bytes.NewBuffer(body),
+	)
+	if err != nil {
+		return fmt.Errorf("connection failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("session expired - please log in again")
+	}
+
+	var result tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	a.token = result.Token
+	a.refreshToken = result.RefreshToken
+	return nil
+}
+
+// doAuthorized sends a request with the access token
+// On 401 it refreshes the token once and retries
+func (a *APIClient) doAuthorized(newRequest func() (*http.Request, error)) (*http.Response, error) {
+	return a.doAuthorizedWith(a.httpClient, newRequest)
+}
+
+// doAuthorizedWith is doAuthorized on a specific HTTP client
+func (a *APIClient) doAuthorizedWith(client *http.Client, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+a.token)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("connection failed: %w", err)
+		}
+
+		if resp.StatusCode != http.StatusUnauthorized || attempt > 0 {
+			return resp, nil
+		}
+		resp.Body.Close()
+
+		if err := a.refresh(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// logout revokes the session on the server so the tokens can't be reused
+func (a *APIClient) logout() error {
+	if a.token == "" {
+		return nil
+	}
+
+	body, _ := json.Marshal(map[string]string{"refresh_token": a.refreshToken})
+	resp, err := a.doAuthorized(func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", a.userServiceURL+"/logout", bytes.NewBuffer(body))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		return req, err
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("logout failed: %s", string(bodyBytes))
+	}
+
+	a.token = ""
+	a.refreshToken = ""
+	return nil
+}
+
+// STATS
+type playerStats struct {
+	Username        string  `json:"username"`
+	Rating          int     `json:"rating"`
+	GamesPlayed     int     `json:"games_played"`
+	GamesWon        int     `json:"games_won"`
+	GamesLost       int     `json:"games_lost"`
+	GamesDrawn      int     `json:"games_drawn"`
+	RoundsPlayed    int     `json:"rounds_played"`
+	RoundsWon       int     `json:"rounds_won"`
+	AvgReactionMs   int64   `json:"avg_reaction_ms"`
+	BestReactionMs  int64   `json:"best_reaction_ms"`
+	Accuracy        float64 `json:"accuracy"`
+	WrongAnswerRate float64 `json:"wrong_answer_rate"`
+	CurrentStreak   int     `json:"current_streak"` // Wins in a row if positive, losses if negative
+}
+
+// getStats fetches a player's statistics over every game they finished
+func (a *APIClient) getStats(userID string) (*playerStats, error) {
+	resp, err := a.httpClient.Get(a.userServiceURL + "/users/" + userID + "/stats")
+	if err != nil {
+		return nil, fmt.Errorf("connection failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("%s", strings.TrimSpace(string(bodyBytes)))
+	}
+
+	var stats playerStats
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return &stats, nil
+}
+
+// LEADERBOARD
+type leaderboardEntry struct {
+	Rank          int    `json:"rank"`
+	UserID        string `json:"user_id"`
+	Username      string `json:"username"`
+	Rating        int    `json:"rating"`
+	GamesPlayed   int    `json:"games_played"`
+	GamesWon      int    `json:"games_won"`
+	AvgReactionMs int64  `json:"avg_reaction_ms"`
+}
+
+type leaderboardResponse struct {
+	Period  string             `json:"period"`
+	Metric  string             `json:"metric"`
+	Entries []leaderboardEntry `json:"entries"`
+	Total   int                `json:"total"`
+}
+
+type myRankResponse struct {
+	Entry leaderboardEntry `json:"entry"`
+	Total int              `json:"total"`
+}
+
+// getLeaderboard fetches the top players for a period and metric
+func (a *APIClient) getLeaderboard(period, metric string, limit int) (*leaderboardResponse, error) {
+	query := url.Values{"period": {period}, "metric": {metric}, "limit": {strconv.Itoa(limit)}}
+	resp, err := a.httpClient.Get(a.userServiceURL + "/leaderboard?" + query.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("connection failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("%s", strings.TrimSpace(string(bodyBytes)))
+	}
+
+	var board leaderboardResponse
+	if err := json.NewDecoder(resp.Body).Decode(&board); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return &board, nil
+}
+
+// getMyRank fetches our own place on a leaderboard
+// Returns nil without an error when we are not ranked for the period
+func (a *APIClient) getMyRank(period, metric string) (*myRankResponse, error) {
+	query := url.Values{"period": {period}, "metric": {metric}}
+	resp, err := a.doAuthorized(func() (*http.Request, error) {
+		return http.NewRequest("GET", a.userServiceURL+"/leaderboard/me?"+query.Encode(), nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("%s", strings.TrimSpace(string(bodyBytes)))
+	}
+
+	var rank myRankResponse
+	if err := json.NewDecoder(resp.Body).Decode(&rank); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return &rank, nil
+}
+
 // JOIN ROOM
 type joinRoomRequest struct {
 	UserID string `json:"user_id"`
@@ -143,23 +360,20 @@ func (a *APIClient) joinRoom(userID string) (string, error) {
 	req := joinRoomRequest{UserID: userID}
 	body, _ := json.Marshal(req)
 
-	// Create request with Authorization header
-	httpReq, err := http.NewRequest(
-		"POST",
-		a.roomServiceURL+"/join",
-		bytes.NewBuffer(body),
-	)
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
-	}
-
-	// Set headers
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", "Bearer "+a.token) // add JWT token
-
-	resp, err := a.httpClient.Do(httpReq)
+	// Create request (doAuthorized adds the JWT token)
+	resp, err := a.doAuthorized(func() (*http.Request, error) {
+		httpReq, err := http.NewRequest(
+			"POST",
+			a.roomServiceURL+"/join",
+			bytes.NewBuffer(body),
+		)
+		if err == nil {
+			httpReq.Header.Set("Content-Type", "application/json")
+		}
+		return httpReq, err
+	})
 	if err != nil {
-		return "", fmt.Errorf("connection failed: %w", err)
+		return "", err
 	}
 	defer resp.Body.Close()
 
@@ -186,68 +400,178 @@ func (a *APIClient) joinRoom(userID string) (string, error) {
 	return result.RoomID, nil
 }
 
-// STEP 1: Check if ROOM is full (has 2 players)
-func (a *APIClient) checkRoomFull(roomID string) (bool, error) {
-	url := fmt.Sprintf("%s/room/%s/ready", a.roomServiceURL, roomID)
+// CREATE PRIVATE ROOM
+
+// gameRules are the rules a private room is played with
+// Unset fields are left out so the server defaults apply
+type gameRules struct {
+	Mode             string   `json:"mode,omitempty"`
+	Rounds           int      `json:"rounds,omitempty"`
+	RoundTimeoutMs   int      `json:"round_timeout_ms,omitempty"`
+	PauseMs          *int     `json:"pause_ms,omitempty"` // Pointer, 0 is a valid pause
+	Palette          []string `json:"palette,omitempty"`
+	WrongAnswer      string   `json:"wrong_answer,omitempty"`
+	Scoring          string   `json:"scoring,omitempty"`
+	ReconnectGraceMs *int     `json:"reconnect_grace_ms,omitempty"` // Pointer, 0 disables reconnecting
+}
+
+type createRoomRequest struct {
+	Config *gameRules `json:"config,omitempty"`
+}
+
+type createRoomResponse struct {
+	RoomID     string    `json:"room_id"`
+	InviteCode string    `json:"invite_code"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Status     string    `json:"status"`
+	Config     gameRules `json:"config"`
+}
+
+func (a *APIClient) createPrivateRoom(rules *gameRules) (*createRoomResponse, error) {
+	body, _ := json.Marshal(createRoomRequest{Config: rules})
 
-	resp, err := a.httpClient.Get(url)
+	resp, err := a.doAuthorized(func() (*http.Request, error) {
+		httpReq, err := http.NewRequest("POST", a.roomServiceURL+"/rooms", bytes.NewBuffer(body))
+		if err == nil {
+			httpReq.Header.Set("Content-Type", "application/json")
+		}
+		return httpReq, err
+	})
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return false, nil
+	if resp.StatusCode != http.StatusCreated {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("create room failed: %s", string(bodyBytes))
 	}
 
-	var result struct {
-		Ready   bool     `json:"ready"`
-		Players []string `json:"players"`
-	}
+	var result createRoomResponse
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return false, err
+		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
-
-	return result.Ready, nil
+	return &result, nil
 }
 
-// STEP 2: Check if GAME is ready (exists)
-func (a *APIClient) checkGameReady(roomID string) (bool, error) {
-	url := fmt.Sprintf("http://localhost:8003/game/status?room_id=%s", roomID)
+// JOIN PRIVATE ROOM
+func (a *APIClient) joinPrivateRoom(code string) (string, error) {
+	url := fmt.Sprintf("%s/rooms/join/%s", a.roomServiceURL, code)
 
-	resp, err := a.httpClient.Get(url)
+	resp, err := a.doAuthorized(func() (*http.Request, error) {
+		return http.NewRequest("POST", url, nil)
+	})
 	if err != nil {
-		return false, err
+		return "", err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusNotFound {
-		return false, nil
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return "", fmt.Errorf("invite code %s not found", code)
+	case http.StatusGone:
+		return "", fmt.Errorf("invite code %s has expired", code)
+	default:
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("join room failed: %s", string(bodyBytes))
+	}
+
+	var result joinRoomResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+	return result.RoomID, nil
+}
+
+// LEAVE MATCHMAKING QUEUE
+func (a *APIClient) cancelMatchmaking() error {
+	resp, err := a.doAuthorized(func() (*http.Request, error) {
+		return http.NewRequest("DELETE", a.roomServiceURL+"/join", nil)
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("leave queue failed: %s", string(bodyBytes))
+	}
+	return nil
+}
+
+// ROOM EVENTS
+type roomEvent struct {
+	Type    string   `json:"type"` // opponent_joined, game_created or room_closed
+	RoomID  string   `json:"room_id"`
+	Players []string `json:"players"`
+	Reason  string   `json:"reason"`
+}
+
+// streamRoomEvents opens the room's Server-Sent Events stream
+// Events arrive on the returned channel, which is closed when the stream ends or ctx is done
+func (a *APIClient) streamRoomEvents(ctx context.Context, roomID string) (<-chan roomEvent, error) {
+	url := fmt.Sprintf("%s/rooms/%s/events", a.roomServiceURL, roomID)
+
+	resp, err := a.doAuthorizedWith(a.streamClient, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err == nil {
+			req.Header.Set("Accept", "text/event-stream")
+		}
+		return req, err
+	})
+	if err != nil {
+		return nil, err
 	}
 	if resp.StatusCode != http.StatusOK {
-		// Read body for logging/debug
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return false, fmt.Errorf("unexpected status checking game: %d %s", resp.StatusCode, string(bodyBytes))
+		resp.Body.Close()
+		return nil, fmt.Errorf("room events failed: %s", string(bodyBytes))
 	}
 
-	// We only care that the game exists (200 OK). Status may be "waiting_for_players" until sockets connect.
-	return true, nil
+	events := make(chan roomEvent)
+	go func() {
+		defer resp.Body.Close()
+		defer close(events)
+
+		// Only "data:" lines matter, the event name is repeated in the JSON
+		var data strings.Builder
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "data:"):
+				data.WriteString(strings.TrimSpace(strings.TrimPrefix(line, "data:")))
+			case line == "" && data.Len() > 0:
+				var event roomEvent
+				if err := json.Unmarshal([]byte(data.String()), &event); err == nil {
+					select {
+					case events <- event:
+					case <-ctx.Done():
+						return
+					}
+				}
+				data.Reset()
+			}
+		}
+	}()
+	return events, nil
 }
 
 // Leave active room (uses JWT for user identity)
 func (a *APIClient) leaveRoom(roomID string) error {
 	url := fmt.Sprintf("%s/rooms/%s/leave", a.roomServiceURL, roomID)
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte("{}")))
+	resp, err := a.doAuthorized(func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte("{}")))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		return req, err
+	})
 	if err != nil {
-		return fmt.Errorf("failed to create leave request: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+a.token)
-
-	resp, err := a.httpClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("connection failed: %w", err)
+		return err
 	}
 	defer resp.Body.Close()
 
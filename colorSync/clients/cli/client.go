@@ -1,27 +1,55 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
 	"strings"
 	"time"
 )
 
+// How the client finds an opponent
+const (
+	modeMatchmaking = "matchmaking" // Public queue
+	modeCreate      = "create"      // Private room, print an invite code
+	modeJoin        = "join"        // Private room, enter an invite code
+	modeStats       = "stats"       // No game, show the player's statistics
+	modeLeaderboard = "leaderboard" // No game, show the leaderboard
+)
+
 // Client represents the CLI game client
 type Client struct {
-	username  string     // Player's username e.g "arbeiter"
-	userID    string     // UUID from user service e.g "25769518-e1de-4c7a-b7f5-c7648195898d"
-	roomID    string     // Room ID from room service e.g "6392b3fc-2745-46df-bba5-60390b4ad397"
-	apiClient *APIClient // Pointer to HTTP client, handles the HTTP requests
-	ui        *UI        // Pointer to UI renderer, handles terminal display
+	mode       string     // One of modeMatchmaking, modeCreate, modeJoin, modeStats, modeLeaderboard
+	inviteCode string     // Code to enter in modeJoin e.g "K7QX4M"
+	rules      *gameRules // Rules to create the room with in modeCreate, nil for defaults
+	board      boardQuery // Leaderboard to show in modeLeaderboard
+	username   string     // Player's username e.g "arbeiter"
+	userID     string     // UUID from user service e.g "25769518-e1de-4c7a-b7f5-c7648195898d"
+	roomID     string     // Room ID from room service e.g "6392b3fc-2745-46df-bba5-60390b4ad397"
+	apiClient  *APIClient // Pointer to HTTP client, handles the HTTP requests
+	ui         *UI        // Pointer to UI renderer, handles terminal display
+}
+
+// boardQuery is which leaderboard to show
+type boardQuery struct {
+	period string // all, weekly or daily
+	metric string // rating, wins or latency
+	limit  int
 }
 
 // newClient creates and initializes a new Client instance
-func newClient(username string) *Client {
+func newClient(username, mode, inviteCode string, rules *gameRules, board boardQuery) *Client {
 	return &Client{
-		username:  username,
-		apiClient: newAPIClient(), // Initialize the API client
-		ui:        newUI(),        // Initialize the UI renderer
+		mode:       mode,
+		inviteCode: inviteCode,
+		rules:      rules,
+		board:      board,
+		username:   username,
+		apiClient:  newAPIClient(), // Initialize the API client
+		ui:         newUI(),        // Initialize the UI renderer
 	}
 }
 
@@ -29,55 +57,63 @@ func newClient(username string) *Client {
 func (c *Client) Run() error {
 	c.ui.showWelcome()
 
-	// Prompt for username if not provided
-	if strings.TrimSpace(c.username) == "" {
-		c.username = promptForUsername()
+	if err := c.signIn(); err != nil {
+		return err
 	}
+	defer c.signOut()
 
-	// Prompt for password
-	fmt.Print("Enter password: ")
-	password := promptForPassword()
+	switch c.mode {
+	case modeStats:
+		return c.showStats()
+	case modeLeaderboard:
+		return c.showLeaderboard()
+	}
 
-	// Try login first (with password)
-	fmt.Println("Logging in user...")
-	userID, err := c.apiClient.login(c.username, password)
-	if err != nil {
-		// If login fails, try registration
-		fmt.Println("User not found, registering...")
-		userID, err = c.apiClient.register(c.username, password) // Pass password for registration
+	// Join room
+	waitTimeout := 75 * time.Second
+	switch c.mode {
+	case modeCreate:
+		fmt.Println("Creating private room...")
+		created, err := c.apiClient.createPrivateRoom(c.rules)
 		if err != nil {
-			return fmt.Errorf("registration failed: %w", err)
+			return fmt.Errorf("failed to create room: %w", err)
 		}
-		fmt.Printf("Registered as %s\n", c.username)
-	} else {
-		fmt.Printf("Welcome back, %s!\n", c.username)
-	}
-	c.userID = userID
+		c.roomID = created.RoomID
+		waitTimeout = time.Until(created.ExpiresAt)
 
-	// Join room
-	fmt.Println("Joining matchmaking queue...")
-	roomID, err := c.apiClient.joinRoom(userID)
-	if err != nil {
-		return fmt.Errorf("failed to join room: %w", err)
+		fmt.Println()
+		c.ui.showInfo(fmt.Sprintf("🔑 Invite code: %s", created.InviteCode))
+		fmt.Printf("   Your opponent runs: go run . join %s\n", created.InviteCode)
+		fmt.Printf("   Code expires at %s\n", created.ExpiresAt.Local().Format("15:04"))
+		fmt.Printf("   Rules: %s\n", describeRules(created.Config))
+		fmt.Println()
+	case modeJoin:
+		fmt.Printf("Joining private room %s...\n", c.inviteCode)
+		roomID, err := c.apiClient.joinPrivateRoom(c.inviteCode)
+		if err != nil {
+			return fmt.Errorf("failed to join room: %w", err)
+		}
+		c.roomID = roomID
+	default:
+		fmt.Println("Joining matchmaking queue...")
+		roomID, err := c.apiClient.joinRoom(c.userID)
+		if err != nil {
+			return fmt.Errorf("failed to join room: %w", err)
+		}
+		c.roomID = roomID
 	}
-	c.roomID = roomID
-	log.Printf("Debug: Room ID = %s", roomID)
+	log.Printf("Debug: Room ID = %s", c.roomID)
 
-	// STEP 1: Wait for opponent (room becomes full)
+	// Wait for opponent and for Game Service to create the game
 	fmt.Println("Waiting for opponent...")
-	if err := c.waitForRoomFull(); err != nil {
-		return fmt.Errorf("failed waiting for opponent: %w", err)
-	}
-
-	// STEP 2: Room is full; wait briefly for Game Service to be notified/created
-	fmt.Println("Opponent found! Preparing game...")
-	if err := c.waitForGameReady(); err != nil {
+	if err := c.waitForGame(waitTimeout); err != nil {
+		c.abandonRoom()
 		return fmt.Errorf("failed waiting for game: %w", err)
 	}
 
 	// NOW connect to game
 	log.Println("Connecting to game...")
-	gameClient := newGameClient(c.roomID, c.userID, c.username, c.ui)
+	gameClient := newGameClient(c.roomID, c.userID, c.username, c.apiClient.token, c.ui)
 	if err := gameClient.connect(); err != nil {
 		// 🆕 Best-effort cleanup on connection failure
 		_ = c.apiClient.leaveRoom(c.roomID)
@@ -85,7 +121,7 @@ func (c *Client) Run() error {
 	}
 
 	// Play game (this will block until game ends)
-	err = gameClient.playGame()
+	err := gameClient.playGame()
 	gameClient.close()
 
 	// Always leave the room after game ends (best-effort)
@@ -107,35 +143,128 @@ func (c *Client) Run() error {
 	return nil
 }
 
-// Wait until room has 2 players
-func (c *Client) waitForRoomFull() error {
-	const maxAttempts = 60
-	for range maxAttempts {
-		full, err := c.apiClient.checkRoomFull(c.roomID)
+// signIn asks for the player's credentials and logs in, registering them if they are new
+func (c *Client) signIn() error {
+	// Prompt for username if not provided
+	if strings.TrimSpace(c.username) == "" {
+		c.username = promptForUsername()
+	}
+
+	// Prompt for password
+	fmt.Print("Enter password: ")
+	password := promptForPassword()
+
+	// Try login first (with password)
+	fmt.Println("Logging in user...")
+	userID, err := c.apiClient.login(c.username, password)
+	if err != nil {
+		// If login fails, try registration
+		fmt.Println("User not found, registering...")
+		userID, err = c.apiClient.register(c.username, password) // Pass password for registration
 		if err != nil {
-			log.Printf("Error checking room status: %v", err)
-		}
-		if full {
-			return nil
+			return fmt.Errorf("registration failed: %w", err)
 		}
-		time.Sleep(1 * time.Second)
+		fmt.Printf("Registered as %s\n", c.username)
+	} else {
+		fmt.Printf("Welcome back, %s!\n", c.username)
+	}
+	c.userID = userID
+	return nil
+}
+
+// signOut ends the session on the server, not just forgets the token
+func (c *Client) signOut() {
+	if err := c.apiClient.logout(); err != nil {
+		log.Printf("Warning: failed to log out: %v", err)
 	}
-	return fmt.Errorf("timeout waiting for opponent")
 }
 
-// Wait until game exists (Game Rules Service has created it)
-func (c *Client) waitForGameReady() error {
-	const maxAttempts = 15
-	for range maxAttempts {
-		ready, err := c.apiClient.checkGameReady(c.roomID)
-		if err != nil {
-			log.Printf("Error checking game status: %v", err)
-		}
-		if ready {
+// showStats prints the player's statistics over every game they finished
+func (c *Client) showStats() error {
+	stats, err := c.apiClient.getStats(c.userID)
+	if err != nil {
+		return fmt.Errorf("failed to get stats: %w", err)
+	}
+	c.ui.showPlayerStats(stats)
+	return nil
+}
+
+// showLeaderboard prints the top players and where we stand
+func (c *Client) showLeaderboard() error {
+	board, err := c.apiClient.getLeaderboard(c.board.period, c.board.metric, c.board.limit)
+	if err != nil {
+		return fmt.Errorf("failed to get leaderboard: %w", err)
+	}
+	mine, err := c.apiClient.getMyRank(c.board.period, c.board.metric)
+	if err != nil {
+		return fmt.Errorf("failed to get your rank: %w", err)
+	}
+	c.ui.showLeaderboard(board, mine, c.userID)
+	return nil
+}
+
+// Wait until the room is full and Game Service has created the game
+// Follows the room event stream instead of polling
+// Ctrl+C stops waiting so the caller can clean up on the server
+func (c *Client) waitForGame(timeout time.Duration) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	events, err := c.apiClient.streamRoomEvents(ctx, c.roomID)
+	if err != nil {
+		return err
+	}
+
+	for event := range events {
+		switch event.Type {
+		case "opponent_joined":
+			// Matchmaking may have moved us into the opponent's room
+			c.roomID = event.RoomID
+			fmt.Println("Opponent found! Preparing game...")
+		case "game_created":
+			c.roomID = event.RoomID
 			fmt.Println("Game ready!")
 			return nil
+		case "room_error":
+			return fmt.Errorf("room failed: %s", event.Reason)
+		case "room_closed":
+			return fmt.Errorf("room closed (%s)", event.Reason)
 		}
-		time.Sleep(1 * time.Second)
 	}
-	return fmt.Errorf("timeout waiting for game to start")
+
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return fmt.Errorf("cancelled")
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("timeout waiting for opponent")
+	}
+	return fmt.Errorf("room event stream ended unexpectedly")
+}
+
+// abandonRoom gives up our place before a game started (best-effort)
+// Leaves the matchmaking queue, or the room if we were already matched or in a private room
+func (c *Client) abandonRoom() {
+	if c.mode == modeMatchmaking {
+		if err := c.apiClient.cancelMatchmaking(); err == nil {
+			return
+		}
+	}
+	_ = c.apiClient.leaveRoom(c.roomID)
+}
+
+// describeRules summarizes the room's rules on one line
+func describeRules(rules gameRules) string {
+	pause := 0
+	if rules.PauseMs != nil {
+		pause = *rules.PauseMs
+	}
+	grace := 0
+	if rules.ReconnectGraceMs != nil {
+		grace = *rules.ReconnectGraceMs
+	}
+	return fmt.Sprintf("%s mode, %d rounds, %.1fs to answer, %.1fs pause, colors %s, %s on wrong answers, %s scoring, %.0fs to reconnect",
+		rules.Mode, rules.Rounds, float64(rules.RoundTimeoutMs)/1000, float64(pause)/1000,
+		strings.Join(rules.Palette, "/"), rules.WrongAnswer, rules.Scoring, float64(grace)/1000)
 }
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"strings"
 	"time"
@@ -16,12 +17,22 @@ type GameClient struct {
 	roomID   string
 	userID   string
 	username string
+	token    string // JWT, proves who we are to the game server
 	conn     *websocket.Conn
 	ui       *UI
 
-	gameActive bool // Track if game is active
+	gameActive     bool          // Track if game is active
+	options        []string      // Answers offered this round, from ROUND_START
+	scoring        string        // How the winner is decided, from the GAME_START rules
+	reconnectGrace time.Duration // How long the server waits for us after a drop
 }
 
+// defaultOptions is used until the server tells us what we can answer
+var defaultOptions = []string{"red", "blue", "green", "yellow"}
+
+// defaultReconnectGrace is used until the GAME_START rules tell us otherwise
+const defaultReconnectGrace = 15 * time.Second
+
 // WSMessage represents a WebSocket message
 type WSMessage struct {
 	Type    string                 `json:"type"`
@@ -29,22 +40,28 @@ type WSMessage struct {
 }
 
 // newGameClient creates a new game client
-func newGameClient(roomID, userID, username string, ui *UI) *GameClient {
+func newGameClient(roomID, userID, username, token string, ui *UI) *GameClient {
 	return &GameClient{
-		roomID:     roomID,
-		userID:     userID,
-		username:   username,
-		ui:         ui,
-		gameActive: false,
+		roomID:         roomID,
+		userID:         userID,
+		username:       username,
+		token:          token,
+		ui:             ui,
+		gameActive:     false,
+		options:        defaultOptions,
+		reconnectGrace: defaultReconnectGrace,
 	}
 }
 
 // connect establishes WebSocket connection
 func (g *GameClient) connect() error {
-	url := fmt.Sprintf("ws://localhost:8003/game/ws?room_id=%s&user_id=%s",
-		g.roomID, g.userID)
+	url := fmt.Sprintf("ws://localhost:8003/game/ws?room_id=%s", g.roomID)
+
+	// Authenticate the upgrade with our JWT
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+g.token)
 
-	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	conn, _, err := websocket.DefaultDialer.Dial(url, header)
 	if err != nil {
 		return fmt.Errorf("failed to connect to game: %w", err)
 	}
@@ -67,36 +84,12 @@ func (g *GameClient) playGame() error {
 	errorChan := make(chan error)
 	done := make(chan struct{}) // Signal to stop goroutine
 
-	// Goroutine to receive messages
-	go func() {
-		defer close(messageChan) // Clean shutdown
-		for {
-			var msg WSMessage
-			err := g.conn.ReadJSON(&msg)
-			if err != nil {
-				select {
-				case errorChan <- err:
-				case <-done: // Don't block if main loop exited
-					return
-				}
-				return
-			}
-
-			select {
-			case messageChan <- msg:
-			case <-done: // Don't block if main loop exited
-				return
-			}
-		}
-	}()
+	go g.readMessages(g.conn, messageChan, errorChan, done)
 
 	// Main game loop
 	for {
 		select {
-		case msg, ok := <-messageChan:
-			if !ok {
-				return nil // Channel closed, exit loop
-			}
+		case msg := <-messageChan:
 			gameOver := g.handleMessage(msg)
 			if gameOver {
 				close(done)                        // Signal goroutine to stop
@@ -105,6 +98,15 @@ func (g *GameClient) playGame() error {
 			}
 
 		case err := <-errorChan:
+			// Dropped mid-game - the server holds our place for a while, so try to get back in
+			if g.gameActive && g.reconnectGrace > 0 {
+				g.ui.showError("Connection lost - reconnecting...")
+				if err = g.reconnect(); err == nil {
+					go g.readMessages(g.conn, messageChan, errorChan, done)
+					continue
+				}
+			}
+
 			close(done) // Signal goroutine to stop
 			// Only report error if game is still active
 			if g.gameActive {
@@ -115,6 +117,43 @@ func (g *GameClient) playGame() error {
 	}
 }
 
+// readMessages receives messages from conn until it fails
+func (g *GameClient) readMessages(conn *websocket.Conn, messageChan chan<- WSMessage, errorChan chan<- error, done <-chan struct{}) {
+	for {
+		var msg WSMessage
+		err := conn.ReadJSON(&msg)
+		if err != nil {
+			select {
+			case errorChan <- err:
+			case <-done: // Don't block if main loop exited
+			}
+			return
+		}
+
+		select {
+		case messageChan <- msg:
+		case <-done: // Don't block if main loop exited
+			return
+		}
+	}
+}
+
+// reconnect dials the game again after a dropped connection, until the grace period runs out
+func (g *GameClient) reconnect() error {
+	g.close()
+
+	deadline := time.Now().Add(g.reconnectGrace)
+	for attempt := 1; time.Now().Before(deadline); attempt++ {
+		time.Sleep(time.Second)
+		err := g.connect()
+		if err == nil {
+			return nil
+		}
+		log.Printf("Reconnect attempt %d failed: %v", attempt, err)
+	}
+	return fmt.Errorf("could not reconnect within %s", g.reconnectGrace)
+}
+
 // handleMessage processes incoming WebSocket messages
 func (g *GameClient) handleMessage(msg WSMessage) bool {
 	switch msg.Type {
@@ -135,6 +174,28 @@ func (g *GameClient) handleMessage(msg WSMessage) bool {
 	case "WRONG_ANSWER":
 		g.ui.showError("❌ Wrong! Blocked for this round.")
 
+	case "ROUND_FEEDBACK":
+		if message, ok := msg.Payload["message"].(string); ok {
+			g.ui.showError("❌ " + message)
+		}
+		// Some rules let us answer again in the same round
+		if retry, _ := msg.Payload["retry"].(bool); retry {
+			g.ui.showAnswerPrompt(g.options)
+			go g.handlePlayerInput()
+		}
+
+	case "STATE_SYNC":
+		g.handleStateSync(msg)
+
+	case "GAME_PAUSED":
+		if playerID, _ := msg.Payload["player_id"].(string); playerID != g.userID {
+			graceMs, _ := msg.Payload["grace_ms"].(float64)
+			g.ui.showInfo(fmt.Sprintf("⏸  Opponent disconnected - waiting up to %ds for them to come back", int(graceMs)/1000))
+		}
+
+	case "GAME_RESUMED":
+		g.ui.showInfo("▶  Both players are back - game resumed!")
+
 	case "ERROR":
 		if errMsg, ok := msg.Payload["message"].(string); ok {
 			g.ui.showError(errMsg)
@@ -151,18 +212,81 @@ func (g *GameClient) handleMessage(msg WSMessage) bool {
 func (g *GameClient) handleGameStart(msg WSMessage) {
 	maxRounds := int(msg.Payload["max_rounds"].(float64))
 
-	g.ui.showGameStart(maxRounds)
+	// Pick up the room's rules
+	g.applyRules(msg.Payload)
+	instructions, ok := msg.Payload["instructions"].(string)
+	if !ok {
+		instructions = "Match the COLOR of the text (not the word!)"
+	}
+
+	g.ui.showGameStart(maxRounds, instructions, g.scoring)
 	g.gameActive = true // Game is now active
 }
 
+// applyRules picks up the room's rules from a GAME_START or STATE_SYNC payload
+func (g *GameClient) applyRules(payload map[string]interface{}) {
+	config, ok := payload["config"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	g.scoring, _ = config["scoring"].(string)
+	if graceMs, ok := config["reconnect_grace_ms"].(float64); ok {
+		g.reconnectGrace = time.Duration(graceMs) * time.Millisecond
+	}
+}
+
+// handleStateSync catches us up after reconnecting to a running game
+func (g *GameClient) handleStateSync(msg WSMessage) {
+	g.applyRules(msg.Payload)
+	g.gameActive = true
+
+	round, _ := msg.Payload["round"].(float64)
+	maxRounds, _ := msg.Payload["max_rounds"].(float64)
+
+	// Scores so far, ours first
+	var mine, theirs float64
+	if scores, ok := msg.Payload["scores"].(map[string]interface{}); ok {
+		for uid, s := range scores {
+			score, _ := s.(map[string]interface{})
+			if uid == g.userID {
+				mine, _ = score["score"].(float64)
+			} else {
+				theirs, _ = score["score"].(float64)
+			}
+		}
+	}
+	g.ui.showInfo(fmt.Sprintf("🔌 Reconnected! Round %d of %d - score: you %d, opponent %d",
+		int(round), int(maxRounds), int(mine), int(theirs)))
+
+	// Still time to answer the round we dropped out of
+	current, ok := msg.Payload["current"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	if canAnswer, _ := current["can_answer"].(bool); !canAnswer {
+		return
+	}
+	g.handleRoundStart(WSMessage{Type: "ROUND_START", Payload: current})
+}
+
 // handleRoundStart processes ROUND_START message and gets player input
 func (g *GameClient) handleRoundStart(msg WSMessage) {
 	round := int(msg.Payload["round"].(float64))
 	word := msg.Payload["word"].(string)
 	color := msg.Payload["color"].(string)
 
+	// The game mode decides what we may answer
+	if options, ok := msg.Payload["options"].([]interface{}); ok && len(options) > 0 {
+		g.options = nil
+		for _, o := range options {
+			if option, ok := o.(string); ok {
+				g.options = append(g.options, option)
+			}
+		}
+	}
+
 	// Display the Stroop test
-	g.ui.showRound(round, word, color)
+	g.ui.showRound(round, word, color, g.options)
 
 	// Get player input in a goroutine (non-blocking)
 	go g.handlePlayerInput()
@@ -181,21 +305,16 @@ func (g *GameClient) handlePlayerInput() {
 		return
 	}
 
-	// Map shortcuts to full color names
-	colorMap := map[string]string{
-		"r":      "red",
-		"b":      "blue",
-		"g":      "green",
-		"y":      "yellow",
-		"red":    "red",
-		"blue":   "blue",
-		"green":  "green",
-		"yellow": "yellow",
+	// Map shortcuts (first letter) and full names to this round's answers
+	answerMap := make(map[string]string)
+	for _, option := range g.options {
+		answerMap[option[:1]] = option
+		answerMap[option] = option
 	}
 
-	answer, valid := colorMap[input]
+	answer, valid := answerMap[input]
 	if !valid {
-		g.ui.showError("Invalid input! Use: r/b/g/y or red/blue/green/yellow")
+		g.ui.showError(fmt.Sprintf("Invalid input! Use: %s or %s", shortcuts(g.options), strings.Join(g.options, "/")))
 		return
 	}
 
@@ -235,6 +354,12 @@ func (g *GameClient) handleRoundResult(msg WSMessage) {
 
 	// Display result - pass winner string directly
 	g.ui.showRoundResult(round, winner, g.userID, latency)
+
+	// Points scoring: both players may have scored
+	if points, ok := msg.Payload["points"].(map[string]interface{}); ok {
+		mine, theirs := splitPoints(points, g.userID)
+		g.ui.showRoundPoints(mine, theirs)
+	}
 }
 
 // handleGameOver processes GAME_OVER message
@@ -308,4 +433,46 @@ func (g *GameClient) handleGameOver(msg WSMessage) {
 
 	// Display game over screen
 	g.ui.showGameOver(winner, g.userID, wins, opponentWins, totalLatency, avgLatency)
+
+	// Points scoring: show how the points were earned round by round
+	if _, ok := myStatsData["round_points"]; ok {
+		var mine, theirs []int
+		for uid, statsData := range stats {
+			data, _ := statsData.(map[string]interface{})
+			rounds, _ := data["round_points"].([]interface{})
+			points := make([]int, len(rounds))
+			for i, p := range rounds {
+				f, _ := p.(float64)
+				points[i] = int(f)
+			}
+			if uid == g.userID {
+				mine = points
+			} else {
+				theirs = points
+			}
+		}
+		g.ui.showPointsBreakdown(mine, theirs)
+	}
+}
+
+// shortcuts lists the one-letter keys for the answers, e.g. "r/b/g/y"
+func shortcuts(options []string) string {
+	keys := make([]string, len(options))
+	for i, option := range options {
+		keys[i] = option[:1]
+	}
+	return strings.Join(keys, "/")
+}
+
+// splitPoints separates our points from the opponent's in a userID -> points map
+func splitPoints(points map[string]interface{}, myUserID string) (mine, theirs int) {
+	for uid, p := range points {
+		f, _ := p.(float64)
+		if uid == myUserID {
+			mine = int(f)
+		} else {
+			theirs = int(f)
+		}
+	}
+	return mine, theirs
 }
@@ -15,10 +15,44 @@ import (
 func main() {
 	// Parse command-line flags
 	username := flag.String("username", "", "Your username (optional - will prompt if not provided)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [create [rule flags] | join <code> | stats | leaderboard [board flags]]\n\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  (no command)  join the public matchmaking queue\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  create        create a private room and print its invite code\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "                (run 'create -h' for the rule flags)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  join <code>   join a private room with an invite code\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  stats         show your statistics over all your games\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  leaderboard   show the best players and your rank\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "                (run 'leaderboard -h' for the board flags)\n\n")
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
+	// Pick how to find an opponent
+	var mode, inviteCode string
+	var rules *gameRules
+	var board boardQuery
+	switch args := flag.Args(); {
+	case len(args) == 0:
+		mode = modeMatchmaking
+	case args[0] == "create":
+		mode = modeCreate
+		rules = parseRuleFlags(args[1:])
+	case args[0] == "join" && len(args) == 2:
+		mode = modeJoin
+		inviteCode = args[1]
+	case args[0] == "stats" && len(args) == 1:
+		mode = modeStats
+	case args[0] == "leaderboard":
+		mode = modeLeaderboard
+		board = parseBoardFlags(args[1:])
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
 	// Create client instance
-	client := newClient(*username)
+	client := newClient(*username, mode, inviteCode, rules, board)
 
 	// Run client
 	if err := client.Run(); err != nil {
@@ -26,6 +60,66 @@ func main() {
 	}
 }
 
+// parseRuleFlags reads the game rules for a private room from the create subcommand
+// Returns nil when no rule was given, leaving every rule to the server defaults
+func parseRuleFlags(args []string) *gameRules {
+	fs := flag.NewFlagSet("create", flag.ExitOnError)
+	mode := fs.String("mode", "", "Game mode: classic, reverse, mixed, match or escalating (default classic)")
+	rounds := fs.Int("rounds", 0, "Number of rounds (default 5)")
+	roundTimeout := fs.Duration("round-timeout", 0, "Time to answer each round, e.g. 3s (default 5s)")
+	pause := fs.Duration("pause", -1, "Pause between rounds, e.g. 1s (default 3s)")
+	palette := fs.String("palette", "", "Comma-separated colors from red,blue,green,yellow,purple,cyan (default red,blue,green,yellow)")
+	wrongAnswer := fs.String("wrong-answer", "", "What a wrong click does: lockout, retry or forfeit (default lockout)")
+	scoring := fs.String("scoring", "", "How the winner is decided: rounds, net or points (default rounds)")
+	reconnectGrace := fs.Duration("reconnect-grace", -1, "How long a dropped player has to rejoin, 0 to forfeit at once (default 15s)")
+	fs.Parse(args)
+
+	if fs.NArg() > 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if fs.NFlag() == 0 {
+		return nil
+	}
+
+	rules := &gameRules{
+		Mode:           *mode,
+		Rounds:         *rounds,
+		RoundTimeoutMs: int(roundTimeout.Milliseconds()),
+		WrongAnswer:    *wrongAnswer,
+		Scoring:        *scoring,
+	}
+	if *pause >= 0 {
+		ms := int(pause.Milliseconds())
+		rules.PauseMs = &ms
+	}
+	if *reconnectGrace >= 0 {
+		ms := int(reconnectGrace.Milliseconds())
+		rules.ReconnectGraceMs = &ms
+	}
+	if *palette != "" {
+		for _, color := range strings.Split(*palette, ",") {
+			rules.Palette = append(rules.Palette, strings.ToLower(strings.TrimSpace(color)))
+		}
+	}
+	return rules
+}
+
+// parseBoardFlags reads which leaderboard to show from the leaderboard subcommand
+func parseBoardFlags(args []string) boardQuery {
+	fs := flag.NewFlagSet("leaderboard", flag.ExitOnError)
+	period := fs.String("period", "all", "Time span: all, weekly or daily")
+	metric := fs.String("metric", "rating", "Ranked by: rating, wins or latency")
+	limit := fs.Int("limit", 10, "Number of players to show (at most 100)")
+	fs.Parse(args)
+
+	if fs.NArg() > 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	return boardQuery{period: *period, metric: *metric, limit: *limit}
+}
+
 // promptForUsername asks the usr to enter their username via stdin
 func promptForUsername() string {
 	reader := bufio.NewReader(os.Stdin)
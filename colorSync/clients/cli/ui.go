@@ -47,30 +47,37 @@ func (ui *UI) showWelcome() {
 }
 
 // showGameStart displays the game start information
-func (ui *UI) showGameStart(maxRounds int) {
+func (ui *UI) showGameStart(maxRounds int, instructions string, scoring string) {
 	ui.clear()
 	ui.bold.Println("🎮 GAME STARTING!")
 	fmt.Println()
 	ui.cyan.Printf("  You will play %d rounds\n", maxRounds)
-	ui.cyan.Println(" Match the COLOR of the text (not the word!)")
+	ui.cyan.Println("  " + instructions)
 	fmt.Println()
 	ui.yellow.Println("  🏆 Winner Determination:")
-	ui.yellow.Println("   1. Most rounds won")
+	switch scoring {
+	case "net":
+		ui.yellow.Println("   1. Most rounds won minus wrong answers")
+	case "points":
+		ui.yellow.Println("   1. Most points: faster correct answers score more, wrong answers cost points")
+	default:
+		ui.yellow.Println("   1. Most rounds won")
+	}
 	ui.yellow.Println("   2. If tied: Lowest total latency wins")
 	ui.yellow.Println("   3. If still tied: It's a draw!")
 	fmt.Println()
-	ui.magenta.Println("  Controls: r=red  b=blue  g=green  y=yellow")
+	ui.magenta.Println("  Controls: first letter or full answer, e.g. r or red")
 	fmt.Println()
 	ui.cyan.Println("  Get ready...")
 }
 
 // showRound displays the Stroop test for the round
-func (ui *UI) showRound(round int, word string, textColor string) {
+func (ui *UI) showRound(round int, word string, textColor string, options []string) {
 	fmt.Println(strings.Repeat("─", 50))
 	ui.bold.Printf("ROUND %d\n", round)
 	fmt.Println()
 
-	ui.cyan.Printf("What COLOR is this text? → ")
+	ui.cyan.Printf("→ ")
 
 	switch textColor {
 	case "red":
@@ -81,12 +88,21 @@ func (ui *UI) showRound(round int, word string, textColor string) {
 		ui.green.Println(word)
 	case "yellow":
 		ui.yellow.Println(word)
+	case "purple":
+		ui.magenta.Println(word)
+	case "cyan":
+		ui.cyan.Println(word)
 	default:
 		fmt.Println(word) // Fallback to default color
 	}
 
 	fmt.Println()
-	ui.yellow.Print("Your answer [r/b/g/y]: ")
+	ui.showAnswerPrompt(options)
+}
+
+// showAnswerPrompt asks for an answer with the options' shortcuts
+func (ui *UI) showAnswerPrompt(options []string) {
+	ui.yellow.Printf("Your answer [%s]: ", shortcuts(options))
 }
 
 // showRoundResult displays the result of a round
@@ -102,6 +118,28 @@ func (ui *UI) showRoundResult(round int, winner string, myUserID string, latency
 	}
 }
 
+// showRoundPoints displays what both players scored this round
+func (ui *UI) showRoundPoints(mine, theirs int) {
+	ui.cyan.Printf("   Points: you %+d, opponent %+d\n", mine, theirs)
+}
+
+// showPointsBreakdown displays points per round and the totals
+func (ui *UI) showPointsBreakdown(mine, theirs []int) {
+	ui.cyan.Println("🔢 Points per round:")
+	var myTotal, theirTotal int
+	for i := range mine {
+		opponent := 0
+		if i < len(theirs) {
+			opponent = theirs[i]
+		}
+		fmt.Printf("  Round %d: you %+4d   opponent %+4d\n", i+1, mine[i], opponent)
+		myTotal += mine[i]
+		theirTotal += opponent
+	}
+	ui.bold.Printf("  Total:   you %4d   opponent %4d\n", myTotal, theirTotal)
+	fmt.Println()
+}
+
 // showGameOver displays the final game results
 func (ui *UI) showGameOver(winner string, myUserID string, wins, opponentWins int, totalLatency, avgLatency int64) {
 	ui.clear()
@@ -142,6 +180,76 @@ func (ui *UI) showGameOver(winner string, myUserID string, wins, opponentWins in
 	time.Sleep(2 * time.Second) // Give user time to read stats
 }
 
+// showPlayerStats displays a player's statistics over all their games
+func (ui *UI) showPlayerStats(stats *playerStats) {
+	fmt.Println()
+	ui.bold.Printf("📊 %s - rating %d\n", stats.Username, stats.Rating)
+	fmt.Println(strings.Repeat("=", 50))
+
+	if stats.GamesPlayed == 0 {
+		fmt.Println("  No games played yet - go play one!")
+		fmt.Println(strings.Repeat("=", 50))
+		return
+	}
+
+	fmt.Printf("  Games: %d played, %d won, %d lost, %d drawn\n",
+		stats.GamesPlayed, stats.GamesWon, stats.GamesLost, stats.GamesDrawn)
+	fmt.Printf("  Rounds Won: %d of %d\n", stats.RoundsWon, stats.RoundsPlayed)
+	if stats.BestReactionMs > 0 {
+		fmt.Printf("  Reaction Time: %dms average, %dms best\n", stats.AvgReactionMs, stats.BestReactionMs)
+	}
+	fmt.Printf("  Accuracy: %.0f%%\n", stats.Accuracy*100)
+	fmt.Printf("  Wrong Answers: %.2f per round\n", stats.WrongAnswerRate)
+
+	switch {
+	case stats.CurrentStreak > 0:
+		ui.green.Printf("  🔥 %d win streak\n", stats.CurrentStreak)
+	case stats.CurrentStreak < 0:
+		ui.red.Printf("  %d losses in a row\n", -stats.CurrentStreak)
+	}
+	fmt.Println(strings.Repeat("=", 50))
+}
+
+// showLeaderboard displays a page of the leaderboard as a table, and our own rank
+func (ui *UI) showLeaderboard(board *leaderboardResponse, mine *myRankResponse, myUserID string) {
+	periods := map[string]string{"all": "All Time", "weekly": "This Week", "daily": "Today"}
+	metrics := map[string]string{"rating": "Rating", "wins": "Wins", "latency": "Fastest Reactions"}
+
+	fmt.Println()
+	ui.bold.Printf("🏆 Leaderboard - %s, by %s\n", periods[board.Period], metrics[board.Metric])
+	fmt.Println(strings.Repeat("=", 60))
+
+	if len(board.Entries) == 0 {
+		fmt.Println("  Nobody ranked yet - go play a game!")
+		fmt.Println(strings.Repeat("=", 60))
+		return
+	}
+
+	fmt.Printf("  %-5s %-20s %7s %6s %5s %10s\n", "Rank", "Player", "Rating", "Games", "Wins", "Avg React")
+	fmt.Println("  " + strings.Repeat("-", 58))
+	for _, entry := range board.Entries {
+		reaction := "-"
+		if entry.AvgReactionMs > 0 {
+			reaction = fmt.Sprintf("%dms", entry.AvgReactionMs)
+		}
+		row := fmt.Sprintf("  %-5d %-20s %7d %6d %5d %10s", entry.Rank, entry.Username,
+			entry.Rating, entry.GamesPlayed, entry.GamesWon, reaction)
+		if entry.UserID == myUserID {
+			ui.green.Println(row)
+		} else {
+			fmt.Println(row)
+		}
+	}
+	fmt.Println(strings.Repeat("=", 60))
+
+	if mine == nil {
+		fmt.Println("  You're not ranked for this period yet.")
+	} else {
+		ui.cyan.Printf("  You're #%d of %d\n", mine.Entry.Rank, mine.Total)
+	}
+	fmt.Println()
+}
+
 // showInfo displays an info message in cyan
 func (ui *UI) showInfo(message string) {
 	ui.cyan.Println(message)
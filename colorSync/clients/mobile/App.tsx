@@ -92,9 +92,10 @@ export default function App() {
     if (flowState === 'WAITING_ROOM' && roomId) {
       let attempts = 0;
       const checkRoomReady = async () => {
-        const ready = await apiRef.current.checkRoomReady(roomId);
-        if (ready) {
+        const status = await apiRef.current.checkRoomReady(roomId);
+        if (status.ready) {
           if (pollIntervalRef.current) clearInterval(pollIntervalRef.current);
+          if (status.roomId !== roomId) setRoomId(status.roomId);
           setFlowState('CONNECTING');
         } else if (++attempts >= 60) {
           if (pollIntervalRef.current) clearInterval(pollIntervalRef.current);
@@ -115,7 +116,8 @@ export default function App() {
   // Connect WebSocket
   useEffect(() => {
     if (flowState === 'CONNECTING' && roomId && userId) {
-      wsRef.current.connect(roomId, userId)
+      apiRef.current.freshToken()
+        .then((token) => wsRef.current.connect(roomId, token ?? ''))
         .then(() => setFlowState('PLAYING'))
         .catch(() => setFlowState('ERROR'));
     }
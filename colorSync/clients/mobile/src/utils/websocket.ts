@@ -4,12 +4,13 @@ export class GameWebSocket {
   private ws: WebSocket | null = null;
   private messageHandlers: Map<string, (payload: unknown) => void> = new Map();
 
-  connect(roomId: string, userId: string): Promise<void> {
+  connect(roomId: string, token: string): Promise<void> {
     return new Promise((resolve, reject) => {
-      const url = `ws://localhost:8003/game/ws?room_id=${roomId}&user_id=${userId}`;
+      const url = `ws://localhost:8003/game/ws?room_id=${roomId}`;
       
       console.log('🔌 Connecting to game via WebSocket...');
-      this.ws = new WebSocket(url);
+      // Browsers can't set headers on WebSockets, so the JWT rides in the subprotocol list
+      this.ws = new WebSocket(url, ['colorsync.bearer', token]);
 
       this.ws.onopen = () => {
         console.log('✅ Connected to game via WebSocket');
@@ -124,14 +124,18 @@ function App() {
 
       const checkRoomReady = async () => {
         try {
-          const ready = await apiRef.current.checkRoomReady(roomId);
+          const status = await apiRef.current.checkRoomReady(roomId);
           
-          if (ready) {
+          if (status.ready) {
             if (pollIntervalRef.current) {
               clearInterval(pollIntervalRef.current);
               pollIntervalRef.current = null;
             }
             console.log('✅ Opponent found! Room is full.');
+            if (status.roomId !== roomId) {
+              console.log('   Moved to room:', status.roomId);
+              setRoomId(status.roomId);
+            }
             
             // Skip WAITING_GAME and go straight to CONNECTING
             // The room service already notified the game service
@@ -174,7 +178,7 @@ function App() {
     if (flowState === 'CONNECTING' && roomId && userId) {
       const connectWebSocket = async () => {
         try {
-          await wsRef.current.connect(roomId, userId);
+          await wsRef.current.connect(roomId, (await apiRef.current.freshToken()) ?? '');
           setFlowState('PLAYING');
         } catch (err) {
           setError(err instanceof Error ? err.message : 'Failed to connect to game');
@@ -7,6 +7,7 @@ interface BackendAuthResponse {
   id?: string;        // Backend might return 'id'
   user_id?: string;   // Or 'user_id'
   token: string;
+  refresh_token: string;
   username: string;
 }
 
@@ -25,6 +26,7 @@ export class APIClient {
   private gameServiceURL = 'http://localhost:8003';
   
   private token: string | null = null;
+  private refreshToken: string | null = null;
   private username: string | null = null;
   private userID: string | null = null;
 
@@ -58,6 +60,7 @@ export class APIClient {
     }
 
     this.token = data.token;
+    this.refreshToken = rawData.refresh_token;
     this.username = data.username;
     this.userID = data.user_id;
     
@@ -91,6 +94,7 @@ export class APIClient {
     }
 
     this.token = data.token;
+    this.refreshToken = rawData.refresh_token;
     this.username = data.username;
     this.userID = data.user_id;
     
@@ -111,12 +115,9 @@ export class APIClient {
     console.log(`   User ID: ${userId}`);
     console.log(`   Token: ${this.token.substring(0, 20)}...`);
 
-    const response = await fetch(`${this.roomServiceURL}/join`, {
+    const response = await this.fetchAuthorized(`${this.roomServiceURL}/join`, {
       method: 'POST',
-      headers: {
-        'Content-Type': 'application/json',
-        'Authorization': `Bearer ${this.token}`,
-      },
+      headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify({ user_id: userId }),
     });
 
@@ -131,27 +132,26 @@ export class APIClient {
     return data;
   }
 
-  async checkRoomReady(roomId: string): Promise<boolean> {
+  // roomId can differ from the one we asked about when matchmaking
+  // moved us into the opponent's room
+  async checkRoomReady(roomId: string): Promise<{ ready: boolean; roomId: string }> {
     const response = await fetch(`${this.roomServiceURL}/room/${roomId}/ready`);
     
     if (!response.ok) {
       console.warn(`⚠️  Failed to check room status: ${response.status}`);
-      return false;
+      return { ready: false, roomId };
     }
 
     const data = await response.json();
-    return data.ready;
+    return { ready: data.ready, roomId: data.room_id ?? roomId };
   }
 
   async leaveRoom(roomId: string): Promise<void> {
     if (!this.token) return;
 
     try {
-      await fetch(`${this.roomServiceURL}/rooms/${roomId}/leave`, {
+      await this.fetchAuthorized(`${this.roomServiceURL}/rooms/${roomId}/leave`, {
         method: 'POST',
-        headers: {
-          'Authorization': `Bearer ${this.token}`,
-        },
       });
       console.log(`✅ Left room: ${roomId}`);
     } catch (error) {
@@ -177,6 +177,56 @@ export class APIClient {
     return data.ready;
   }
 
+  // Exchanges the refresh token for a new access token (and a new refresh token)
+  private async refresh(): Promise<void> {
+    if (!this.refreshToken) {
+      throw new Error('Session expired - please log in again');
+    }
+
+    const response = await fetch(`${this.userServiceURL}/token/refresh`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ refresh_token: this.refreshToken }),
+    });
+
+    if (!response.ok) {
+      this.token = null;
+      this.refreshToken = null;
+      throw new Error('Session expired - please log in again');
+    }
+
+    const data: { token: string; refresh_token: string } = await response.json();
+    this.token = data.token;
+    this.refreshToken = data.refresh_token;
+  }
+
+  // Sends a request with the access token
+  // On 401 it refreshes the token once and retries, like the CLI's doAuthorized
+  private async fetchAuthorized(url: string, init: RequestInit = {}): Promise<Response> {
+    const send = () =>
+      fetch(url, {
+        ...init,
+        headers: { ...(init.headers as Record<string, string>), 'Authorization': `Bearer ${this.token}` },
+      });
+
+    const response = await send();
+    if (response.status !== 401) {
+      return response;
+    }
+
+    await this.refresh();
+    return send();
+  }
+
+  // Returns an access token that is good for at least another 30 seconds,
+  // for the WebSocket, which can't be retried on 401 like a fetch
+  async freshToken(): Promise<string | null> {
+    if (this.token && tokenExpiresAt(this.token) - Date.now() < 30_000) {
+      await this.refresh();
+    }
+    return this.token;
+  }
+
   // ============================================
   // GETTERS
   // ============================================
@@ -196,4 +246,15 @@ export class APIClient {
   isAuthenticated(): boolean {
     return this.token !== null && this.userID !== null;
   }
-}
\ No newline at end of file
+}
+
+// Expiry of a JWT in milliseconds since the epoch, 0 if it can't be read
+function tokenExpiresAt(token: string): number {
+  try {
+    const payload = token.split('.')[1].replace(/-/g, '+').replace(/_/g, '/');
+    const claims = JSON.parse(atob(payload));
+    return typeof claims.exp === 'number' ? claims.exp * 1000 : 0;
+  } catch {
+    return 0;
+  }
+}
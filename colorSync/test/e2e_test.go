@@ -1,7 +1,9 @@
 package test
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,6 +11,7 @@ import (
 	"math/rand"
 	"net/http"
 	"os"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -103,16 +106,10 @@ func TestE2EGameFlow(t *testing.T) {
 			}
 			testLogger.Printf("✅ Peter joined room: %s", roomID)
 
-			// Wait for room full
+			// Wait for opponent and game
 			testLogger.Println("🔵 Peter: Waiting for opponent...")
-			if err := waitForRoomFull(roomID, 30*time.Second); err != nil {
-				errors <- fmt.Errorf("peter wait room: %w", err)
-				return
-			}
-			testLogger.Println("✅ Peter: Opponent found!")
-
-			// Wait for game ready
-			if err := waitForGameReady(roomID, 15*time.Second); err != nil {
+			roomID, err = waitForGameCreated(peter, roomID, 45*time.Second)
+			if err != nil {
 				errors <- fmt.Errorf("peter wait game: %w", err)
 				return
 			}
@@ -148,7 +145,8 @@ func TestE2EGameFlow(t *testing.T) {
 			testLogger.Printf("✅ Pam joined room: %s", roomID)
 
 			// Wait for game ready (room already full)
-			if err := waitForGameReady(roomID, 15*time.Second); err != nil {
+			roomID, err = waitForGameCreated(pam, roomID, 15*time.Second)
+			if err != nil {
 				errors <- fmt.Errorf("pam wait game: %w", err)
 				return
 			}
@@ -330,42 +328,58 @@ func joinMatchmaking(user *User) (string, error) {
 	return result.RoomID, nil
 }
 
-func waitForRoomFull(roomID string, timeout time.Duration) error {
-	deadline := time.Now().Add(timeout)
-	for time.Now().Before(deadline) {
-		resp, _ := http.Get(fmt.Sprintf("%s/room/%s/ready", roomServiceURL, roomID))
-		if resp != nil && resp.StatusCode == http.StatusOK {
-			var result struct {
-				Ready bool `json:"ready"`
-			}
-			json.NewDecoder(resp.Body).Decode(&result)
-			resp.Body.Close()
-			if result.Ready {
-				return nil
-			}
-		}
-		time.Sleep(time.Second)
+// waitForGameCreated follows the room event stream until Game Service has the game
+// Returns the room ID from the events, matchmaking may have moved the player
+func waitForGameCreated(user *User, roomID string, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/rooms/%s/events", roomServiceURL, roomID), nil)
+	if err != nil {
+		return "", err
 	}
-	return fmt.Errorf("timeout")
-}
+	req.Header.Set("Authorization", "Bearer "+user.Token)
 
-func waitForGameReady(roomID string, timeout time.Duration) error {
-	deadline := time.Now().Add(timeout)
-	for time.Now().Before(deadline) {
-		resp, _ := http.Get(fmt.Sprintf("%s/game/status?room_id=%s", gameServiceURL, roomID))
-		if resp != nil && resp.StatusCode == http.StatusOK {
-			resp.Body.Close()
-			return nil
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("events failed: %s", body)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var event struct {
+			Type   string `json:"type"`
+			RoomID string `json:"room_id"`
+		}
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return "", err
+		}
+		switch event.Type {
+		case "game_created":
+			return event.RoomID, nil
+		case "room_error", "room_closed":
+			return "", fmt.Errorf("%s", event.Type)
 		}
-		time.Sleep(time.Second)
 	}
-	return fmt.Errorf("timeout")
+	return "", fmt.Errorf("timeout")
 }
 
 // Play game with random answers to test game mechanics (not trying to win)
 func playGameWithRandomAnswers(user *User, roomID string) (string, error) {
-	wsURL := fmt.Sprintf("ws://localhost:8003/game/ws?room_id=%s&user_id=%s", roomID, user.UserID)
-	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	wsURL := fmt.Sprintf("ws://localhost:8003/game/ws?room_id=%s", roomID)
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+user.Token)
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, header)
 	if err != nil {
 		return "", err
 	}